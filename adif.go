@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// adifField is a data specifier in an ADIF file, like <CALL:4>N0CA.
+type adifField struct {
+	Name  string
+	Type  string
+	Value string
+}
+
+// adifRecord is the fields of a QSO, in the order they appear in the file.
+type adifRecord []adifField
+
+// adifFile is an ADI format file, fields we don't know about are kept as they are.
+type adifFile struct {
+	preamble  string
+	header    []adifField
+	hasHeader bool
+	records   []adifRecord
+}
+
+// get returns the value of the field called name, and whether the record has it.
+func (r adifRecord) get(name string) (string, bool) {
+	for _, f := range r {
+		if strings.EqualFold(f.Name, name) {
+			return f.Value, true
+		}
+	}
+	return "", false
+}
+
+// set changes the value of the field called name, adding it to the end if the record doesn't have it.
+func (r *adifRecord) set(name, value string) {
+	for i, f := range *r {
+		if strings.EqualFold(f.Name, name) {
+			(*r)[i].Value = value
+			return
+		}
+	}
+	*r = append(*r, adifField{Name: name, Value: value})
+}
+
+// adifTokenizer returns the data specifiers in an ADI file one at a time.
+type adifTokenizer struct {
+	b   []byte
+	pos int
+}
+
+// next returns the next field, or io.EOF when there are no more
+// the text skipped over to get to the field is returned too.
+func (t *adifTokenizer) next() (adifField, string, error) {
+	i := bytes.IndexByte(t.b[t.pos:], '<')
+	if i < 0 {
+		t.pos = len(t.b)
+		return adifField{}, "", io.EOF
+	}
+	skipped := string(t.b[t.pos : t.pos+i])
+	start := t.pos + i
+
+	j := bytes.IndexByte(t.b[start:], '>')
+	if j < 0 {
+		err := fmt.Errorf("ADIF field at offset %d isn't closed", start)
+		log.Printf("%+v", err)
+		return adifField{}, skipped, err
+	}
+	end := start + j
+
+	// NAME[:LENGTH[:TYPE]]
+	parts := strings.SplitN(string(t.b[start+1:end]), ":", 3)
+	f := adifField{Name: strings.TrimSpace(parts[0])}
+	if f.Name == "" {
+		err := fmt.Errorf("ADIF field at offset %d has no name", start)
+		log.Printf("%+v", err)
+		return adifField{}, skipped, err
+	}
+	t.pos = end + 1
+
+	if len(parts) == 1 {
+		// EOH and EOR have no data
+		return f, skipped, nil
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil || n < 0 {
+		err = fmt.Errorf("ADIF field %s at offset %d has invalid length %q", f.Name, start, parts[1])
+		log.Printf("%+v", err)
+		return adifField{}, skipped, err
+	}
+	if t.pos+n > len(t.b) {
+		err = fmt.Errorf("ADIF field %s at offset %d is longer than the file", f.Name, start)
+		log.Printf("%+v", err)
+		return adifField{}, skipped, err
+	}
+	if len(parts) == 3 {
+		f.Type = parts[2]
+	}
+
+	f.Value = string(t.b[t.pos : t.pos+n])
+	t.pos += n
+
+	return f, skipped, nil
+}
+
+// parseADIF parses the ADI format file in b.
+func parseADIF(b []byte) (*adifFile, error) {
+	af := &adifFile{}
+	t := &adifTokenizer{b: b}
+
+	// a file with a header doesn't start with <
+	inHeader := len(b) > 0 && b[0] != '<'
+
+	var r adifRecord
+	for {
+		f, skipped, err := t.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case inHeader && strings.EqualFold(f.Name, "EOH"):
+			if len(af.header) == 0 {
+				af.preamble = skipped
+			}
+			af.hasHeader = true
+			inHeader = false
+		case inHeader:
+			if len(af.header) == 0 {
+				af.preamble = skipped
+			}
+			af.header = append(af.header, f)
+		case strings.EqualFold(f.Name, "EOR"):
+			af.records = append(af.records, r)
+			r = nil
+		default:
+			r = append(r, f)
+		}
+	}
+
+	if inHeader {
+		err := fmt.Errorf("ADIF header has no <EOH>")
+		log.Printf("%+v", err)
+		return nil, err
+	}
+	if len(r) > 0 {
+		err := fmt.Errorf("ADIF record %d has no <EOR>", len(af.records)+1)
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	return af, nil
+}
+
+// writeADIFField writes f as a data specifier.
+func writeADIFField(w io.Writer, f adifField) error {
+	var err error
+	if f.Type != "" {
+		_, err = fmt.Fprintf(w, "<%s:%d:%s>%s", f.Name, len(f.Value), f.Type, f.Value)
+	} else {
+		_, err = fmt.Fprintf(w, "<%s:%d>%s", f.Name, len(f.Value), f.Value)
+	}
+	if err != nil {
+		log.Printf("%+v", err)
+	}
+	return err
+}
+
+// write writes the file in ADI format, one record per line.
+func (af *adifFile) write(w io.Writer) error {
+	if af.hasHeader {
+		_, err := io.WriteString(w, af.preamble)
+		if err != nil {
+			log.Printf("%+v", err)
+			return err
+		}
+		for _, f := range af.header {
+			err = writeADIFField(w, f)
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(w, "\n")
+			if err != nil {
+				log.Printf("%+v", err)
+				return err
+			}
+		}
+		_, err = io.WriteString(w, "<EOH>\n\n")
+		if err != nil {
+			log.Printf("%+v", err)
+			return err
+		}
+	}
+
+	for _, r := range af.records {
+		for _, f := range r {
+			err := writeADIFField(w, f)
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(w, " ")
+			if err != nil {
+				log.Printf("%+v", err)
+				return err
+			}
+		}
+		_, err := io.WriteString(w, "<EOR>\n")
+		if err != nil {
+			log.Printf("%+v", err)
+			return err
+		}
+	}
+
+	return nil
+}
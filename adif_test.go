@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func Test_parseADIF(t *testing.T) {
+	tests := []struct {
+		name    string
+		adif    string
+		want    *adifFile
+		wantErr bool
+	}{
+		{
+			name: "Header",
+			adif: "Exported by logger\n<ADIF_VER:5>3.1.4\n<PROGRAMID:6>logger\n<EOH>\n" +
+				"<CALL:4>N0CA <QSO_DATE:8:D>20240601 <APP_LOGGER_X:3>a<b <EOR>\n",
+			want: &adifFile{
+				preamble:  "Exported by logger\n",
+				header:    []adifField{{Name: "ADIF_VER", Value: "3.1.4"}, {Name: "PROGRAMID", Value: "logger"}},
+				hasHeader: true,
+				records: []adifRecord{
+					{{Name: "CALL", Value: "N0CA"}, {Name: "QSO_DATE", Type: "D", Value: "20240601"}, {Name: "APP_LOGGER_X", Value: "a<b"}},
+				},
+			},
+		},
+		{
+			name: "No header",
+			adif: "<call:4>N0CA<eor><call:5>N0CAL<eor>",
+			want: &adifFile{
+				records: []adifRecord{
+					{{Name: "call", Value: "N0CA"}},
+					{{Name: "call", Value: "N0CAL"}},
+				},
+			},
+		},
+		{
+			name:    "No EOH",
+			adif:    "Exported by logger\n<ADIF_VER:5>3.1.4\n",
+			wantErr: true,
+		},
+		{
+			name:    "No EOR",
+			adif:    "<CALL:4>N0CA <EOR><CALL:5>N0CAL",
+			wantErr: true,
+		},
+		{
+			name:    "Bad length",
+			adif:    "<CALL:x>N0CA <EOR>",
+			wantErr: true,
+		},
+		{
+			name:    "Too long",
+			adif:    "<CALL:40>N0CA <EOR>",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			got, err := parseADIF([]byte(ttt.adif))
+			if (err != nil) != ttt.wantErr {
+				t.Errorf("parseADIF() error = %v, wantErr %v", err, ttt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, ttt.want) {
+				t.Errorf("parseADIF() = %+v, want %+v", got, ttt.want)
+			}
+		})
+	}
+}
+
+func Test_adifFile_write(t *testing.T) {
+	adif := "Exported by logger\n<ADIF_VER:5>3.1.4\n<EOH>\n\n" +
+		"<CALL:4>N0CA <QSO_DATE:8:D>20240601 <APP_LOGGER_X:3>a<b <EOR>\n"
+
+	af, err := parseADIF([]byte(adif))
+	if err != nil {
+		t.Fatalf("parseADIF() error = %v", err)
+	}
+
+	var b bytes.Buffer
+	err = af.write(&b)
+	if err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+	if b.String() != adif {
+		t.Errorf("write() = %q, want %q", b.String(), adif)
+	}
+
+	// changed values get the right length
+	af.records[0].set("call", "N0CALL")
+	af.records[0].set("MY_GRIDSQUARE", "FM18lw")
+	want := "<CALL:6>N0CALL <QSO_DATE:8:D>20240601 <APP_LOGGER_X:3>a<b <MY_GRIDSQUARE:6>FM18lw <EOR>\n"
+
+	b.Reset()
+	af.hasHeader = false
+	err = af.write(&b)
+	if err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+	if b.String() != want {
+		t.Errorf("write() = %q, want %q", b.String(), want)
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// apiStatus is the JSON representation of the gps data, values we don't have are null.
+type apiStatus struct {
+	Status        string         `json:"status"`
+	Time          *time.Time     `json:"time"`
+	Gridsquare    *string        `json:"gridsquare"`
+	Latitude      *float64       `json:"latitude"`
+	Longitude     *float64       `json:"longitude"`
+	FixQuality    *string        `json:"fixQuality"`
+	NumSatellites *int           `json:"numSatellites"`
+	HDOP          *float64       `json:"hdop"`
+	Altitude      *float64       `json:"altitudeMeters"`
+	Speed         *float64       `json:"speedKnots"`
+	Course        *float64       `json:"courseDegrees"`
+	PDOP          *float64       `json:"pdop"`
+	VDOP          *float64       `json:"vdop"`
+	FixMode       *int           `json:"fixMode"`
+	ClockOffset   *float64       `json:"clockOffsetSeconds"`
+	Stale         bool           `json:"stale"`
+	LastAttempt   *time.Time     `json:"lastAttempt"`
+	Synced        *time.Time     `json:"synced"`
+	SyncOffset    *float64       `json:"syncOffsetSeconds"`
+	FixAge        *float64       `json:"fixAgeSeconds,omitempty"`
+	Satellites    []apiSatellite `json:"satellites,omitempty"`
+}
+
+// apiSatellite is the JSON representation of a satellite in view, values the receiver doesn't know are null.
+type apiSatellite struct {
+	System    string `json:"system"`
+	PRN       int    `json:"prn"`
+	Elevation *int   `json:"elevation"`
+	Azimuth   *int   `json:"azimuth"`
+	SNR       *int   `json:"snr"`
+	Used      bool   `json:"used"`
+}
+
+// newAPISatellite converts s to its JSON representation.
+func newAPISatellite(s satellite) apiSatellite {
+	a := apiSatellite{
+		System: s.System,
+		PRN:    s.PRN,
+		Used:   s.Used,
+	}
+	if s.Elevation > -1 {
+		a.Elevation = &s.Elevation
+	}
+	if s.Azimuth > -1 {
+		a.Azimuth = &s.Azimuth
+	}
+	if s.SNR > -1 {
+		a.SNR = &s.SNR
+	}
+	return a
+}
+
+// satellite converts a back.
+func (a apiSatellite) satellite() satellite {
+	s := satellite{
+		System:    a.System,
+		PRN:       a.PRN,
+		Elevation: -1,
+		Azimuth:   -1,
+		SNR:       -1,
+		Used:      a.Used,
+	}
+	if a.Elevation != nil {
+		s.Elevation = *a.Elevation
+	}
+	if a.Azimuth != nil {
+		s.Azimuth = *a.Azimuth
+	}
+	if a.SNR != nil {
+		s.SNR = *a.SNR
+	}
+	return s
+}
+
+// newAPIStatus converts v to its JSON representation, using the same rules as the formatX methods of gpsData.
+func newAPIStatus(v gpsValues) apiStatus {
+	a := apiStatus{
+		Status: "OK",
+		Stale:  v.Stale,
+	}
+
+	if v.Status != "" {
+		a.Status = v.Status
+	}
+	if v.Time != (time.Time{}) {
+		a.Time = &v.Time
+	}
+	if v.Gridsquare != "" {
+		a.Gridsquare = &v.Gridsquare
+	}
+	if v.Latitude >= -90.0 && v.Latitude <= 90.0 {
+		a.Latitude = &v.Latitude
+	}
+	if v.Longitude >= -180.0 && v.Longitude <= 180.0 {
+		a.Longitude = &v.Longitude
+	}
+	if v.FixQuality != "" {
+		a.FixQuality = &v.FixQuality
+	}
+	if v.NumSatellites > -1 {
+		a.NumSatellites = &v.NumSatellites
+	}
+	if v.HDOP > -1 {
+		a.HDOP = &v.HDOP
+	}
+	if !math.IsNaN(v.Altitude) {
+		a.Altitude = &v.Altitude
+	}
+	if v.Speed > -1 {
+		a.Speed = &v.Speed
+	}
+	if v.Course > -1 {
+		a.Course = &v.Course
+	}
+	if v.PDOP > -1 {
+		a.PDOP = &v.PDOP
+	}
+	if v.VDOP > -1 {
+		a.VDOP = &v.VDOP
+	}
+	if v.FixMode > 0 {
+		a.FixMode = &v.FixMode
+	}
+	// only measured when there is a good enough fix
+	if v.ClockOffset != 0 {
+		o := v.ClockOffset.Seconds()
+		a.ClockOffset = &o
+	}
+	if v.Attempted != (time.Time{}) {
+		a.LastAttempt = &v.Attempted
+	}
+	if v.Synced != (time.Time{}) {
+		a.Synced = &v.Synced
+		o := v.SyncOffset.Seconds()
+		a.SyncOffset = &o
+	}
+	for _, s := range v.Satellites {
+		a.Satellites = append(a.Satellites, newAPISatellite(s))
+	}
+
+	return a
+}
+
+// newAPIStatusAt is newAPIStatus with how long before now the last good fix was.
+func newAPIStatusAt(v gpsValues, now time.Time) apiStatus {
+	a := newAPIStatus(v)
+
+	if v.Time != (time.Time{}) {
+		age := now.Sub(v.Time).Seconds()
+		a.FixAge = &age
+	}
+
+	return a
+}
+
+// writeJSON writes v to w as the response.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Printf("%+v", err)
+	}
+}
+
+// handleStatus returns the current gps data.
+func handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newAPIStatusAt(gpsdata.values(), time.Now()))
+}
+
+// how long POST /update waits for a poll that was already running, a bit more than a poll can take.
+const apiUpdateWait = gpsPollTimeout + 5*time.Second
+
+// isSameOrigin returns true if r wasn't sent by a web page from somewhere else.
+func isSameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		// not from a browser
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+// isJSON returns true if the body of r is JSON, which web pages can't send to other sites without asking first.
+func isJSON(r *http.Request) bool {
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mt == "application/json"
+}
+
+// handleUpdate polls the gps device now and returns the resulting gps data
+// it sets the system time, so web pages from other sites can't ask for it.
+func handleUpdate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if !isSameOrigin(r) {
+		http.Error(w, "cross-origin requests aren't allowed", http.StatusForbidden)
+		return
+	}
+	if !isJSON(r) {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// every poll publishes when it finishes, ours or the one already running
+	updates, unsubscribe := subscribe()
+	defer unsubscribe()
+
+	if gatherGpsData(true) {
+		writeJSON(w, http.StatusOK, newAPIStatusAt(gpsdata.values(), time.Now()))
+		return
+	}
+
+	code := http.StatusServiceUnavailable
+	timer := time.NewTimer(apiUpdateWait)
+	defer timer.Stop()
+	select {
+	case u := <-updates:
+		if u.values.Status == "" {
+			code = http.StatusOK
+		}
+	case <-timer.C:
+	case <-r.Context().Done():
+		return
+	}
+
+	writeJSON(w, code, newAPIStatusAt(gpsdata.values(), time.Now()))
+}
+
+// handleEvents streams the gps data as Server-Sent Events, starting with the current values and then after every poll.
+func handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	f, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	updates, unsubscribe := subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event string, v gpsValues) error {
+		b, err := json.Marshal(newAPIStatusAt(v, time.Now()))
+		if err != nil {
+			log.Printf("%+v", err)
+			return err
+		}
+
+		_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+		if err != nil {
+			return err
+		}
+		f.Flush()
+		return nil
+	}
+
+	err := send("status", gpsdata.values())
+	if err != nil {
+		return
+	}
+
+	for {
+		select {
+		case u, ok := <-updates:
+			if !ok {
+				return
+			}
+
+			err = send("status", u.values)
+			if err != nil {
+				return
+			}
+
+			for _, e := range u.events {
+				err = send(string(e), u.values)
+				if err != nil {
+					return
+				}
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// newAPIHandler returns the handler for all the api endpoints.
+func newAPIHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", handleStatus)
+	mux.HandleFunc("/update", handleUpdate)
+	mux.HandleFunc("/events", handleEvents)
+	mux.HandleFunc("/metrics", handleMetrics)
+	mux.HandleFunc("/history", handleHistory)
+
+	return mux
+}
+
+// apiListenAddress returns address with the host defaulted to loopback, so the api is only on other interfaces when asked for.
+func apiListenAddress(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || host != "" {
+		return address
+	}
+	return net.JoinHostPort("127.0.0.1", port)
+}
+
+// startAPI starts serving the api on address in the background.
+func startAPI(address string) (*http.Server, error) {
+	l, err := net.Listen("tcp", apiListenAddress(address))
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	srv := &http.Server{
+		Handler:           newAPIHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		err := srv.Serve(l)
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("%+v", err)
+		}
+	}()
+
+	return srv, nil
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_newAPIStatus(t *testing.T) {
+	g := newGPSData()
+	g.setGridsquare("FM18lw")
+	g.setLatitude(38.92)
+	g.setLongitude(-77.065)
+	g.setNumSatellites(0)
+	g.setTime(time.Date(2020, time.Month(1), 18, 2, 2, 2, 0, time.UTC))
+
+	type args struct {
+		v gpsValues
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "Empty",
+			args: args{v: newGPSData().values()},
+			want: `{"status":"OK","time":null,"gridsquare":null,"latitude":null,"longitude":null,"fixQuality":null,"numSatellites":null,"hdop":null,"altitudeMeters":null,"speedKnots":null,"courseDegrees":null,"pdop":null,"vdop":null,"fixMode":null,"clockOffsetSeconds":null,"stale":false,"lastAttempt":null,"synced":null,"syncOffsetSeconds":null}`,
+		},
+		{
+			name: "Washington DC",
+			args: args{v: g.values()},
+			want: `{"status":"OK","time":"2020-01-18T02:02:02Z","gridsquare":"FM18lw","latitude":38.92,"longitude":-77.065,"fixQuality":null,"numSatellites":0,"hdop":null,"altitudeMeters":null,"speedKnots":null,"courseDegrees":null,"pdop":null,"vdop":null,"fixMode":null,"clockOffsetSeconds":null,"stale":false,"lastAttempt":null,"synced":null,"syncOffsetSeconds":null}`,
+		},
+		{
+			name: "Everything",
+			args: args{v: gpsValues{
+				Time:          time.Date(2020, time.Month(1), 18, 2, 2, 2, 0, time.UTC),
+				Gridsquare:    "FM18lw",
+				Latitude:      38.92,
+				Longitude:     -77.065,
+				FixQuality:    "GPS",
+				FixMode:       3,
+				NumSatellites: 8,
+				HDOP:          0.9,
+				ClockOffset:   1500 * time.Millisecond,
+				Altitude:      102.5,
+				Speed:         12.5,
+				Course:        270,
+				PDOP:          1.6,
+				VDOP:          1.3,
+				Attempted:     time.Date(2020, time.Month(1), 18, 2, 2, 3, 0, time.UTC),
+				Synced:        time.Date(2020, time.Month(1), 18, 2, 2, 3, 0, time.UTC),
+				SyncOffset:    1500 * time.Millisecond,
+			}},
+			want: `{"status":"OK","time":"2020-01-18T02:02:02Z","gridsquare":"FM18lw","latitude":38.92,"longitude":-77.065,"fixQuality":"GPS","numSatellites":8,"hdop":0.9,"altitudeMeters":102.5,"speedKnots":12.5,"courseDegrees":270,"pdop":1.6,"vdop":1.3,"fixMode":3,"clockOffsetSeconds":1.5,"stale":false,"lastAttempt":"2020-01-18T02:02:03Z","synced":"2020-01-18T02:02:03Z","syncOffsetSeconds":1.5}`,
+		},
+		{
+			name: "Error",
+			args: args{v: gpsValues{Status: "GGA line bad checksum", Latitude: -91, Longitude: -181, NumSatellites: -1, HDOP: -1, Altitude: math.NaN(), Speed: -1, Course: -1, PDOP: -1, VDOP: -1}},
+			want: `{"status":"GGA line bad checksum","time":null,"gridsquare":null,"latitude":null,"longitude":null,"fixQuality":null,"numSatellites":null,"hdop":null,"altitudeMeters":null,"speedKnots":null,"courseDegrees":null,"pdop":null,"vdop":null,"fixMode":null,"clockOffsetSeconds":null,"stale":false,"lastAttempt":null,"synced":null,"syncOffsetSeconds":null}`,
+		},
+		{
+			name: "Stale",
+			args: args{v: gpsValues{Gridsquare: "FM18lw", Latitude: -91, Longitude: -181, NumSatellites: -1, HDOP: -1, Altitude: math.NaN(), Speed: -1, Course: -1, PDOP: -1, VDOP: -1, Stale: true}},
+			want: `{"status":"OK","time":null,"gridsquare":"FM18lw","latitude":null,"longitude":null,"fixQuality":null,"numSatellites":null,"hdop":null,"altitudeMeters":null,"speedKnots":null,"courseDegrees":null,"pdop":null,"vdop":null,"fixMode":null,"clockOffsetSeconds":null,"stale":true,"lastAttempt":null,"synced":null,"syncOffsetSeconds":null}`,
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			b, err := json.Marshal(newAPIStatus(ttt.args.v))
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+			if string(b) != ttt.want {
+				t.Errorf("newAPIStatus() = %s, want %s", b, ttt.want)
+			}
+		})
+	}
+}
+
+func Test_newAPIStatusAt(t *testing.T) {
+	tm := time.Date(2024, 6, 1, 14, 0, 0, 0, time.UTC)
+
+	g := newGPSData()
+	a := newAPIStatusAt(g.values(), tm)
+	if a.FixAge != nil || a.LastAttempt != nil {
+		t.Errorf("newAPIStatusAt() = %+v, want no fix age or last attempt", a)
+	}
+
+	// failed poll keeps the last good fix
+	g.setGridsquare("FM18lw")
+	g.setTime(tm)
+	g.setStatus("GGA line bad checksum")
+	g.setAttempted(tm.Add(90 * time.Second))
+
+	a = newAPIStatusAt(g.values(), tm.Add(100*time.Second))
+	if a.FixAge == nil || *a.FixAge != 100 || a.LastAttempt == nil || !a.LastAttempt.Equal(tm.Add(90*time.Second)) {
+		t.Errorf("newAPIStatusAt() = %+v, want fix age 100 and last attempt", a)
+	}
+	if a.Status != "GGA line bad checksum" || a.Gridsquare == nil || *a.Gridsquare != "FM18lw" {
+		t.Errorf("newAPIStatusAt() = %+v, want error status with last good gridsquare", a)
+	}
+	if got := g.formatFixAge(tm.Add(100 * time.Second)); got != "1m40s ago" {
+		t.Errorf("formatFixAge() = %v, want 1m40s ago", got)
+	}
+}
+
+func Test_apiHandler(t *testing.T) {
+	h := newAPIHandler()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   int
+	}{
+		{name: "Status", method: http.MethodGet, path: "/status", want: http.StatusOK},
+		{name: "Status POST", method: http.MethodPost, path: "/status", want: http.StatusMethodNotAllowed},
+		{name: "Update GET", method: http.MethodGet, path: "/update", want: http.StatusMethodNotAllowed},
+		{name: "History", method: http.MethodGet, path: "/history", want: http.StatusOK},
+		{name: "History POST", method: http.MethodPost, path: "/history", want: http.StatusMethodNotAllowed},
+		{name: "Unknown", method: http.MethodGet, path: "/nothing", want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(ttt.method, ttt.path, nil))
+			if w.Code != ttt.want {
+				t.Errorf("ServeHTTP() code = %v, want %v", w.Code, ttt.want)
+			}
+		})
+	}
+}
+
+func Test_handleUpdate(t *testing.T) {
+	tests := []struct {
+		name        string
+		origin      string
+		contentType string
+		want        int
+	}{
+		{name: "Cross-origin", origin: "http://example.org", contentType: "application/json", want: http.StatusForbidden},
+		{name: "Form", contentType: "application/x-www-form-urlencoded", want: http.StatusUnsupportedMediaType},
+		{name: "No content type", want: http.StatusUnsupportedMediaType},
+		// there is no gps device configured, so the poll fails
+		{name: "Same origin", origin: "http://example.com", contentType: "application/json; charset=utf-8", want: http.StatusServiceUnavailable},
+		{name: "Not a browser", contentType: "application/json", want: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/update", nil)
+			if ttt.origin != "" {
+				r.Header.Set("Origin", ttt.origin)
+			}
+			if ttt.contentType != "" {
+				r.Header.Set("Content-Type", ttt.contentType)
+			}
+
+			w := httptest.NewRecorder()
+			handleUpdate(w, r)
+			if w.Code != ttt.want {
+				t.Errorf("handleUpdate() code = %v, want %v", w.Code, ttt.want)
+			}
+		})
+	}
+}
+
+func Test_handleUpdate_inProgress(t *testing.T) {
+	// a scheduled poll is running
+	if !nbmGatherGpsData.Lock() {
+		t.Fatal("nbmGatherGpsData.Lock() = false")
+	}
+
+	countSubscribers := func() int {
+		subscribersMu.Lock()
+		defer subscribersMu.Unlock()
+
+		return len(subscribers)
+	}
+	before := countSubscribers()
+
+	done := make(chan int)
+	go func() {
+		r := httptest.NewRequest(http.MethodPost, "/update", nil)
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handleUpdate(w, r)
+		done <- w.Code
+	}()
+
+	// finishes once the update is waiting for it
+	for countSubscribers() == before {
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case code := <-done:
+		t.Fatalf("handleUpdate() = %v before the poll finished", code)
+	case <-time.After(50 * time.Millisecond):
+	}
+	publish(nil, gpsValues{Gridsquare: "FM18lw"})
+	nbmGatherGpsData.Unlock()
+
+	select {
+	case code := <-done:
+		if code != http.StatusOK {
+			t.Errorf("handleUpdate() code = %v, want %v", code, http.StatusOK)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("handleUpdate() didn't return after the poll finished")
+	}
+}
+
+func Test_apiListenAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+	}{
+		{address: ":8080", want: "127.0.0.1:8080"},
+		{address: "127.0.0.1:8080", want: "127.0.0.1:8080"},
+		{address: "0.0.0.0:8080", want: "0.0.0.0:8080"},
+		{address: "[::1]:8080", want: "[::1]:8080"},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.address, func(t *testing.T) {
+			if got := apiListenAddress(ttt.address); got != ttt.want {
+				t.Errorf("apiListenAddress() = %v, want %v", got, ttt.want)
+			}
+		})
+	}
+}
+
+func Test_handleEvents(t *testing.T) {
+	ts := httptest.NewServer(newAPIHandler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/events")
+	if err != nil {
+		t.Fatalf("http.Get() error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	r := bufio.NewReader(resp.Body)
+	next := func() string {
+		var lines []string
+		for {
+			l, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatalf("ReadString() error = %v", err)
+			}
+			if l == "\n" {
+				return strings.Join(lines, "")
+			}
+			lines = append(lines, l)
+		}
+	}
+
+	// current values come first
+	got := next()
+	if !strings.HasPrefix(got, "event: status\n") {
+		t.Errorf("handleEvents() got = %q, want status event", got)
+	}
+
+	// subscription is in place once the first event is sent
+	v := newGPSData().values()
+	v.Gridsquare = "RB32id"
+	publish([]gpsEvent{eventGridChanged}, v)
+
+	got = next()
+	if !strings.HasPrefix(got, "event: status\n") || !strings.Contains(got, `"gridsquare":"RB32id"`) {
+		t.Errorf("handleEvents() got = %q, want status event with gridsquare", got)
+	}
+	got = next()
+	if !strings.HasPrefix(got, "event: gridchanged\n") {
+		t.Errorf("handleEvents() got = %q, want gridchanged event", got)
+	}
+}
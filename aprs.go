@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	// experimental APRS destination, identifies the software that sent the packet
+	aprsToCall = "APZQTR"
+
+	// KISS framing
+	kissFEND  byte = 0xC0
+	kissFESC  byte = 0xDB
+	kissTFEND byte = 0xDC
+	kissTFESC byte = 0xDD
+)
+
+// aprsPosition is what goes into an APRS position report.
+type aprsPosition struct {
+	lat         float64
+	lon         float64
+	course      float64
+	speed       float64
+	altitude    float64
+	symbolTable byte
+	symbolCode  byte
+	comment     string
+}
+
+// formatAPRSLatitude returns lat as ddmm.mmN.
+func formatAPRSLatitude(lat float64) string {
+	h := int(math.Round(math.Abs(lat) * 6000))
+	ns := 'N'
+	if lat < 0 {
+		ns = 'S'
+	}
+	return fmt.Sprintf("%02d%02d.%02d%c", h/6000, h%6000/100, h%100, ns)
+}
+
+// formatAPRSLongitude returns lon as dddmm.mmE.
+func formatAPRSLongitude(lon float64) string {
+	h := int(math.Round(math.Abs(lon) * 6000))
+	ew := 'E'
+	if lon < 0 {
+		ew = 'W'
+	}
+	return fmt.Sprintf("%03d%02d.%02d%c", h/6000, h%6000/100, h%100, ew)
+}
+
+// encodeBase91 returns v as n base 91 characters.
+func encodeBase91(v int, n int) string {
+	b := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		b[i] = byte(v%91) + 33
+		v /= 91
+	}
+	return string(b)
+}
+
+// hasMotion returns true if the course and speed are known.
+func (p aprsPosition) hasMotion() bool {
+	return p.course >= 0 && p.speed >= 0
+}
+
+// formatComment returns the altitude, if known, and the comment.
+func (p aprsPosition) formatComment() string {
+	if math.IsNaN(p.altitude) {
+		return p.comment
+	}
+
+	// altitude is in feet
+	return fmt.Sprintf("/A=%06d%s", int(math.Round(p.altitude/0.3048)), p.comment)
+}
+
+// encodeUncompressed returns the information field of an uncompressed position report without timestamp.
+func (p aprsPosition) encodeUncompressed() string {
+	var b strings.Builder
+
+	b.WriteByte('!')
+	b.WriteString(formatAPRSLatitude(p.lat))
+	b.WriteByte(p.symbolTable)
+	b.WriteString(formatAPRSLongitude(p.lon))
+	b.WriteByte(p.symbolCode)
+
+	if p.hasMotion() {
+		// course 0 means unknown, so north is 360
+		c := int(math.Round(p.course)) % 360
+		if c == 0 {
+			c = 360
+		}
+		fmt.Fprintf(&b, "%03d/%03d", c, int(math.Round(p.speed)))
+	}
+
+	b.WriteString(p.formatComment())
+
+	return b.String()
+}
+
+// encodeCompressed returns the information field of a Base91 compressed position report without timestamp.
+func (p aprsPosition) encodeCompressed() string {
+	var b strings.Builder
+
+	b.WriteByte('!')
+	b.WriteByte(p.symbolTable)
+	b.WriteString(encodeBase91(int(380926*(90-p.lat)), 4))
+	b.WriteString(encodeBase91(int(190463*(180+p.lon)), 4))
+	b.WriteByte(p.symbolCode)
+
+	if p.hasMotion() {
+		c := int(p.course/4) % 90
+		s := int(math.Round(math.Log(p.speed+1) / math.Log(1.08)))
+		if s > 89 {
+			s = 89
+		}
+
+		// current GPS fix, from RMC, by software
+		b.WriteByte(byte(c) + 33)
+		b.WriteByte(byte(s) + 33)
+		b.WriteByte(0x3A + 33)
+	} else {
+		b.WriteString("   ")
+	}
+
+	b.WriteString(p.formatComment())
+
+	return b.String()
+}
+
+// parseCallsign splits a callsign like N0CALL-9 into its base and SSID.
+func parseCallsign(c string) (string, int, error) {
+	base := strings.ToUpper(c)
+	ssid := 0
+
+	if i := strings.Index(base, "-"); i >= 0 {
+		var err error
+		ssid, err = strconv.Atoi(base[i+1:])
+		if err != nil || ssid < 0 || ssid > 15 {
+			err = fmt.Errorf("invalid SSID in callsign %s", c)
+			log.Printf("%+v", err)
+			return "", 0, err
+		}
+		base = base[:i]
+	}
+
+	if base == "" || len(base) > 6 {
+		err := fmt.Errorf("invalid callsign %s", c)
+		log.Printf("%+v", err)
+		return "", 0, err
+	}
+	for _, r := range base {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			err := fmt.Errorf("invalid callsign %s", c)
+			log.Printf("%+v", err)
+			return "", 0, err
+		}
+	}
+
+	return base, ssid, nil
+}
+
+// encodeAX25Address returns the 7 byte AX.25 address for callsign, flags are the bits to set in the SSID byte.
+func encodeAX25Address(callsign string, flags byte) ([]byte, error) {
+	base, ssid, err := parseCallsign(callsign)
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 7)
+	for i := 0; i < 6; i++ {
+		c := byte(' ')
+		if i < len(base) {
+			c = base[i]
+		}
+		b[i] = c << 1
+	}
+	b[6] = 0x60 | byte(ssid)<<1 | flags
+
+	return b, nil
+}
+
+// encodeAX25UIFrame returns an AX.25 UI frame, without FCS, from source to destination via path carrying info.
+func encodeAX25UIFrame(source, destination string, path []string, info string) ([]byte, error) {
+	var b bytes.Buffer
+
+	addrs := append([]string{destination, source}, path...)
+	for i, a := range addrs {
+		var flags byte
+
+		// command frame has the C bit set in the destination
+		if i == 0 {
+			flags |= 0x80
+		}
+
+		// last address
+		if i == len(addrs)-1 {
+			flags |= 0x01
+		}
+
+		ab, err := encodeAX25Address(a, flags)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(ab)
+	}
+
+	// UI frame, no layer 3 protocol
+	b.WriteByte(0x03)
+	b.WriteByte(0xF0)
+	b.WriteString(info)
+
+	return b.Bytes(), nil
+}
+
+// encodeKISSFrame returns frame as a KISS data frame for TNC port 0.
+func encodeKISSFrame(frame []byte) []byte {
+	b := []byte{kissFEND, 0x00}
+
+	for _, c := range frame {
+		switch c {
+		case kissFEND:
+			b = append(b, kissFESC, kissTFEND)
+		case kissFESC:
+			b = append(b, kissFESC, kissTFESC)
+		default:
+			b = append(b, c)
+		}
+	}
+
+	return append(b, kissFEND)
+}
+
+// formatTNC2 returns the packet in the text format used by APRS-IS.
+func formatTNC2(source, destination string, path []string, info string) string {
+	return fmt.Sprintf("%s>%s:%s", source, strings.Join(append([]string{destination}, path...), ","), info)
+}
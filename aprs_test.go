@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func Test_aprsPosition_encodeUncompressed(t *testing.T) {
+	type args struct {
+		p aprsPosition
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "Parked",
+			args: args{p: aprsPosition{lat: 49.058333, lon: -72.029167, course: -1, speed: -1, altitude: math.NaN(), symbolTable: '/', symbolCode: '-', comment: "Test"}},
+			want: "!4903.50N/07201.75W-Test",
+		},
+		{
+			name: "Moving",
+			args: args{p: aprsPosition{lat: -22.912328, lon: -43.182617, course: 88, speed: 36.2, altitude: 1234 * 0.3048, symbolTable: '/', symbolCode: '>'}},
+			want: "!2254.74S/04310.96W>088/036/A=001234",
+		},
+		{
+			name: "North",
+			args: args{p: aprsPosition{lat: 47.44304, lon: 19.000968, course: 0, speed: 10, altitude: -10, symbolTable: '\\', symbolCode: 'k'}},
+			want: "!4726.58N\\01900.06Ek360/010/A=-00033",
+		},
+		{
+			name: "Rounding",
+			args: args{p: aprsPosition{lat: 38.9999999, lon: -77.9999999, course: -1, speed: -1, altitude: math.NaN(), symbolTable: '/', symbolCode: '>'}},
+			want: "!3900.00N/07800.00W>",
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			if got := ttt.args.p.encodeUncompressed(); got != ttt.want {
+				t.Errorf("encodeUncompressed() = %v, want %v", got, ttt.want)
+			}
+		})
+	}
+}
+
+func Test_aprsPosition_encodeCompressed(t *testing.T) {
+	type args struct {
+		p aprsPosition
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "Spec example",
+			args: args{p: aprsPosition{lat: 49.5, lon: -72.75, course: 88, speed: 36.2, altitude: math.NaN(), symbolTable: '/', symbolCode: '>'}},
+			want: "!/5L!!<*e7>7P[",
+		},
+		{
+			name: "No motion",
+			args: args{p: aprsPosition{lat: 49.5, lon: -72.75, course: -1, speed: -1, altitude: 100, symbolTable: '/', symbolCode: '>', comment: " gps-qth-qtr"}},
+			want: "!/5L!!<*e7>   /A=000328 gps-qth-qtr",
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			if got := ttt.args.p.encodeCompressed(); got != ttt.want {
+				t.Errorf("encodeCompressed() = %v, want %v", got, ttt.want)
+			}
+		})
+	}
+}
+
+func Test_parseCallsign(t *testing.T) {
+	type args struct {
+		c string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		want1   int
+		wantErr bool
+	}{
+		{name: "No SSID", args: args{c: "n0call"}, want: "N0CALL", want1: 0, wantErr: false},
+		{name: "SSID", args: args{c: "N0CALL-9"}, want: "N0CALL", want1: 9, wantErr: false},
+		{name: "Path", args: args{c: "WIDE2-1"}, want: "WIDE2", want1: 1, wantErr: false},
+		{name: "Too long", args: args{c: "N0CALLS-9"}, want: "", want1: 0, wantErr: true},
+		{name: "Bad SSID", args: args{c: "N0CALL-16"}, want: "", want1: 0, wantErr: true},
+		{name: "Bad character", args: args{c: "TCPIP*"}, want: "", want1: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			got, got1, err := parseCallsign(ttt.args.c)
+			if (err != nil) != ttt.wantErr {
+				t.Errorf("parseCallsign() error = %v, wantErr %v", err, ttt.wantErr)
+				return
+			}
+			if got != ttt.want {
+				t.Errorf("parseCallsign() got = %v, want %v", got, ttt.want)
+			}
+			if got1 != ttt.want1 {
+				t.Errorf("parseCallsign() got1 = %v, want %v", got1, ttt.want1)
+			}
+		})
+	}
+}
+
+func Test_encodeAX25UIFrame(t *testing.T) {
+	got, err := encodeAX25UIFrame("N0CALL-9", "APZQTR", []string{"WIDE1-1"}, "!")
+	if err != nil {
+		t.Fatalf("encodeAX25UIFrame() error = %v", err)
+	}
+
+	want := []byte{
+		'A' << 1, 'P' << 1, 'Z' << 1, 'Q' << 1, 'T' << 1, 'R' << 1, 0xE0,
+		'N' << 1, '0' << 1, 'C' << 1, 'A' << 1, 'L' << 1, 'L' << 1, 0x72,
+		'W' << 1, 'I' << 1, 'D' << 1, 'E' << 1, '1' << 1, ' ' << 1, 0x63,
+		0x03, 0xF0, '!',
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("encodeAX25UIFrame() = %x, want %x", got, want)
+	}
+}
+
+func Test_encodeKISSFrame(t *testing.T) {
+	got := encodeKISSFrame([]byte{0x01, kissFEND, 0x02, kissFESC, 0x03})
+	want := []byte{kissFEND, 0x00, 0x01, kissFESC, kissTFEND, 0x02, kissFESC, kissTFESC, 0x03, kissFEND}
+	if !bytes.Equal(got, want) {
+		t.Errorf("encodeKISSFrame() = %x, want %x", got, want)
+	}
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/tarm/serial"
+)
+
+// aprsConfig is the configuration of APRS position beaconing.
+type aprsConfig struct {
+	Callsign       string
+	Symbol         string
+	Comment        string
+	Compressed     bool
+	Path           []string
+	SmartBeaconing smartBeaconingConfig
+	KISS           struct {
+		Port    string
+		Baud    int
+		Address string
+	}
+	APRSIS struct {
+		Server   string
+		Passcode int
+	}
+}
+
+// smartBeaconingConfig is the configuration of how often to beacon, speeds are in mph and times in seconds
+// ReadRate is how often to read the position between polls, 0 to only beacon after polls.
+type smartBeaconingConfig struct {
+	FastSpeed    float64
+	FastRate     time.Duration
+	SlowSpeed    float64
+	SlowRate     time.Duration
+	MinTurnTime  time.Duration
+	MinTurnAngle float64
+	TurnSlope    float64
+	ReadRate     time.Duration
+}
+
+const (
+	// knots to mph
+	mphPerKnot = 1.150779
+
+	// how long to wait for the TNC or APRS-IS server
+	aprsTimeout = 30 * time.Second
+)
+
+// smartBeacon decides when to beacon based on speed and changes in course.
+type smartBeacon struct {
+	cfg        smartBeaconingConfig
+	last       time.Time
+	lastCourse float64
+}
+
+// newSmartBeacon fills in the defaults for anything not set in cfg.
+func newSmartBeacon(cfg smartBeaconingConfig) *smartBeacon {
+	if cfg.FastSpeed <= 0 {
+		cfg.FastSpeed = 60
+	}
+	if cfg.FastRate <= 0 {
+		cfg.FastRate = 180
+	}
+	if cfg.SlowSpeed <= 0 {
+		cfg.SlowSpeed = 5
+	}
+	if cfg.SlowRate <= 0 {
+		cfg.SlowRate = 1800
+	}
+	if cfg.MinTurnTime <= 0 {
+		cfg.MinTurnTime = 15
+	}
+	if cfg.MinTurnAngle <= 0 {
+		cfg.MinTurnAngle = 28
+	}
+	if cfg.TurnSlope <= 0 {
+		cfg.TurnSlope = 255
+	}
+	cfg.FastRate *= time.Second
+	cfg.SlowRate *= time.Second
+	cfg.MinTurnTime *= time.Second
+	cfg.ReadRate *= time.Second
+
+	return &smartBeacon{cfg: cfg}
+}
+
+// due returns true if it's time to beacon given the speed in knots and course in degrees at now.
+func (sb *smartBeacon) due(now time.Time, speed, course float64) bool {
+	if sb.last.IsZero() {
+		return true
+	}
+	since := now.Sub(sb.last)
+
+	mph := speed * mphPerKnot
+	if mph < sb.cfg.SlowSpeed {
+		return since >= sb.cfg.SlowRate
+	}
+
+	rate := sb.cfg.FastRate
+	if mph < sb.cfg.FastSpeed {
+		rate = time.Duration(float64(sb.cfg.FastRate) * sb.cfg.FastSpeed / mph)
+	}
+	if since >= rate {
+		return true
+	}
+
+	// corner pegging
+	if course >= 0 && since >= sb.cfg.MinTurnTime {
+		change := math.Abs(course - sb.lastCourse)
+		if change > 180 {
+			change = 360 - change
+		}
+		if change > sb.cfg.MinTurnAngle+sb.cfg.TurnSlope/mph {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sent records that a beacon was sent at now with course.
+func (sb *smartBeacon) sent(now time.Time, course float64) {
+	sb.last = now
+	sb.lastCourse = course
+}
+
+// aprsBeacon sends our position to a KISS TNC and/or APRS-IS.
+type aprsBeacon struct {
+	cfg aprsConfig
+	sb  *smartBeacon
+
+	// reads the position between polls, so turns are seen whatever the poll rate is, 0 interval is off
+	read         func() (gpsValues, bool)
+	readInterval time.Duration
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+// newAPRSBeacon validates cfg and returns a beacon ready to start.
+func newAPRSBeacon(cfg aprsConfig) (*aprsBeacon, error) {
+	_, _, err := parseCallsign(cfg.Callsign)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range cfg.Path {
+		_, _, err = parseCallsign(p)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Symbol == "" {
+		cfg.Symbol = "/>"
+	}
+	if len(cfg.Symbol) != 2 {
+		err = fmt.Errorf("APRS symbol must be the table and code characters, like /> for a car")
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	if cfg.KISS.Port == "" && cfg.KISS.Address == "" && cfg.APRSIS.Server == "" {
+		err = fmt.Errorf("APRS needs a KISS port, KISS address or APRS-IS server")
+		log.Printf("%+v", err)
+		return nil, err
+	}
+	if cfg.KISS.Port != "" && cfg.KISS.Address != "" {
+		err = fmt.Errorf("APRS KISS needs either a port or an address, not both")
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	sb := newSmartBeacon(cfg.SmartBeaconing)
+	ab := &aprsBeacon{
+		cfg: cfg,
+		sb:  sb,
+		read:         readMotion,
+		readInterval: sb.cfg.ReadRate,
+		quit:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+
+	return ab, nil
+}
+
+// position returns the position report for v.
+func (ab *aprsBeacon) position(v gpsValues) aprsPosition {
+	return aprsPosition{
+		lat:         v.Latitude,
+		lon:         v.Longitude,
+		course:      v.Course,
+		speed:       v.Speed,
+		altitude:    v.Altitude,
+		symbolTable: ab.cfg.Symbol[0],
+		symbolCode:  ab.cfg.Symbol[1],
+		comment:     ab.cfg.Comment,
+	}
+}
+
+// info returns the information field for p.
+func (ab *aprsBeacon) info(p aprsPosition) string {
+	if ab.cfg.Compressed {
+		return p.encodeCompressed()
+	}
+	return p.encodeUncompressed()
+}
+
+// sendKISS sends the position to the TNC.
+func (ab *aprsBeacon) sendKISS(info string) error {
+	frame, err := encodeAX25UIFrame(ab.cfg.Callsign, aprsToCall, ab.cfg.Path, info)
+	if err != nil {
+		return err
+	}
+	kiss := encodeKISSFrame(frame)
+
+	if ab.cfg.KISS.Port != "" {
+		var p *serial.Port
+		p, err = serial.OpenPort(&serial.Config{
+			Name: ab.cfg.KISS.Port,
+			Baud: ab.cfg.KISS.Baud,
+		})
+		if err != nil {
+			log.Printf("%+v", err)
+			return err
+		}
+		defer p.Close()
+
+		_, err = p.Write(kiss)
+		if err != nil {
+			log.Printf("%+v", err)
+			return err
+		}
+		return nil
+	}
+
+	conn, err := net.DialTimeout("tcp", ab.cfg.KISS.Address, aprsTimeout)
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(aprsTimeout))
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+
+	_, err = conn.Write(kiss)
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+	return nil
+}
+
+// sendAPRSIS logs in to the APRS-IS server and submits the position.
+func (ab *aprsBeacon) sendAPRSIS(info string) error {
+	conn, err := net.DialTimeout("tcp", ab.cfg.APRSIS.Server, aprsTimeout)
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(aprsTimeout))
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+
+	r := bufio.NewReader(conn)
+
+	// server banner
+	_, err = r.ReadString('\n')
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+
+	call := strings.ToUpper(ab.cfg.Callsign)
+	_, err = fmt.Fprintf(conn, "user %s pass %d vers gps-qth-qtr 1.0\r\n", call, ab.cfg.APRSIS.Passcode)
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+
+	// skip comments until the login response
+	for {
+		var l string
+		l, err = r.ReadString('\n')
+		if err != nil {
+			log.Printf("%+v", err)
+			return err
+		}
+
+		if strings.HasPrefix(l, "# logresp ") {
+			if !strings.Contains(l, " verified") || strings.Contains(l, "unverified") {
+				err = fmt.Errorf("APRS-IS login not verified: %s", strings.TrimSpace(l))
+				log.Printf("%+v", err)
+				return err
+			}
+			break
+		}
+	}
+
+	_, err = fmt.Fprintf(conn, "%s\r\n", formatTNC2(call, aprsToCall, []string{"TCPIP*"}, info))
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+	return nil
+}
+
+// update beacons v if it has a fix and SmartBeaconing says it's time.
+func (ab *aprsBeacon) update(v gpsValues, now time.Time) {
+	if v.Status != "" || v.Gridsquare == "" {
+		return
+	}
+
+	if !ab.sb.due(now, v.Speed, v.Course) {
+		return
+	}
+
+	info := ab.info(ab.position(v))
+
+	var sent bool
+	if ab.cfg.KISS.Port != "" || ab.cfg.KISS.Address != "" {
+		if ab.sendKISS(info) == nil {
+			sent = true
+		}
+	}
+	if ab.cfg.APRSIS.Server != "" {
+		if ab.sendAPRSIS(info) == nil {
+			sent = true
+		}
+	}
+
+	if sent {
+		ab.sb.sent(now, v.Course)
+	}
+}
+
+// start beacons after polls in the background until stop is called
+// if there is a read interval it also reads the position itself between polls.
+func (ab *aprsBeacon) start() {
+	updates, unsubscribe := subscribe()
+
+	go func() {
+		defer close(ab.done)
+		defer unsubscribe()
+
+		var reads <-chan time.Time
+		if ab.readInterval > 0 {
+			ticker := time.NewTicker(ab.readInterval)
+			defer ticker.Stop()
+			reads = ticker.C
+		}
+
+		for {
+			select {
+			case u := <-updates:
+				ab.update(u.values, time.Now())
+			case <-reads:
+				v, ok := ab.read()
+				if ok {
+					ab.update(v, time.Now())
+				}
+			case <-ab.quit:
+				return
+			}
+		}
+	}()
+}
+
+// stop waits for the background work to finish.
+func (ab *aprsBeacon) stop() {
+	close(ab.quit)
+	<-ab.done
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_smartBeacon_due(t *testing.T) {
+	start := time.Date(2020, time.Month(1), 18, 20, 0, 0, 0, time.UTC)
+
+	type args struct {
+		since  time.Duration
+		speed  float64
+		course float64
+	}
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{
+		{name: "Parked", args: args{since: 10 * time.Minute, speed: 0, course: -1}, want: false},
+		{name: "Parked long enough", args: args{since: 30 * time.Minute, speed: 0, course: -1}, want: true},
+		{name: "Highway", args: args{since: 2 * time.Minute, speed: 60, course: 90}, want: false},
+		{name: "Highway long enough", args: args{since: 3 * time.Minute, speed: 60, course: 90}, want: true},
+		{name: "Town", args: args{since: 5 * time.Minute, speed: 26, course: 90}, want: false},
+		{name: "Town long enough", args: args{since: 6*time.Minute + 30*time.Second, speed: 26, course: 90}, want: true},
+		{name: "Corner", args: args{since: 20 * time.Second, speed: 26, course: 180}, want: true},
+		{name: "Corner too soon", args: args{since: 10 * time.Second, speed: 26, course: 180}, want: false},
+		{name: "Gentle curve", args: args{since: 20 * time.Second, speed: 26, course: 110}, want: false},
+		{name: "Across north", args: args{since: 20 * time.Second, speed: 26, course: 350}, want: true},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			sb := newSmartBeacon(smartBeaconingConfig{})
+			if !sb.due(start, ttt.args.speed, ttt.args.course) {
+				t.Fatalf("due() first beacon = false, want true")
+			}
+			sb.sent(start, 90)
+
+			if got := sb.due(start.Add(ttt.args.since), ttt.args.speed, ttt.args.course); got != ttt.want {
+				t.Errorf("due() = %v, want %v", got, ttt.want)
+			}
+		})
+	}
+}
+
+// aprsTestServer accepts a single connection and sends everything it reads to got.
+func aprsTestServer(t *testing.T, serve func(conn net.Conn) string) (net.Listener, chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+
+	got := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		got <- serve(conn)
+	}()
+
+	return l, got
+}
+
+func Test_aprsBeacon_update(t *testing.T) {
+	kiss, gotKISS := aprsTestServer(t, func(conn net.Conn) string {
+		b, _ := ioutil.ReadAll(conn)
+		return string(b)
+	})
+	defer kiss.Close()
+
+	aprsis, gotAPRSIS := aprsTestServer(t, func(conn net.Conn) string {
+		fmt.Fprintf(conn, "# aprsc 2.1.4-g408ed49\r\n")
+
+		r := bufio.NewReader(conn)
+		login, _ := r.ReadString('\n')
+		fmt.Fprintf(conn, "# logresp N0CALL-9 verified, server T2TEST\r\n")
+		packet, _ := r.ReadString('\n')
+
+		return login + packet
+	})
+	defer aprsis.Close()
+
+	cfg := aprsConfig{
+		Callsign: "N0CALL-9",
+		Comment:  " mobile",
+		Path:     []string{"WIDE1-1", "WIDE2-1"},
+	}
+	cfg.KISS.Address = kiss.Addr().String()
+	cfg.APRSIS.Server = aprsis.Addr().String()
+	cfg.APRSIS.Passcode = 12345
+
+	ab, err := newAPRSBeacon(cfg)
+	if err != nil {
+		t.Fatalf("newAPRSBeacon() error = %v", err)
+	}
+
+	g := newGPSData()
+	g.setGridsquare("FM18lw")
+	g.setLatitude(38.92)
+	g.setLongitude(-77.065)
+	g.setSpeed(22.4)
+	g.setCourse(54.7)
+	g.setAltitude(10)
+
+	ab.update(g.values(), time.Now())
+
+	info := "!3855.20N/07703.90W>055/022/A=000033 mobile"
+
+	frame, err := encodeAX25UIFrame("N0CALL-9", aprsToCall, cfg.Path, info)
+	if err != nil {
+		t.Fatalf("encodeAX25UIFrame() error = %v", err)
+	}
+	want := string(encodeKISSFrame(frame))
+	if got := <-gotKISS; got != want {
+		t.Errorf("KISS = %x, want %x", got, want)
+	}
+
+	want = "user N0CALL-9 pass 12345 vers gps-qth-qtr 1.0\r\nN0CALL-9>APZQTR,TCPIP*:" + info + "\r\n"
+	if got := <-gotAPRSIS; got != want {
+		t.Errorf("APRS-IS = %q, want %q", got, want)
+	}
+
+	if ab.sb.last.IsZero() {
+		t.Errorf("update() beacon not recorded as sent")
+	}
+}
+
+func Test_aprsBeacon_start(t *testing.T) {
+	kiss, gotKISS := aprsTestServer(t, func(conn net.Conn) string {
+		b, _ := ioutil.ReadAll(conn)
+		return string(b)
+	})
+	defer kiss.Close()
+
+	cfg := aprsConfig{Callsign: "N0CALL-9"}
+	cfg.KISS.Address = kiss.Addr().String()
+
+	ab, err := newAPRSBeacon(cfg)
+	if err != nil {
+		t.Fatalf("newAPRSBeacon() error = %v", err)
+	}
+	if ab.readInterval != 0 {
+		t.Errorf("newAPRSBeacon() readInterval = %v, want off by default", ab.readInterval)
+	}
+
+	g := newGPSData()
+	g.setGridsquare("FM18lw")
+	g.setLatitude(38.92)
+	g.setLongitude(-77.065)
+
+	// the beacon reads the position itself, without waiting for or causing a poll
+	updates, unsubscribe := subscribe()
+	defer unsubscribe()
+	ab.readInterval = 10 * time.Millisecond
+	ab.read = func() (gpsValues, bool) {
+		return g.values(), true
+	}
+	ab.start()
+	defer ab.stop()
+
+	select {
+	case got := <-gotKISS:
+		if !strings.Contains(got, "!3855.20N/07703.90W>") {
+			t.Errorf("KISS = %q, want the position read", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("start() didn't beacon after reading the position")
+	}
+
+	select {
+	case u := <-updates:
+		t.Errorf("start() published %+v after reading the position", u.values)
+	default:
+	}
+}
+
+func Test_newAPRSBeacon(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  aprsConfig
+		want string
+	}{
+		{name: "No output", cfg: aprsConfig{Callsign: "N0CALL"}, want: "needs a KISS port"},
+		{name: "Bad callsign", cfg: aprsConfig{Callsign: "N0CALL-99"}, want: "invalid SSID"},
+		{name: "Bad symbol", cfg: aprsConfig{Callsign: "N0CALL", Symbol: ">"}, want: "symbol"},
+		{name: "Bad path", cfg: aprsConfig{Callsign: "N0CALL", Path: []string{"WIDE2-2*"}}, want: "invalid"},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			_, err := newAPRSBeacon(ttt.cfg)
+			if err == nil || !strings.Contains(err.Error(), ttt.want) {
+				t.Errorf("newAPRSBeacon() error = %v, want %v", err, ttt.want)
+			}
+		})
+	}
+}
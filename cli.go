@@ -0,0 +1,428 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tarm/serial"
+)
+
+// how long to wait for the running gps-qth-qtr to answer
+const apiClientTimeout = 5 * time.Second
+
+// printStatus writes g to w the same way the status window shows it, with the values lined up.
+func printStatus(w io.Writer, g *gpsData, now time.Time) {
+	rows := statusRows(g, now)
+
+	width := 0
+	for _, r := range rows {
+		if len(r.Name) > width {
+			width = len(r.Name)
+		}
+	}
+
+	for _, r := range rows {
+		fmt.Fprintf(w, "%-*s %s\n", width+1, r.Name+":", r.Value)
+	}
+}
+
+// printJSON writes v to w as indented JSON.
+func printJSON(w io.Writer, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", b)
+	if err != nil {
+		log.Printf("%+v", err)
+	}
+	return err
+}
+
+// secondsToDuration converts seconds in JSON back to a duration.
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(math.Round(s * float64(time.Second)))
+}
+
+// newGPSDataFromAPI converts the JSON representation of gps data back.
+func newGPSDataFromAPI(a apiStatus) *gpsData {
+	g := newGPSData()
+
+	if a.Status != "OK" {
+		g.setStatus(a.Status)
+	}
+	if a.Time != nil {
+		g.setTime(*a.Time)
+	}
+	if a.Gridsquare != nil {
+		g.setGridsquare(*a.Gridsquare)
+	}
+	if a.Latitude != nil {
+		g.setLatitude(*a.Latitude)
+	}
+	if a.Longitude != nil {
+		g.setLongitude(*a.Longitude)
+	}
+	if a.FixQuality != nil {
+		g.setFixQuality(*a.FixQuality)
+	}
+	if a.NumSatellites != nil {
+		g.setNumSatellites(*a.NumSatellites)
+	}
+	if a.HDOP != nil {
+		g.setHDOP(*a.HDOP)
+	}
+	if a.Altitude != nil {
+		g.setAltitude(*a.Altitude)
+	}
+	if a.Speed != nil {
+		g.setSpeed(*a.Speed)
+	}
+	if a.Course != nil {
+		g.setCourse(*a.Course)
+	}
+	if a.PDOP != nil {
+		g.setPDOP(*a.PDOP)
+	}
+	if a.VDOP != nil {
+		g.setVDOP(*a.VDOP)
+	}
+	if a.FixMode != nil {
+		g.setFixMode(*a.FixMode)
+	}
+	if a.ClockOffset != nil {
+		g.setClockOffset(secondsToDuration(*a.ClockOffset))
+	}
+	if a.LastAttempt != nil {
+		g.setAttempted(*a.LastAttempt)
+	}
+	if a.Synced != nil && a.SyncOffset != nil {
+		g.setSynced(*a.Synced, secondsToDuration(*a.SyncOffset))
+	}
+	g.setStale(a.Stale)
+	if len(a.Satellites) > 0 {
+		sats := make([]satellite, len(a.Satellites))
+		for i, s := range a.Satellites {
+			sats[i] = s.satellite()
+		}
+		g.setSatellites(sats)
+	}
+
+	return g
+}
+
+// fetchStatus gets the gps data from the local api of the running gps-qth-qtr at address.
+func fetchStatus(address string) (apiStatus, error) {
+	var a apiStatus
+
+	client := &http.Client{Timeout: apiClientTimeout}
+	resp, err := client.Get("http://" + address + "/status")
+	if err != nil {
+		log.Printf("%+v", err)
+		return a, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("gps-qth-qtr api returned %s", resp.Status)
+		log.Printf("%+v", err)
+		return a, err
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(&a)
+	if err != nil {
+		log.Printf("%+v", err)
+		return a, err
+	}
+	return a, nil
+}
+
+// pollOnce polls the gps device, giving up after timeout, returns the exit code for how it went.
+func pollOnce(setTime bool, timeout time.Duration) int {
+	done := make(chan bool, 1)
+	go func() {
+		done <- gatherGpsData(setTime)
+	}()
+
+	select {
+	case ok := <-done:
+		if ok {
+			return exitOK
+		}
+	case <-time.After(timeout):
+		log.Printf("no usable data from the gps device after %s", timeout)
+		return exitNoFix
+	}
+
+	rs := history.recent()
+	if len(rs) > 0 && rs[0].Reason == reasonInvalidState {
+		return exitNoFix
+	}
+	return exitFailed
+}
+
+// runOnce is the once command, it polls the gps device, prints the result and exits.
+func runOnce(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("once", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	setTime := fs.Bool("settime", false, "set the system time from the gps device")
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	timeout := fs.Int("timeout", 60, "give up after this many `seconds`")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: gps-qth-qtr once [-settime] [-json] [-timeout seconds]\n\nexits with 0 if there is a fix, 1 if the poll failed, 3 if the receiver has no fix\n\n")
+		fs.PrintDefaults()
+	}
+
+	err := fs.Parse(args)
+	if err != nil || fs.NArg() != 0 {
+		return exitUsage
+	}
+
+	err = loadConfig(options.configPath)
+	if err != nil {
+		return exitFailed
+	}
+
+	code := pollOnce(*setTime, time.Duration(*timeout)*time.Second)
+
+	if *asJSON {
+		err = printJSON(stdout, newAPIStatusAt(gpsdata.values(), time.Now()))
+		if err != nil {
+			return exitFailed
+		}
+	} else {
+		printStatus(stdout, gpsdata, time.Now())
+	}
+
+	return code
+}
+
+// currentStatus returns the gps data from the running gps-qth-qtr, or the saved state if it can't be reached.
+func currentStatus() (*gpsData, error) {
+	err := loadConfig(options.configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.API.Address != "" {
+		a, err := fetchStatus(config.API.Address)
+		if err == nil {
+			return newGPSDataFromAPI(a), nil
+		}
+	}
+
+	// last position saved by the running gps-qth-qtr
+	log.Printf("using the last known position from %s", options.statePath)
+	return loadState(options.statePath)
+}
+
+// runStatus is the status command, it prints the gps data of the running gps-qth-qtr.
+func runStatus(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	asJSON := fs.Bool("json", false, "print the status as JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: gps-qth-qtr status [-json]\n\nasks the running gps-qth-qtr through its api, or shows the last known position\nexits with 0 if there is a current fix, 1 on error, 3 if there isn't\n\n")
+		fs.PrintDefaults()
+	}
+
+	err := fs.Parse(args)
+	if err != nil || fs.NArg() != 0 {
+		return exitUsage
+	}
+
+	g, err := currentStatus()
+	if err != nil {
+		return exitFailed
+	}
+
+	if *asJSON {
+		err = printJSON(stdout, newAPIStatusAt(g.values(), time.Now()))
+		if err != nil {
+			return exitFailed
+		}
+	} else {
+		printStatus(stdout, g, time.Now())
+	}
+
+	if !g.hasCurrentFix() {
+		return exitNoFix
+	}
+	return exitOK
+}
+
+// runGrid is the grid command, it prints the current gridsquare.
+func runGrid(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("grid", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	length := fs.Int("length", 6, "`length` of the gridsquare, 4, 6 or 8")
+	poll := fs.Bool("poll", false, "poll the gps device instead of asking the running gps-qth-qtr")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: gps-qth-qtr grid [-length 4|6|8] [-poll]\n\nexits with 0 if there is a gridsquare, 1 on error, 3 if there isn't\n\n")
+		fs.PrintDefaults()
+	}
+
+	err := fs.Parse(args)
+	if err != nil || fs.NArg() != 0 || (*length != 4 && *length != 6 && *length != 8) {
+		fs.Usage()
+		return exitUsage
+	}
+
+	var g *gpsData
+	if *poll {
+		err = loadConfig(options.configPath)
+		if err != nil {
+			return exitFailed
+		}
+		pollOnce(false, time.Minute)
+		g = gpsdata
+	} else {
+		g, err = currentStatus()
+		if err != nil {
+			return exitFailed
+		}
+	}
+
+	grid := gridsquareOfLength(g.values(), *length)
+	if grid == "" {
+		return exitNoFix
+	}
+
+	fmt.Fprintln(stdout, grid)
+	return exitOK
+}
+
+// formatSentence returns the parsed contents of an NMEA sentence s, without the leading $, to show user.
+func formatSentence(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) < 6 {
+		return s
+	}
+
+	switch s[2:5] {
+	case "RMC":
+		t, l, lat, lon, spd, crs, err := parseRMC(s)
+		if err != nil {
+			return fmt.Sprintf("RMC error: %v", err)
+		}
+
+		r := fmt.Sprintf("RMC %s %s %.6f %.6f", t.Format(time.RFC3339), l, lat, lon)
+		if spd >= 0 {
+			r += fmt.Sprintf(" %.1fkn", spd)
+		}
+		if crs >= 0 {
+			r += fmt.Sprintf(" %.1f°", crs)
+		}
+		return r
+	case "GGA":
+		q, n, h, alt, err := parseGGA(s)
+		if err != nil {
+			return fmt.Sprintf("GGA error: %v", err)
+		}
+		return fmt.Sprintf("GGA %s, %d satellites, HDOP %.1f, altitude %.1fm", q, n, h, alt)
+	case "GSA":
+		m, err := parseGSA(s)
+		if err != nil {
+			return fmt.Sprintf("GSA error: %v", err)
+		}
+		return fmt.Sprintf("GSA mode %d, %d satellites used, PDOP %.1f, HDOP %.1f, VDOP %.1f", m.Mode, len(m.PRNs), m.PDOP, m.HDOP, m.VDOP)
+	case "GSV":
+		m, err := parseGSV(s)
+		if err != nil {
+			return fmt.Sprintf("GSV error: %v", err)
+		}
+
+		r := fmt.Sprintf("GSV %s %d/%d, %d in view", m.System, m.Number, m.Total, m.InView)
+		for _, sat := range m.Satellites {
+			snr := "-"
+			if sat.SNR >= 0 {
+				snr = strconv.Itoa(sat.SNR)
+			}
+			r += fmt.Sprintf(" %d:%s", sat.PRN, snr)
+		}
+		return r
+	}
+
+	return s[2:5] + " " + s
+}
+
+// runMonitor is the monitor command, it prints the sentences from the gps device until interrupted.
+func runMonitor(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("monitor", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	raw := fs.Bool("raw", false, "print the sentences as they are received too")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: gps-qth-qtr monitor [-raw]\n\n")
+		fs.PrintDefaults()
+	}
+
+	err := fs.Parse(args)
+	if err != nil || fs.NArg() != 0 {
+		return exitUsage
+	}
+
+	err = loadConfig(options.configPath)
+	if err != nil {
+		return exitFailed
+	}
+
+	p, err := serial.OpenPort(&serial.Config{
+		Name: config.GPSDevice.Port,
+		Baud: config.GPSDevice.Baud,
+	})
+	if err != nil {
+		log.Printf("%+v", err)
+		return exitFailed
+	}
+	defer p.Close()
+
+	// closing the port stops the loop
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt)
+	defer signal.Stop(interrupted)
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case <-interrupted:
+			close(stopped)
+			p.Close()
+		case <-stopped:
+		}
+	}()
+
+	// the first read starts part way through a sentence
+	_, err = readLineFromPort(p, '$')
+	for err == nil {
+		var s string
+		s, err = readLineFromPort(p, '$')
+		if err != nil {
+			break
+		}
+
+		if *raw {
+			fmt.Fprintf(stdout, "$%s\n", strings.TrimSpace(s))
+		}
+		fmt.Fprintln(stdout, formatSentence(s))
+	}
+
+	select {
+	case <-stopped:
+		return exitOK
+	default:
+		close(stopped)
+		log.Printf("%+v", err)
+		return exitFailed
+	}
+}
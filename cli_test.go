@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_newGPSDataFromAPI(t *testing.T) {
+	g := newGPSData()
+	g.setGridsquare("FM18lw")
+	g.setLatitude(38.92)
+	g.setLongitude(-77.065)
+	g.setFixQuality("GPS fix (SPS)")
+	g.setNumSatellites(7)
+	g.setHDOP(1.1)
+	g.setTime(time.Date(2020, time.Month(1), 18, 2, 2, 2, 0, time.UTC))
+	g.setAttempted(time.Date(2020, time.Month(1), 18, 2, 2, 3, 0, time.UTC))
+	g.setAltitude(102.5)
+	g.setSpeed(12.5)
+	g.setCourse(270)
+	g.setPDOP(1.6)
+	g.setVDOP(1.3)
+	g.setFixMode(3)
+	g.setClockOffset(-250 * time.Millisecond)
+	g.setSynced(time.Date(2020, time.Month(1), 18, 2, 2, 3, 0, time.UTC), -250*time.Millisecond)
+
+	stale := newGPSData()
+	stale.setGridsquare("FM18lw")
+	stale.setTime(time.Date(2020, time.Month(1), 18, 2, 2, 2, 0, time.UTC))
+	stale.setStale(true)
+
+	failed := newGPSData()
+	failed.setStatus("GGA line bad checksum")
+
+	tests := []struct {
+		name string
+		g    *gpsData
+	}{
+		{name: "Fix", g: g},
+		{name: "Stale", g: stale},
+		{name: "Error", g: failed},
+		{name: "Empty", g: newGPSData()},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			b, err := json.Marshal(newAPIStatus(ttt.g.values()))
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+
+			var a apiStatus
+			err = json.Unmarshal(b, &a)
+			if err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+
+			gg := newGPSDataFromAPI(a)
+			got := gg.values()
+			want := ttt.g.values()
+			if got.Status != want.Status || got.Gridsquare != want.Gridsquare || !got.Time.Equal(want.Time) ||
+				got.Latitude != want.Latitude || got.Longitude != want.Longitude || got.FixQuality != want.FixQuality ||
+				got.NumSatellites != want.NumSatellites || got.HDOP != want.HDOP || got.Stale != want.Stale ||
+				!got.Attempted.Equal(want.Attempted) || got.FixMode != want.FixMode || got.ClockOffset != want.ClockOffset ||
+				!got.Synced.Equal(want.Synced) || got.SyncOffset != want.SyncOffset {
+				t.Errorf("newGPSDataFromAPI() = %+v, want %+v", got, want)
+			}
+
+			// altitude isn't known as NaN, so compare what is shown
+			if gg.formatAltitude() != ttt.g.formatAltitude() || gg.formatSpeedCourse() != ttt.g.formatSpeedCourse() || gg.formatPDOPVDOP() != ttt.g.formatPDOPVDOP() {
+				t.Errorf("newGPSDataFromAPI() shows %q %q %q, want %q %q %q",
+					gg.formatAltitude(), gg.formatSpeedCourse(), gg.formatPDOPVDOP(),
+					ttt.g.formatAltitude(), ttt.g.formatSpeedCourse(), ttt.g.formatPDOPVDOP())
+			}
+		})
+	}
+}
+
+func Test_formatSentence(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want string
+	}{
+		{
+			name: "RMC",
+			s:    "GNRMC,020202.00,A,3855.2,N,07703.9,W,0.149,,180120,,,A*7B\r\n",
+			want: "RMC 2020-01-18T02:02:02Z FM18lw 38.920000 -77.065000 0.1kn",
+		},
+		{
+			name: "GGA",
+			s:    "GNGGA,013016.00,7751.3,S,16642.4,E,1,12,0.96,250.6,M,-33.4,M,,*7A",
+			want: "GGA GPS fix (SPS), 12 satellites, HDOP 1.0, altitude 250.6m",
+		},
+		{
+			name: "Bad checksum",
+			s:    "GNGGA,013016.00,7751.3,S,16642.4,E,1,12,0.96,250.6,M,-33.4,M,,*7B",
+			want: "GGA error: ",
+		},
+		{
+			name: "GSV",
+			s:    "GLGSV,1,1,02,71,45,120,35,72,,,,1*4D",
+			want: "GSV GL 1/1, 2 in view 71:35 72:-",
+		},
+		{
+			name: "GSA",
+			s:    "GPGSA,A,3,04,05,,09,12,,,24,,,,,2.5,1.3,2.1*39",
+			want: "GSA mode 3, 5 satellites used, PDOP 2.5, HDOP 1.3, VDOP 2.1",
+		},
+		{
+			name: "Other",
+			s:    "GPVTG,,T,,M,0.149,N,0.276,K,A*2E",
+			want: "VTG GPVTG,,T,,M,0.149,N,0.276,K,A*2E",
+		},
+		{
+			name: "Short",
+			s:    "GP",
+			want: "GP",
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			got := formatSentence(ttt.s)
+			if !strings.HasPrefix(got, ttt.want) || (!strings.HasSuffix(ttt.want, " ") && got != ttt.want) {
+				t.Errorf("formatSentence() = %q, want %q", got, ttt.want)
+			}
+		})
+	}
+}
+
+func Test_printStatus(t *testing.T) {
+	g := newGPSData()
+	g.setGridsquare("FM18lw")
+	g.setTime(time.Date(2020, time.Month(1), 18, 2, 2, 2, 0, time.UTC))
+
+	var b bytes.Buffer
+	printStatus(&b, g, time.Date(2020, time.Month(1), 18, 2, 3, 42, 0, time.UTC))
+
+	for _, want := range []string{"Message:       OK\n", "Gridsquare:    FM18lw\n", "Fix Age:       1m40s ago\n", "\nRecent Polls:  "} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("printStatus() = %q, want it to contain %q", b.String(), want)
+		}
+	}
+}
+
+func Test_runCommand(t *testing.T) {
+	defer log.SetOutput(log.Writer())
+	defer log.SetFlags(log.Flags())
+
+	dir, err := ioutil.TempDir("", "gps-qth-qtr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fix := newGPSData()
+	fix.setGridsquare("FM18lw")
+	fix.setLatitude(38.92)
+	fix.setLongitude(-77.065)
+	fix.setFixQuality("GPS fix (SPS)")
+	fix.setTime(time.Date(2020, time.Month(1), 18, 2, 2, 2, 0, time.UTC))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/status" {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, http.StatusOK, newAPIStatus(fix.values()))
+	}))
+	defer srv.Close()
+
+	running := filepath.Join(dir, "running.yaml")
+	err = ioutil.WriteFile(running, []byte("api:\n  address: "+strings.TrimPrefix(srv.URL, "http://")+"\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing answers, so the saved state is used
+	stopped := filepath.Join(dir, "stopped.yaml")
+	err = ioutil.WriteFile(stopped, []byte("api:\n  address: 127.0.0.1:1\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad := filepath.Join(dir, "bad.yaml")
+	err = ioutil.WriteFile(bad, []byte("gpsdevice:\n  baud: 12\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = saveState(filepath.Join(dir, "stopped.state"), fix.values(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		want     string
+		wantCode int
+	}{
+		{
+			name:     "Help",
+			args:     []string{"help"},
+			want:     "usage: gps-qth-qtr",
+			wantCode: exitOK,
+		},
+		{
+			name:     "Unknown",
+			args:     []string{"-log", "-", "bogus"},
+			wantCode: exitUsage,
+		},
+		{
+			name:     "Missing config",
+			args:     []string{"-config", filepath.Join(dir, "missing.yaml"), "-log", "-", "status"},
+			wantCode: exitFailed,
+		},
+		{
+			name:     "Config check",
+			args:     []string{"-config", running, "-log", "-", "config", "check"},
+			want:     "running.yaml: no problems\n",
+			wantCode: exitOK,
+		},
+		{
+			name:     "Config check problems",
+			args:     []string{"-config", bad, "-log", "-", "config", "check"},
+			want:     "bad.yaml:2: gpsdevice.baud must be between 300 and 921600\n",
+			wantCode: exitFailed,
+		},
+		{
+			name:     "Config without check",
+			args:     []string{"-config", running, "-log", "-", "config"},
+			wantCode: exitUsage,
+		},
+		{
+			name:     "Status",
+			args:     []string{"-config", running, "-log", "-", "status"},
+			want:     "Gridsquare:    FM18lw\n",
+			wantCode: exitOK,
+		},
+		{
+			name:     "Status JSON",
+			args:     []string{"-config", running, "-log", "-", "status", "-json"},
+			want:     `"gridsquare": "FM18lw"`,
+			wantCode: exitOK,
+		},
+		{
+			name:     "Status from state",
+			args:     []string{"-config", stopped, "-log", filepath.Join(dir, "stopped.log"), "status"},
+			want:     "Stale, waiting for a fix",
+			wantCode: exitNoFix,
+		},
+		{
+			name:     "Grid",
+			args:     []string{"-config", running, "-log", "-", "grid", "-length", "4"},
+			want:     "FM18\n",
+			wantCode: exitOK,
+		},
+		{
+			name:     "Grid bad length",
+			args:     []string{"-config", running, "-log", "-", "grid", "-length", "5"},
+			wantCode: exitUsage,
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			code := runCommand(ttt.args, &stdout, ioutil.Discard)
+			if code != ttt.wantCode {
+				t.Errorf("runCommand() = %d, want %d", code, ttt.wantCode)
+			}
+			if !strings.Contains(stdout.String(), ttt.want) {
+				t.Errorf("runCommand() output = %q, want it to contain %q", stdout.String(), ttt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+// +build !windows
+
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+var (
+	// commands that copy their input to the clipboard, tried in order until one works.
+	clipboardCommands = [][]string{
+		{"wl-copy"},
+		{"xclip", "-selection", "clipboard"},
+		{"xsel", "--clipboard", "--input"},
+		{"pbcopy"},
+	}
+
+	// no clipboard command worked.
+	errNoClipboard = errors.New("no clipboard, install wl-clipboard, xclip or xsel")
+)
+
+// copyToClipboard puts s on the clipboard with the first clipboard command that is installed and works.
+func copyToClipboard(s string) error {
+	for _, c := range clipboardCommands {
+		path, err := exec.LookPath(c[0])
+		if err != nil {
+			continue
+		}
+
+		// #nosec G204
+		cmd := exec.Command(path, c[1:]...)
+		cmd.Stdin = strings.NewReader(s)
+
+		// wl-copy and xclip leave a child running that holds the selection, it must not hold a pipe of ours
+		// or we'd wait for it to exit, so what they say goes straight to our stderr
+		cmd.Stderr = os.Stderr
+
+		err = cmd.Run()
+		if err != nil {
+			// wl-copy fails without wayland, xclip without X
+			log.Printf("%s: %v", c[0], err)
+			continue
+		}
+		return nil
+	}
+
+	log.Printf("%+v", errNoClipboard)
+	return errNoClipboard
+}
@@ -0,0 +1,64 @@
+// +build !windows
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_copyToClipboard(t *testing.T) {
+	dir, err := ioutil.TempDir("", "clipboard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	prev := clipboardCommands
+	defer func() {
+		clipboardCommands = prev
+	}()
+
+	fn := filepath.Join(dir, "clipboard")
+	tests := []struct {
+		name     string
+		commands [][]string
+		wantErr  bool
+	}{
+		{name: "Installed", commands: [][]string{{"sh", "-c", "cat > " + fn}}},
+		{name: "Falls back", commands: [][]string{{"no-such-clipboard"}, {"sh", "-c", "exit 1"}, {"sh", "-c", "cat > " + fn}}},
+		{name: "None", commands: [][]string{{"no-such-clipboard"}, {"sh", "-c", "exit 1"}}, wantErr: true},
+		{name: "Leaves a child running", commands: [][]string{{"sh", "-c", "cat > " + fn + "; sleep 1 &"}}},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			os.Remove(fn)
+			clipboardCommands = ttt.commands
+
+			start := time.Now()
+			err := copyToClipboard("FM18lw")
+			if (err != nil) != ttt.wantErr {
+				t.Fatalf("copyToClipboard() error = %v, wantErr %v", err, ttt.wantErr)
+			}
+			if d := time.Since(start); d > 500*time.Millisecond {
+				t.Errorf("copyToClipboard() took %v, it waited for the child", d)
+			}
+			if ttt.wantErr {
+				return
+			}
+
+			b, err := ioutil.ReadFile(fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != "FM18lw" {
+				t.Errorf("clipboard = %q, want FM18lw", b)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// cloudlogConfig is the configuration of the Cloudlog / Wavelog integration.
+type cloudlogConfig struct {
+	URL         string
+	APIKey      string
+	StationID   int
+	StationPath string
+	Radio       string
+	RadioPath   string
+	GridLength  int
+	Timeout     time.Duration
+}
+
+// cloudlogStation is the body of the station location update.
+type cloudlogStation struct {
+	Key        string  `json:"key"`
+	StationID  int     `json:"station_id"`
+	Gridsquare string  `json:"gridsquare"`
+	Latitude   float64 `json:"latitude"`
+	Longitude  float64 `json:"longitude"`
+}
+
+// cloudlogRadio is the body of the radio update.
+type cloudlogRadio struct {
+	Key        string `json:"key"`
+	Radio      string `json:"radio"`
+	Gridsquare string `json:"gridsquare"`
+	Timestamp  string `json:"timestamp"`
+}
+
+// cloudlogIntegration keeps the station location in Cloudlog up to date.
+type cloudlogIntegration struct {
+	cfg    cloudlogConfig
+	client *http.Client
+	grid   string
+	quit   chan struct{}
+	done   chan struct{}
+}
+
+// newCloudlogIntegration validates cfg and returns an integration ready to start.
+func newCloudlogIntegration(cfg cloudlogConfig) (*cloudlogIntegration, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		err = fmt.Errorf("Cloudlog url must be http or https")
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	if cfg.APIKey == "" {
+		err = fmt.Errorf("Cloudlog needs an apikey")
+		log.Printf("%+v", err)
+		return nil, err
+	}
+	if cfg.StationID <= 0 {
+		err = fmt.Errorf("Cloudlog needs a stationid")
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	switch cfg.GridLength {
+	case 0:
+		cfg.GridLength = 6
+	case 4, 6, 8:
+	default:
+		err = fmt.Errorf("Cloudlog grid length must be 4, 6 or 8")
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	if cfg.StationPath == "" {
+		cfg.StationPath = "/index.php/api/station_location"
+	}
+	if cfg.RadioPath == "" {
+		cfg.RadioPath = "/index.php/api/radio"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30
+	}
+	cfg.Timeout *= time.Second
+	cfg.URL = strings.TrimSuffix(cfg.URL, "/")
+
+	return &cloudlogIntegration{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// post sends v as JSON to the api at path.
+func (ci *cloudlogIntegration) post(path string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+
+	resp, err := ci.client.Post(ci.cfg.URL+path, "application/json", bytes.NewReader(b))
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	// drain so the connection can be reused
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err = fmt.Errorf("Cloudlog %s returned %s", path, resp.Status)
+		log.Printf("%+v", err)
+		return err
+	}
+	return nil
+}
+
+// update sends the location for v to Cloudlog if the grid hasn't already been sent.
+func (ci *cloudlogIntegration) update(v gpsValues) {
+	// don't send the last known grid from before we started
+	if v.Stale {
+		return
+	}
+
+	grid := gridsquareOfLength(v, ci.cfg.GridLength)
+	if grid == "" || grid == ci.grid {
+		return
+	}
+
+	err := ci.post(ci.cfg.StationPath, cloudlogStation{
+		Key:        ci.cfg.APIKey,
+		StationID:  ci.cfg.StationID,
+		Gridsquare: grid,
+		Latitude:   v.Latitude,
+		Longitude:  v.Longitude,
+	})
+	if err != nil {
+		return
+	}
+
+	if ci.cfg.Radio != "" {
+		err = ci.post(ci.cfg.RadioPath, cloudlogRadio{
+			Key:        ci.cfg.APIKey,
+			Radio:      ci.cfg.Radio,
+			Gridsquare: grid,
+			Timestamp:  time.Now().UTC().Format("2006/01/02 15:04"),
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	ci.grid = grid
+	log.Printf("Cloudlog station %d gridsquare set to %s", ci.cfg.StationID, grid)
+}
+
+// start keeps Cloudlog up to date in the background until stop is called
+// failures are retried after every poll.
+func (ci *cloudlogIntegration) start() {
+	updates, unsubscribe := subscribe()
+
+	go func() {
+		defer close(ci.done)
+		defer unsubscribe()
+
+		ci.update(gpsdata.values())
+		for {
+			select {
+			case u := <-updates:
+				ci.update(u.values)
+			case <-ci.quit:
+				return
+			}
+		}
+	}()
+}
+
+// stop waits for the background work to finish.
+func (ci *cloudlogIntegration) stop() {
+	close(ci.quit)
+	<-ci.done
+}
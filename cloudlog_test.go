@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_cloudlogIntegration_update(t *testing.T) {
+	var stations []cloudlogStation
+	var radios []cloudlogRadio
+	fail := true
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/index.php/api/station_location":
+			// fail the first update
+			if fail {
+				fail = false
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			var s cloudlogStation
+			if json.NewDecoder(r.Body).Decode(&s) != nil || s.Key != "cl5e1234" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			stations = append(stations, s)
+		case "/index.php/api/radio":
+			var rd cloudlogRadio
+			if json.NewDecoder(r.Body).Decode(&rd) != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			radios = append(radios, rd)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	ci, err := newCloudlogIntegration(cloudlogConfig{URL: ts.URL + "/", APIKey: "cl5e1234", StationID: 2, Radio: "gps-qth-qtr"})
+	if err != nil {
+		t.Fatalf("newCloudlogIntegration() error = %v", err)
+	}
+
+	g := newGPSData()
+	g.setGridsquare("FM18lw")
+	g.setLatitude(38.92)
+	g.setLongitude(-77.065)
+
+	// failure, retry, then nothing to do
+	ci.update(g.values())
+	ci.update(g.values())
+	ci.update(g.values())
+
+	if len(stations) != 1 {
+		t.Fatalf("update() stations = %v, want 1", len(stations))
+	}
+	want := cloudlogStation{Key: "cl5e1234", StationID: 2, Gridsquare: "FM18lw", Latitude: 38.92, Longitude: -77.065}
+	if stations[0] != want {
+		t.Errorf("update() station = %+v, want %+v", stations[0], want)
+	}
+	if len(radios) != 1 || radios[0].Radio != "gps-qth-qtr" || radios[0].Gridsquare != "FM18lw" {
+		t.Errorf("update() radios = %+v", radios)
+	}
+
+	// moved
+	g.setGridsquare("FM18lx")
+	ci.update(g.values())
+	if len(stations) != 2 || stations[1].Gridsquare != "FM18lx" {
+		t.Errorf("update() stations = %+v", stations)
+	}
+}
+
+func Test_newCloudlogIntegration(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  cloudlogConfig
+	}{
+		{name: "Bad url", cfg: cloudlogConfig{URL: "log.example.com", APIKey: "cl5e1234", StationID: 1}},
+		{name: "No key", cfg: cloudlogConfig{URL: "https://log.example.com", StationID: 1}},
+		{name: "No station", cfg: cloudlogConfig{URL: "https://log.example.com", APIKey: "cl5e1234"}},
+		{name: "Bad grid length", cfg: cloudlogConfig{URL: "https://log.example.com", APIKey: "cl5e1234", StationID: 1, GridLength: 10}},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			_, err := newCloudlogIntegration(ttt.cfg)
+			if err == nil {
+				t.Errorf("newCloudlogIntegration() error = nil, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// command is run from the command line, run is the default.
+type command struct {
+	run   func(args []string, stdout, stderr io.Writer) int
+	usage string
+}
+
+// exit codes of the commands.
+const (
+	exitOK     = 0
+	exitFailed = 1
+	exitUsage  = 2
+	exitNoFix  = 3
+)
+
+var (
+	// commands by name.
+	commands = map[string]command{
+		"config":    {run: runConfig, usage: "check the configuration file and report every problem with it"},
+		"dashboard": {run: runDashboard, usage: "show the gps data full screen in the terminal, updating live"},
+		"export":    {run: runExport, usage: "write GPX tracks and the gridsquares visited as KML or GeoJSON"},
+		"grid":      {run: runGrid, usage: "print the current gridsquare"},
+		"monitor":   {run: runMonitor, usage: "print the sentences from the gps device as they are parsed"},
+		"once":      {run: runOnce, usage: "poll the gps device, optionally set the time, print the result and exit"},
+		"run":       {run: runRun, usage: "poll the gps device and run in the system tray (the default)"},
+		"stamp":     {run: runStamp, usage: "write MY_GRIDSQUARE, MY_LAT and MY_LON into an ADIF file from a GPX track"},
+		"status":    {run: runStatus, usage: "print the status of the running gps-qth-qtr"},
+		"unit":      {run: runUnit, usage: "print a systemd service unit for running gps-qth-qtr headless on Linux"},
+	}
+
+	// global command line options.
+	options struct {
+		configPath string
+		logPath    string
+		statePath  string
+	}
+)
+
+// printCommands lists the commands on w.
+func printCommands(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for n := range commands {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(w, "usage: gps-qth-qtr [-config file] [-log file] [-state file] [command [arguments]]\n\ncommands:\n")
+	for _, n := range names {
+		fmt.Fprintf(w, "  %-10s %s\n", n, commands[n].usage)
+	}
+	fmt.Fprintf(w, "\noptions:\n")
+}
+
+// runCommand parses the global options and runs the command named in args, returns the exit code.
+func runCommand(args []string, stdout, stderr io.Writer) int {
+	// log, config & state files default to the same directory as the executable with the same base name
+	fn, err := os.Executable()
+	if err != nil {
+		log.Printf("%+v", err)
+		return exitFailed
+	}
+	basefn := strings.TrimSuffix(fn, filepath.Ext(fn))
+
+	fs := flag.NewFlagSet("gps-qth-qtr", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&options.configPath, "config", basefn+".yaml", "configuration `file`")
+	fs.StringVar(&options.logPath, "log", "", "log `file`, - for standard error (default "+basefn+".log for run, standard error for the other commands)")
+	fs.StringVar(&options.statePath, "state", "", "`file` the last known position is kept in (default next to the log file)")
+	fs.Usage = func() {
+		printCommands(fs.Output())
+		fs.PrintDefaults()
+	}
+
+	err = fs.Parse(args)
+	if err != nil {
+		return exitUsage
+	}
+
+	name := "run"
+	if fs.NArg() > 0 {
+		name = fs.Arg(0)
+	}
+	if name == "help" {
+		fs.SetOutput(stdout)
+		fs.Usage()
+		return exitOK
+	}
+
+	c, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %s\n", name)
+		fs.Usage()
+		return exitUsage
+	}
+
+	if options.logPath == "" {
+		options.logPath = "-"
+		if name == "run" {
+			options.logPath = basefn + ".log"
+		}
+	}
+
+	// state is kept beside the log unless told otherwise
+	statePath := basefn + ".state"
+	if options.logPath == "-" {
+		log.SetOutput(stderr)
+		if name != "run" {
+			log.SetFlags(0)
+		}
+	} else {
+		f, err := os.OpenFile(options.logPath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return exitFailed
+		}
+		defer f.Close()
+		log.SetOutput(f)
+
+		statePath = strings.TrimSuffix(options.logPath, filepath.Ext(options.logPath)) + ".state"
+	}
+	if options.statePath == "" {
+		options.statePath = statePath
+	}
+
+	if fs.NArg() == 0 {
+		return c.run(nil, stdout, stderr)
+	}
+	return c.run(fs.Args()[1:], stdout, stderr)
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	// a key at the start of a line, after any list item dashes.
+	configKeyLine = regexp.MustCompile(`^( *)((?:- +)*)([A-Za-z0-9_]+) *:(?:\s|$)`)
+
+	// errors from yaml with the line they are on.
+	configErrorLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+	// yaml error for a key that isn't in the configuration.
+	configUnknownKey = regexp.MustCompile(`^field \S+ not found in type`)
+
+	// yaml error for a value of the wrong type.
+	configBadValue = regexp.MustCompile("^cannot unmarshal !!\\w+ (?:`(.*)` )?into (.+)$")
+
+	// serial ports, COM3 on windows and /dev/ttyUSB0 elsewhere.
+	configPortName = regexp.MustCompile(`(?i)^((\\\\\.\\)?COM[1-9][0-9]*|/.+)$`)
+)
+
+// configProblem is something wrong with the configuration file.
+type configProblem struct {
+	// dotted path of the key, like gpsdevice.baud, empty if it isn't known
+	Key string
+	// 0 if the key isn't in the file
+	Line    int
+	Message string
+}
+
+// format returns the problem in file fn the way compilers report them, so editors can jump to it.
+func (p configProblem) format(fn string) string {
+	var b strings.Builder
+
+	b.WriteString(fn)
+	if p.Line > 0 {
+		fmt.Fprintf(&b, ":%d", p.Line)
+	}
+	b.WriteString(": ")
+	if p.Key != "" {
+		b.WriteString(p.Key + " ")
+	}
+	b.WriteString(p.Message)
+
+	return b.String()
+}
+
+// configError is every problem with the configuration file.
+type configError struct {
+	File     string
+	Problems []configProblem
+}
+
+// Error returns the problems on one line.
+func (e *configError) Error() string {
+	s := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		s[i] = p.format(e.File)
+	}
+	return strings.Join(s, "; ")
+}
+
+// configKeys returns the dotted path of the key on each line of the yaml in b
+// it follows block mappings by indentation, the keys of list items are under the key of the list.
+func configKeys(b []byte) map[int]string {
+	type level struct {
+		indent int
+		key    string
+	}
+
+	keys := make(map[int]string)
+	var levels []level
+	for i, line := range strings.Split(string(b), "\n") {
+		m := configKeyLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		indent := len(m[1]) + len(m[2])
+		for len(levels) > 0 && levels[len(levels)-1].indent >= indent {
+			levels = levels[:len(levels)-1]
+		}
+
+		key := strings.ToLower(m[3])
+		if len(levels) > 0 {
+			key = levels[len(levels)-1].key + "." + key
+		}
+		levels = append(levels, level{indent: indent, key: key})
+		keys[i+1] = key
+	}
+
+	return keys
+}
+
+// lineOf returns the first line key is on, 0 if it isn't in keys.
+func lineOf(keys map[int]string, key string) int {
+	line := 0
+	for l, k := range keys {
+		if k == key && (line == 0 || l < line) {
+			line = l
+		}
+	}
+	return line
+}
+
+// describeValueError returns what is wrong with a value yaml couldn't decode into typ.
+func describeValueError(value, typ string) string {
+	var want string
+	switch {
+	case typ == "int" || typ == "float64" || typ == "time.Duration":
+		want = "must be a number"
+	case typ == "bool":
+		want = "must be true or false"
+	case typ == "string":
+		want = "must be text"
+	case strings.HasPrefix(typ, "[]"):
+		want = "must be a list"
+	default:
+		want = "must be a section"
+	}
+
+	if value == "" {
+		return want
+	}
+	return fmt.Sprintf("%s, not %s", want, value)
+}
+
+// decodeConfig returns the configuration in the yaml b, with the defaults for what it leaves out, and every problem with it.
+func decodeConfig(b []byte) (configuration, []configProblem) {
+	cfg := newConfiguration()
+	keys := configKeys(b)
+
+	var problems []configProblem
+	err := yaml.UnmarshalStrict(b, &cfg)
+	if err != nil {
+		errs := []string{err.Error()}
+		te, ok := err.(*yaml.TypeError)
+		if ok {
+			errs = te.Errors
+		}
+
+		for _, e := range errs {
+			p := configProblem{Message: e}
+
+			m := configErrorLine.FindStringSubmatch(e)
+			if m != nil {
+				p.Line, _ = strconv.Atoi(m[1])
+				p.Message = m[2]
+			}
+
+			// syntax errors are about the yaml, not a key
+			if ok {
+				p.Key = keys[p.Line]
+				if configUnknownKey.MatchString(p.Message) {
+					p.Message = "is not a setting"
+				} else if m := configBadValue.FindStringSubmatch(p.Message); m != nil {
+					p.Message = describeValueError(m[1], m[2])
+				}
+			}
+
+			problems = append(problems, p)
+		}
+
+		// nothing else can be trusted if the yaml itself is broken
+		if !ok {
+			return cfg, problems
+		}
+	}
+
+	for _, p := range checkConfig(cfg) {
+		p.Line = lineOf(keys, p.Key)
+		problems = append(problems, p)
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Line < problems[j].Line
+	})
+	return cfg, problems
+}
+
+// isHostPort returns true if address is a host and a port that can be used.
+func isHostPort(address string) bool {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+
+	n, err := strconv.ParseUint(port, 10, 16)
+	return err == nil && n > 0
+}
+
+// isGridLength returns true if n is one of lengths, or 0 for the default.
+func isGridLength(n int, lengths ...int) bool {
+	if n == 0 {
+		return true
+	}
+	for _, l := range lengths {
+		if n == l {
+			return true
+		}
+	}
+	return false
+}
+
+// checkConfig returns the values in cfg that are out of range, it doesn't know the lines they are on.
+func checkConfig(cfg configuration) []configProblem {
+	var problems []configProblem
+	problem := func(key, format string, a ...interface{}) {
+		problems = append(problems, configProblem{Key: key, Message: fmt.Sprintf(format, a...)})
+	}
+
+	// gps device
+	if cfg.GPSDevice.Port != "" && !configPortName.MatchString(cfg.GPSDevice.Port) {
+		problem("gpsdevice.port", "must be a serial port like COM3 or /dev/ttyUSB0")
+	}
+	if cfg.GPSDevice.Baud < minBaud || cfg.GPSDevice.Baud > maxBaud {
+		problem("gpsdevice.baud", "must be between %d and %d", minBaud, maxBaud)
+	}
+	if cfg.GPSDevice.PollRate < minPollRate || cfg.GPSDevice.PollRate > maxPollRate {
+		problem("gpsdevice.pollrate", "must be between %d and %d seconds", minPollRate, maxPollRate)
+	}
+	if cfg.GPSDevice.MaxHDOP <= 0 || cfg.GPSDevice.MaxHDOP > maxMaxHDOP {
+		problem("gpsdevice.maxhdop", "must be more than 0 and at most %d", maxMaxHDOP)
+	}
+	if cfg.History.Size < 0 {
+		problem("history.size", "can't be negative")
+	}
+
+	// integrations
+	for _, a := range []struct {
+		key     string
+		address string
+	}{
+		{key: "api.address", address: cfg.API.Address},
+		{key: "wsjtx.address", address: cfg.WSJTX.Address},
+		{key: "js8call.address", address: cfg.JS8Call.Address},
+		{key: "udpbroadcast.address", address: cfg.UDPBroadcast.Address},
+	} {
+		if a.address != "" && !isHostPort(a.address) {
+			problem(a.key, "must be a host and port like 127.0.0.1:2237")
+		}
+	}
+	if cfg.MQTT.Broker != "" {
+		u, err := url.Parse(cfg.MQTT.Broker)
+		if err != nil || u.Host == "" {
+			problem("mqtt.broker", "must be a URL like tcp://broker.example.com:1883")
+		}
+	}
+	if cfg.MQTT.KeepAlive < 0 {
+		problem("mqtt.keepalive", "can't be negative")
+	}
+	if !isGridLength(cfg.WSJTX.GridLength, 4, 6) {
+		problem("wsjtx.gridlength", "must be 4 or 6")
+	}
+	if !isGridLength(cfg.JS8Call.GridLength, 4, 6, 8) {
+		problem("js8call.gridlength", "must be 4, 6 or 8")
+	}
+	if !isGridLength(cfg.Cloudlog.GridLength, 4, 6, 8) {
+		problem("cloudlog.gridlength", "must be 4, 6 or 8")
+	}
+	if cfg.UDPBroadcast.Interval < 0 {
+		problem("udpbroadcast.interval", "can't be negative")
+	}
+	if cfg.Track.MinDistance < 0 {
+		problem("track.mindistance", "can't be negative")
+	}
+	if cfg.Track.MinInterval < 0 {
+		problem("track.mininterval", "can't be negative")
+	}
+
+	// aprs thresholds
+	sb := cfg.APRS.SmartBeaconing
+	for _, v := range []struct {
+		key   string
+		value float64
+	}{
+		{key: "aprs.smartbeaconing.fastspeed", value: sb.FastSpeed},
+		{key: "aprs.smartbeaconing.fastrate", value: float64(sb.FastRate)},
+		{key: "aprs.smartbeaconing.slowspeed", value: sb.SlowSpeed},
+		{key: "aprs.smartbeaconing.slowrate", value: float64(sb.SlowRate)},
+		{key: "aprs.smartbeaconing.minturntime", value: float64(sb.MinTurnTime)},
+		{key: "aprs.smartbeaconing.turnslope", value: sb.TurnSlope},
+	} {
+		if v.value < 0 {
+			problem(v.key, "can't be negative")
+		}
+	}
+	if sb.MinTurnAngle < 0 || sb.MinTurnAngle > 180 {
+		problem("aprs.smartbeaconing.minturnangle", "must be between 0 and 180 degrees")
+	}
+
+	return problems
+}
+
+// runConfig is the config command, config check reports every problem with the configuration file at once.
+func runConfig(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: gps-qth-qtr config check\n\nexits with 0 if the configuration file has no problems, 1 if it has\n")
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 || fs.Arg(0) != "check" {
+		fs.Usage()
+		return exitUsage
+	}
+
+	// #nosec G304
+	b, err := ioutil.ReadFile(options.configPath)
+	if err != nil {
+		fmt.Fprintf(stdout, "%v\n", err)
+		return exitFailed
+	}
+
+	cfg, problems := decodeConfig(b)
+
+	// only worth knowing when checking, the gps device can be plugged in after starting
+	if cfg.GPSDevice.Port != "" && configPortName.MatchString(cfg.GPSDevice.Port) && !portConnected(cfg.GPSDevice.Port) {
+		problems = append(problems, configProblem{
+			Key:     "gpsdevice.port",
+			Line:    lineOf(configKeys(b), "gpsdevice.port"),
+			Message: cfg.GPSDevice.Port + " isn't connected",
+		})
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Line < problems[j].Line
+	})
+	for _, p := range problems {
+		fmt.Fprintf(stdout, "%s\n", p.format(options.configPath))
+	}
+	if len(problems) > 0 {
+		return exitFailed
+	}
+
+	fmt.Fprintf(stdout, "%s: no problems\n", options.configPath)
+	return exitOK
+}
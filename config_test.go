@@ -0,0 +1,137 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_decodeConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want []string
+	}{
+		{
+			name: "Defaults",
+			yaml: "api:\n  address: 127.0.0.1:8080\n",
+		},
+		{
+			name: "Good",
+			yaml: `gpsdevice:
+  port: COM3
+  baud: 4800
+  pollrate: 60
+  maxhdop: 2.5
+hooks:
+  - event: fix
+    command: ["echo", "{{.Gridsquare}}"]
+wsjtx:
+  address: 127.0.0.1:2237
+  gridlength: 4
+`,
+		},
+		{
+			name: "Unknown keys",
+			yaml: `gpsdevice:
+  port: COM3
+  prt: COM4
+hooks:
+  - event: fix
+    timout: 5
+`,
+			want: []string{
+				"gps-qth-qtr.yaml:3: gpsdevice.prt is not a setting",
+				"gps-qth-qtr.yaml:6: hooks.timout is not a setting",
+			},
+		},
+		{
+			name: "Wrong types",
+			yaml: "gpsdevice:\n  pollrate: fast\nmqtt:\n  insecureskipverify: maybe\n",
+			want: []string{
+				"gps-qth-qtr.yaml:2: gpsdevice.pollrate must be a number, not fast",
+				"gps-qth-qtr.yaml:4: mqtt.insecureskipverify must be true or false, not maybe",
+			},
+		},
+		{
+			name: "Out of range",
+			yaml: "wsjtx:\n  gridlength: 5\ngpsdevice:\n  baud: 12\n  pollrate: 0\n",
+			want: []string{
+				"gps-qth-qtr.yaml:2: wsjtx.gridlength must be 4 or 6",
+				"gps-qth-qtr.yaml:4: gpsdevice.baud must be between 300 and 921600",
+				"gps-qth-qtr.yaml:5: gpsdevice.pollrate must be between 10 and 86400 seconds",
+			},
+		},
+		{
+			name: "Addresses",
+			yaml: "gpsdevice:\n  port: ttyUSB0\napi:\n  address: localhost\nmqtt:\n  broker: broker.example.com\n",
+			want: []string{
+				"gps-qth-qtr.yaml:2: gpsdevice.port must be a serial port like COM3 or /dev/ttyUSB0",
+				"gps-qth-qtr.yaml:4: api.address must be a host and port like 127.0.0.1:2237",
+				"gps-qth-qtr.yaml:6: mqtt.broker must be a URL like tcp://broker.example.com:1883",
+			},
+		},
+		{
+			name: "Syntax",
+			yaml: "gpsdevice:\n  port: COM3\n baud: 4800\n",
+			want: []string{
+				"gps-qth-qtr.yaml:2: did not find expected key",
+			},
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+		t.Run(ttt.name, func(t *testing.T) {
+			_, problems := decodeConfig([]byte(ttt.yaml))
+
+			var got []string
+			for _, p := range problems {
+				got = append(got, p.format("gps-qth-qtr.yaml"))
+			}
+			if !reflect.DeepEqual(got, ttt.want) {
+				t.Errorf("decodeConfig() problems = %q, want %q", got, ttt.want)
+			}
+		})
+	}
+}
+
+func Test_decodeConfig_defaults(t *testing.T) {
+	cfg, problems := decodeConfig([]byte("gpsdevice:\n  port: COM3\n"))
+	if len(problems) != 0 {
+		t.Fatalf("decodeConfig() problems = %v", problems)
+	}
+
+	if cfg.GPSDevice.Port != "COM3" || cfg.GPSDevice.Baud != 9600 || cfg.GPSDevice.PollRate != 900 || cfg.GPSDevice.MaxHDOP != defaultMaxHDOP {
+		t.Errorf("decodeConfig() gpsdevice = %+v, want the defaults", cfg.GPSDevice)
+	}
+}
+
+func Test_configKeys(t *testing.T) {
+	got := configKeys([]byte(`# comment
+gpsdevice:
+  port: COM3
+
+hooks:
+  - event: fix
+    command:
+      - echo
+  - event: lost
+aprs:
+  smartbeaconing:
+    fastspeed: 60
+`))
+
+	want := map[int]string{
+		2:  "gpsdevice",
+		3:  "gpsdevice.port",
+		5:  "hooks",
+		6:  "hooks.event",
+		7:  "hooks.command",
+		9:  "hooks.event",
+		10: "aprs",
+		11: "aprs.smartbeaconing",
+		12: "aprs.smartbeaconing.fastspeed",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("configKeys() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// configWatchInterval is how often the configuration file is checked for changes.
+const configWatchInterval = 2 * time.Second
+
+// fileStamp is enough about a file to tell it changed.
+type fileStamp struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// stampOf returns the stamp of the file fn, it doesn't exist if it can't be read.
+func stampOf(fn string) fileStamp {
+	fi, err := os.Stat(fn)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{exists: true, size: fi.Size(), modTime: fi.ModTime()}
+}
+
+// configWatcher calls reload when the configuration file changes
+// it isn't one of the services, reloading restarts them.
+type configWatcher struct {
+	fn       string
+	interval time.Duration
+	reload   func() error
+	quit     chan struct{}
+	done     chan struct{}
+}
+
+// newConfigWatcher returns a watcher that checks fn every interval.
+func newConfigWatcher(fn string, interval time.Duration, reload func() error) *configWatcher {
+	return &configWatcher{
+		fn:       fn,
+		interval: interval,
+		reload:   reload,
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// start watches the file in the background.
+func (w *configWatcher) start() {
+	last := stampOf(w.fn)
+
+	go func() {
+		defer close(w.done)
+
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		// editors can take more than one write to save, so wait until the file stops changing
+		pending := last
+		for {
+			select {
+			case <-ticker.C:
+				st := stampOf(w.fn)
+				if st != pending {
+					pending = st
+					continue
+				}
+				if st == last || !st.exists {
+					continue
+				}
+
+				// bad edits are logged by reload and not tried again until the file changes
+				last = st
+				_ = w.reload()
+			case <-w.quit:
+				return
+			}
+		}
+	}()
+}
+
+// stop stops watching, a reload in progress finishes first.
+func (w *configWatcher) stop() {
+	close(w.quit)
+	<-w.done
+}
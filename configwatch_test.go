@@ -0,0 +1,173 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_configWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gps-qth-qtr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fn := filepath.Join(dir, "gps-qth-qtr.yaml")
+	err = ioutil.WriteFile(fn, []byte("gpsdevice:\n  port: COM3\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloads := make(chan struct{}, 4)
+	w := newConfigWatcher(fn, 10*time.Millisecond, func() error {
+		reloads <- struct{}{}
+		return nil
+	})
+	w.start()
+	defer w.stop()
+
+	// the sizes differ so the change shows even if the modification time doesn't
+	for i, step := range []struct {
+		name   string
+		change func() error
+		want   bool
+	}{
+		{
+			name:   "Unchanged",
+			change: func() error { return nil },
+		},
+		{
+			name:   "Edited",
+			change: func() error { return ioutil.WriteFile(fn, []byte("gpsdevice:\n  port: COM10\n"), 0600) },
+			want:   true,
+		},
+		{
+			name:   "Removed",
+			change: func() error { return os.Remove(fn) },
+		},
+		{
+			name:   "Created",
+			change: func() error { return ioutil.WriteFile(fn, []byte("gpsdevice:\n  port: COM100\n"), 0600) },
+			want:   true,
+		},
+	} {
+		err := step.change()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case <-reloads:
+			if !step.want {
+				t.Errorf("step %d %s reloaded", i, step.name)
+			}
+		case <-time.After(200 * time.Millisecond):
+			if step.want {
+				t.Errorf("step %d %s didn't reload", i, step.name)
+			}
+		}
+	}
+}
+
+func Test_reloadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gps-qth-qtr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	prev := options.configPath
+	defer func() {
+		options.configPath = prev
+	}()
+	options.configPath = filepath.Join(dir, "gps-qth-qtr.yaml")
+
+	write := func(s string) {
+		err := ioutil.WriteFile(options.configPath, []byte(s), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("gpsdevice:\n  port: /dev/null\n  pollrate: 900\n")
+	err = loadConfig(options.configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = startRunning()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stopRunning()
+
+	// unchanged
+	err = reloadConfig()
+	if err != nil {
+		t.Errorf("reloadConfig() unchanged error = %v", err)
+	}
+
+	// changed
+	write("gpsdevice:\n  port: /dev/null\n  pollrate: 60\n")
+	err = reloadConfig()
+	if err != nil {
+		t.Errorf("reloadConfig() error = %v", err)
+	}
+	if got := currentConfig().GPSDevice.PollRate; got != 60 {
+		t.Errorf("reloadConfig() pollrate = %d, want 60", got)
+	}
+
+	// only what changed is restarted
+	runningService := func(name string) service {
+		runningMu.Lock()
+		defer runningMu.Unlock()
+
+		return running.running[name]
+	}
+	p := runningService("gpsdevice")
+	write("gpsdevice:\n  port: /dev/null\n  pollrate: 60\nhistory:\n  size: 5\n")
+	err = reloadConfig()
+	if err != nil {
+		t.Errorf("reloadConfig() history error = %v", err)
+	}
+	if got := runningService("gpsdevice"); got != p {
+		t.Errorf("reloadConfig() history restarted the poller")
+	}
+	history.mu.RLock()
+	size := len(history.records)
+	history.mu.RUnlock()
+	if size != 5 {
+		t.Errorf("reloadConfig() history size = %d, want 5", size)
+	}
+
+	// bad edits keep the running configuration
+	write("gpsdevice:\n  port: /dev/null\n  pollrate: 1\nhistory:\n  size: 5\n")
+	err = reloadConfig()
+	if err == nil {
+		t.Errorf("reloadConfig() out of range error = nil")
+	}
+	if got := currentConfig().GPSDevice.PollRate; got != 60 {
+		t.Errorf("reloadConfig() out of range pollrate = %d, want 60", got)
+	}
+
+	// and so do ones that can't be started
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	write("gpsdevice:\n  port: /dev/null\n  pollrate: 30\nhistory:\n  size: 5\napi:\n  address: " + l.Addr().String() + "\n")
+	err = reloadConfig()
+	if err == nil {
+		t.Errorf("reloadConfig() address in use error = nil")
+	}
+	if got := currentConfig(); got.GPSDevice.PollRate != 60 || got.API.Address != "" {
+		t.Errorf("reloadConfig() address in use config = %+v, want the previous one", got)
+	}
+	if got := runningService("gpsdevice"); got == nil || got == p {
+		t.Errorf("reloadConfig() address in use poller = %v, want the previous configuration's restarted", got)
+	}
+}
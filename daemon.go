@@ -0,0 +1,64 @@
+// +build !windows
+
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// runDaemon runs until SIGINT, SIGTERM or exit is closed, reloading the configuration on SIGHUP
+// keeps systemd up to date when it started us, with problem until the configuration is reloaded if we couldn't start the way we were configured to.
+func runDaemon(exit <-chan struct{}, problem string) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	updates, unsubscribe := subscribe()
+	defer unsubscribe()
+
+	var watchdog <-chan time.Time
+	if d := sdWatchdogInterval(); d > 0 {
+		ticker := time.NewTicker(d)
+		defer ticker.Stop()
+		watchdog = ticker.C
+	}
+
+	status := formatDaemonStatus(gpsdata.values())
+	if problem != "" {
+		// systemd shows the status on one line
+		status = strings.Replace(problem, "\n", " ", -1)
+	}
+	_ = sdNotify("READY=1\nSTATUS=" + status)
+
+	for {
+		select {
+		case sig := <-signals:
+			if sig != syscall.SIGHUP {
+				log.Printf("stopping on %s", sig)
+				_ = sdNotify("STOPPING=1")
+				return nil
+			}
+
+			_ = sdNotify("RELOADING=1")
+			status := formatDaemonStatus(gpsdata.values())
+			err := reloadConfig()
+			if err != nil {
+				status = "Configuration not reloaded: " + err.Error()
+			}
+			_ = sdNotify("READY=1\nSTATUS=" + status)
+		case u := <-updates:
+			_ = sdNotify("STATUS=" + formatDaemonStatus(u.values))
+		case <-watchdog:
+			_ = sdNotify("WATCHDOG=1")
+		case <-exit:
+			log.Printf("stopping on exit")
+			_ = sdNotify("STOPPING=1")
+			return nil
+		}
+	}
+}
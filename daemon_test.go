@@ -0,0 +1,98 @@
+// +build !windows
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func Test_runDaemon(t *testing.T) {
+	defer saveEnv("NOTIFY_SOCKET", "WATCHDOG_USEC", "WATCHDOG_PID")()
+	os.Unsetenv("WATCHDOG_USEC")
+	os.Unsetenv("WATCHDOG_PID")
+
+	dir, err := ioutil.TempDir("", "daemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conn := listenNotify(t, dir)
+	defer conn.Close()
+
+	prev := options.configPath
+	defer func() {
+		options.configPath = prev
+	}()
+	options.configPath = filepath.Join(dir, "gps-qth-qtr.yaml")
+	err = ioutil.WriteFile(options.configPath, []byte("gpsdevice:\n  port: /dev/null\n  baud: 9600\n  pollrate: 900\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stopRunning()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- runDaemon(nil, "")
+	}()
+
+	if got := readNotify(t, conn); !strings.HasPrefix(got, "READY=1\nSTATUS=") {
+		t.Fatalf("runDaemon() sent %q first, want READY", got)
+	}
+
+	// reload
+	err = syscall.Kill(os.Getpid(), syscall.SIGHUP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readNotify(t, conn); got != "RELOADING=1" {
+		t.Errorf("runDaemon() sent %q on SIGHUP, want RELOADING=1", got)
+	}
+	if got := readNotify(t, conn); !strings.HasPrefix(got, "READY=1\nSTATUS=") || strings.Contains(got, "not reloaded") {
+		t.Errorf("runDaemon() sent %q after reload, want READY", got)
+	}
+	if config.GPSDevice.Port != "/dev/null" {
+		t.Errorf("config.GPSDevice.Port = %q after reload, want /dev/null", config.GPSDevice.Port)
+	}
+
+	// bad configuration is reported and the old one kept
+	err = ioutil.WriteFile(options.configPath, []byte("gpsdevice: [\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = syscall.Kill(os.Getpid(), syscall.SIGHUP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readNotify(t, conn)
+	if got := readNotify(t, conn); !strings.Contains(got, "STATUS=Configuration not reloaded") {
+		t.Errorf("runDaemon() sent %q after a bad reload, want the error", got)
+	}
+	if config.GPSDevice.Port != "/dev/null" {
+		t.Errorf("config.GPSDevice.Port = %q after a bad reload, want /dev/null", config.GPSDevice.Port)
+	}
+
+	// stop
+	err = syscall.Kill(os.Getpid(), syscall.SIGTERM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readNotify(t, conn); got != "STOPPING=1" {
+		t.Errorf("runDaemon() sent %q on SIGTERM, want STOPPING=1", got)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("runDaemon() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("runDaemon() didn't return on SIGTERM")
+	}
+}
@@ -0,0 +1,587 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// how many recent events the dashboard keeps
+	dashboardEvents = 50
+
+	// SNR that fills the whole bar, anything above is good
+	dashboardMaxSNR = 50
+
+	// how long to wait before reconnecting to the running gps-qth-qtr
+	dashboardReconnect = 5 * time.Second
+
+	// how long an update of the running gps-qth-qtr can take
+	dashboardUpdateTimeout = 2 * time.Minute
+)
+
+// dashboardUpdate is what a dashboard source sends, the gps data after a poll with the event it is for,
+// or an error.
+type dashboardUpdate struct {
+	event  string
+	status apiStatus
+	err    error
+}
+
+// dashboardSource is where the dashboard gets its gps data from.
+type dashboardSource interface {
+	updates() <-chan dashboardUpdate
+	update() error
+	close()
+}
+
+// dashboardEvent is a line in the recent events pane.
+type dashboardEvent struct {
+	time time.Time
+	text string
+}
+
+// dashboard is what the dashboard command shows.
+type dashboard struct {
+	source string
+	status *gpsData
+	// newest first
+	events []dashboardEvent
+}
+
+// newDashboard returns an empty dashboard for the gps data from source.
+func newDashboard(source string) *dashboard {
+	return &dashboard{
+		source: source,
+		status: newGPSData(),
+	}
+}
+
+// addEvent adds text to the recent events, dropping the oldest.
+func (d *dashboard) addEvent(t time.Time, text string) {
+	d.events = append([]dashboardEvent{{time: t, text: text}}, d.events...)
+	if len(d.events) > dashboardEvents {
+		d.events = d.events[:dashboardEvents]
+	}
+}
+
+// apply shows u.
+func (d *dashboard) apply(u dashboardUpdate, now time.Time) {
+	if u.err != nil {
+		d.addEvent(now, u.err.Error())
+		return
+	}
+
+	g := newGPSDataFromAPI(u.status)
+	switch gpsEvent(u.event) {
+	case eventGridChanged:
+		d.addEvent(now, "Gridsquare changed to "+g.formatGridsquare())
+	case eventFixLost:
+		d.addEvent(now, "Fix lost")
+	case eventFixAcquired:
+		d.addEvent(now, "Fix acquired, "+g.formatFixQuality())
+	case eventClockStepped:
+		d.addEvent(now, "System time set")
+	default:
+		// polls that fail don't have an event of their own
+		if u.status.Status != "OK" && u.status.Status != d.status.getStatus() {
+			d.addEvent(now, u.status.Status)
+		}
+	}
+	d.status = g
+}
+
+// truncate cuts s to width characters.
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	if width <= 0 {
+		return ""
+	}
+	return string([]rune(s)[:width])
+}
+
+// formatSNRBar returns a bar for sat that is width characters at dashboardMaxSNR.
+func formatSNRBar(sat satellite, width int) string {
+	label := fmt.Sprintf("%-2s %3d ", sat.System, sat.PRN)
+	if sat.SNR < 0 {
+		return label + "-"
+	}
+
+	// a terminal too narrow for the label has no room for the bar
+	if width < 0 {
+		width = 0
+	}
+
+	n := sat.SNR
+	if n > dashboardMaxSNR {
+		n = dashboardMaxSNR
+	}
+	n = n * width / dashboardMaxSNR
+
+	return label + strings.Repeat("█", n) + fmt.Sprintf(" %d", sat.SNR)
+}
+
+// render draws d on a width x height terminal, from the top left corner.
+func (d *dashboard) render(w io.Writer, width, height int, now time.Time) error {
+	var lines []string
+
+	title := "gps-qth-qtr " + d.source
+	clock := now.Format("15:04:05")
+	pad := width - utf8.RuneCountInString(title) - len(clock)
+	if pad < 1 {
+		pad = 1
+	}
+	lines = append(lines, title+strings.Repeat(" ", pad)+clock, "")
+
+	var status bytes.Buffer
+	printStatus(&status, d.status, now)
+	lines = append(lines, strings.Split(strings.TrimSuffix(status.String(), "\n"), "\n")...)
+
+	// best signals first so the ones that don't fit are the least interesting
+	sats := d.status.getSatellites()
+	sort.SliceStable(sats, func(i, j int) bool {
+		return sats[i].SNR > sats[j].SNR
+	})
+
+	// what's left after the headings and the key help is shared by the satellites and the events
+	rows := height - len(lines) - 5
+	if rows < 2 {
+		rows = 2
+	}
+	satRows := len(sats)
+	if satRows == 0 {
+		satRows = 1
+	}
+	eventRows := len(d.events)
+	if eventRows == 0 {
+		eventRows = 1
+	}
+	if satRows+eventRows > rows {
+		// events get at least a third
+		eventRows = min(eventRows, max(rows/3, rows-satRows))
+		satRows = rows - eventRows
+	}
+
+	lines = append(lines, "", "Satellites in view (SNR dB-Hz)")
+	switch {
+	case len(sats) == 0:
+		lines = append(lines, "none")
+	case len(sats) <= satRows:
+		for _, s := range sats {
+			lines = append(lines, formatSNRBar(s, width-11))
+		}
+	default:
+		for _, s := range sats[:satRows-1] {
+			lines = append(lines, formatSNRBar(s, width-11))
+		}
+		lines = append(lines, fmt.Sprintf("... %d more", len(sats)-satRows+1))
+	}
+
+	lines = append(lines, "", "Recent events")
+	if len(d.events) == 0 {
+		lines = append(lines, "none")
+	}
+	for i := 0; i < len(d.events) && i < eventRows; i++ {
+		lines = append(lines, d.events[i].time.Format("15:04:05")+" "+d.events[i].text)
+	}
+
+	// key help goes on the last line
+	if len(lines) > height-1 {
+		lines = lines[:max(height-1, 0)]
+	}
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, "u update  c copy gridsquare  q quit")
+
+	// overwrite in place so the screen doesn't flicker
+	var b bytes.Buffer
+	b.WriteString("\x1b[H")
+	for i, l := range lines {
+		b.WriteString(truncate(l, width))
+		b.WriteString("\x1b[K")
+		if i < len(lines)-1 {
+			b.WriteString("\n")
+		}
+	}
+	b.WriteString("\x1b[J")
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// min returns the smaller of a and b.
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// max returns the larger of a and b.
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// osc52 returns the escape sequence that has the terminal put s on the clipboard, works over ssh.
+func osc52(s string) string {
+	return "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(s)) + "\a"
+}
+
+// runDashboardLoop shows d on out until q or Ctrl+C is read from keys, size returns the size of the terminal.
+func runDashboardLoop(d *dashboard, src dashboardSource, keys <-chan byte, out io.Writer, size func() (int, int)) error {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	// errors from updates asked for
+	errs := make(chan error, 1)
+
+	for {
+		width, height := size()
+		err := d.render(out, width, height, time.Now())
+		if err != nil {
+			log.Printf("%+v", err)
+			return err
+		}
+
+		select {
+		case u, ok := <-src.updates():
+			if !ok {
+				return nil
+			}
+			d.apply(u, time.Now())
+		case k, ok := <-keys:
+			if !ok {
+				return nil
+			}
+
+			switch k {
+			case 'q', 'Q', 3:
+				return nil
+			case 'u', 'U', ' ':
+				d.addEvent(time.Now(), "Update requested")
+				go func() {
+					err := src.update()
+					if err != nil {
+						select {
+						case errs <- err:
+						default:
+						}
+					}
+				}()
+			case 'c', 'C':
+				grid := d.status.getGridsquare()
+				if grid == "" {
+					d.addEvent(time.Now(), "No gridsquare to copy")
+					break
+				}
+				_, err = io.WriteString(out, osc52(grid))
+				if err != nil {
+					log.Printf("%+v", err)
+					return err
+				}
+				d.addEvent(time.Now(), "Copied "+grid+" to the clipboard")
+			}
+		case err := <-errs:
+			d.addEvent(time.Now(), "Update failed: "+err.Error())
+		case <-ticker.C:
+			// ages and the clock move on
+		}
+	}
+}
+
+// apiDashboardSource gets the gps data from the local api of the running gps-qth-qtr.
+type apiDashboardSource struct {
+	address string
+	c       chan dashboardUpdate
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// newAPIDashboardSource returns a source streaming the gps data from the running gps-qth-qtr at address.
+func newAPIDashboardSource(address string) *apiDashboardSource {
+	ctx, cancel := context.WithCancel(context.Background())
+	a := &apiDashboardSource{
+		address: address,
+		c:       make(chan dashboardUpdate, 8),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+
+	go func() {
+		for {
+			err := a.stream()
+			if a.ctx.Err() != nil {
+				return
+			}
+			a.send(dashboardUpdate{err: fmt.Errorf("lost gps-qth-qtr at %s: %v", address, err)})
+
+			select {
+			case <-time.After(dashboardReconnect):
+			case <-a.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return a
+}
+
+// send passes u on unless the source has been closed.
+func (a *apiDashboardSource) send(u dashboardUpdate) {
+	select {
+	case a.c <- u:
+	case <-a.ctx.Done():
+	}
+}
+
+// stream reads the Server-Sent Events of the api until the connection is lost.
+func (a *apiDashboardSource) stream() error {
+	req, err := http.NewRequest(http.MethodGet, "http://"+a.address+"/events", nil)
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(a.ctx))
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("gps-qth-qtr api returned %s", resp.Status)
+		log.Printf("%+v", err)
+		return err
+	}
+
+	return readEvents(resp.Body, a.send)
+}
+
+// readEvents calls f with each Server-Sent Event from r until it ends.
+func readEvents(r io.Reader, f func(dashboardUpdate)) error {
+	var u dashboardUpdate
+	var data string
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		l := s.Text()
+		switch {
+		case strings.HasPrefix(l, "event:"):
+			u.event = strings.TrimSpace(strings.TrimPrefix(l, "event:"))
+		case strings.HasPrefix(l, "data:"):
+			data += strings.TrimSpace(strings.TrimPrefix(l, "data:"))
+		case l == "":
+			if data != "" {
+				err := json.Unmarshal([]byte(data), &u.status)
+				if err != nil {
+					log.Printf("%+v", err)
+					return err
+				}
+				f(u)
+			}
+			u = dashboardUpdate{}
+			data = ""
+		}
+	}
+
+	err := s.Err()
+	if err != nil {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
+
+// updates returns the channel the gps data is sent on.
+func (a *apiDashboardSource) updates() <-chan dashboardUpdate {
+	return a.c
+}
+
+// update asks the running gps-qth-qtr to poll the gps device now, the result comes as an event.
+func (a *apiDashboardSource) update() error {
+	client := &http.Client{Timeout: dashboardUpdateTimeout}
+	resp, err := client.Post("http://"+a.address+"/update", "application/json", nil)
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	// a poll that fails is still an update
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		err = fmt.Errorf("gps-qth-qtr api returned %s", resp.Status)
+		log.Printf("%+v", err)
+		return err
+	}
+	return nil
+}
+
+// close stops streaming.
+func (a *apiDashboardSource) close() {
+	a.cancel()
+}
+
+// localDashboardSource polls the gps device itself, without setting the system time.
+type localDashboardSource struct {
+	c           chan dashboardUpdate
+	quit        chan struct{}
+	unsubscribe func()
+}
+
+// newLocalDashboardSource returns a source polling the gps device now and then every rate.
+func newLocalDashboardSource(rate time.Duration) *localDashboardSource {
+	updates, unsubscribe := subscribe()
+	l := &localDashboardSource{
+		c:           make(chan dashboardUpdate, 8),
+		quit:        make(chan struct{}),
+		unsubscribe: unsubscribe,
+	}
+	l.c <- dashboardUpdate{event: "status", status: newAPIStatusAt(gpsdata.values(), time.Now())}
+
+	go func() {
+		ticker := time.NewTicker(rate)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case u, ok := <-updates:
+				if !ok {
+					return
+				}
+
+				status := newAPIStatusAt(u.values, time.Now())
+				for _, e := range append([]gpsEvent{"status"}, u.events...) {
+					select {
+					case l.c <- dashboardUpdate{event: string(e), status: status}:
+					case <-l.quit:
+						return
+					}
+				}
+			case <-ticker.C:
+				go gatherGpsData(false)
+			case <-l.quit:
+				return
+			}
+		}
+	}()
+	go gatherGpsData(false)
+
+	return l
+}
+
+// updates returns the channel the gps data is sent on.
+func (l *localDashboardSource) updates() <-chan dashboardUpdate {
+	return l.c
+}
+
+// update polls the gps device now, unless a poll is already in progress.
+func (l *localDashboardSource) update() error {
+	go gatherGpsData(false)
+	return nil
+}
+
+// close stops polling, a poll in progress still finishes.
+func (l *localDashboardSource) close() {
+	close(l.quit)
+	l.unsubscribe()
+}
+
+// runDashboard is the dashboard command, it shows the gps data full screen until q is pressed.
+func runDashboard(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("dashboard", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	poll := fs.Bool("poll", false, "poll the gps device instead of asking the running gps-qth-qtr")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: gps-qth-qtr dashboard [-poll]\n\nkeys: u or space updates now, c copies the gridsquare to the clipboard of the terminal, q quits\n\n")
+		fs.PrintDefaults()
+	}
+
+	err := fs.Parse(args)
+	if err != nil || fs.NArg() != 0 {
+		return exitUsage
+	}
+
+	err = loadConfig(options.configPath)
+	if err != nil {
+		return exitFailed
+	}
+
+	// anything logged would scroll the dashboard
+	if options.logPath == "-" {
+		log.SetOutput(ioutil.Discard)
+		defer log.SetOutput(stderr)
+	}
+
+	var d *dashboard
+	var src dashboardSource
+	if config.API.Address != "" && !*poll {
+		_, err = fetchStatus(config.API.Address)
+		if err == nil {
+			d = newDashboard("at " + config.API.Address)
+			src = newAPIDashboardSource(config.API.Address)
+		}
+	}
+	if src == nil {
+		d = newDashboard("on " + config.GPSDevice.Port)
+		src = newLocalDashboardSource(config.GPSDevice.PollRate * time.Second)
+	}
+	defer src.close()
+
+	restore, err := makeRaw(os.Stdin, os.Stdout)
+	if err != nil {
+		fmt.Fprintf(stderr, "dashboard needs a terminal: %v\n", err)
+		return exitFailed
+	}
+	defer restore()
+
+	// alternate screen without a cursor, put back on the way out
+	fmt.Fprint(stdout, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(stdout, "\x1b[?25h\x1b[?1049l")
+
+	keys := make(chan byte)
+	go func() {
+		defer close(keys)
+
+		b := make([]byte, 1)
+		for {
+			_, err := os.Stdin.Read(b)
+			if err != nil {
+				return
+			}
+			keys <- b[0]
+		}
+	}()
+
+	size := func() (int, int) {
+		w, h, err := terminalSize(os.Stdout)
+		if err != nil || w <= 0 || h <= 0 {
+			return 80, 24
+		}
+		return w, h
+	}
+
+	err = runDashboardLoop(d, src, keys, stdout, size)
+	if err != nil {
+		return exitFailed
+	}
+	return exitOK
+}
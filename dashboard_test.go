@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_dashboard_render(t *testing.T) {
+	now := time.Date(2020, time.Month(1), 18, 2, 3, 42, 0, time.UTC)
+
+	g := newGPSData()
+	g.setGridsquare("FM18lw")
+	g.setSatellites([]satellite{
+		{System: "GP", PRN: 5, Elevation: 10, Azimuth: 20, SNR: -1},
+		{System: "GP", PRN: 9, Elevation: 10, Azimuth: 20, SNR: 50},
+		{System: "GL", PRN: 70, Elevation: 10, Azimuth: 20, SNR: 25},
+	})
+
+	tests := []struct {
+		name     string
+		width    int
+		height   int
+		events   int
+		want     []string
+		dontWant []string
+	}{
+		{
+			name:   "Everything fits",
+			width:  61,
+			height: 38,
+			events: 2,
+			want: []string{
+				"gps-qth-qtr at localhost:8080" + strings.Repeat(" ", 24) + "02:03:42\x1b[K\n",
+				"Gridsquare:    FM18lw\x1b[K\n",
+				"GP   9 " + strings.Repeat("█", 50) + " 50\x1b[K\n",
+				"GL  70 " + strings.Repeat("█", 25) + " 25\x1b[K\n",
+				"GP   5 -\x1b[K\n",
+				"02:03:42 event 1\x1b[K\n",
+				"02:03:42 event 0\x1b[K\n",
+				"u update  c copy gridsquare  q quit\x1b[K\x1b[J",
+			},
+		},
+		{
+			name:   "Too many",
+			width:  61,
+			height: 28,
+			events: 10,
+			want: []string{
+				"GP   9 ",
+				"... 2 more\x1b[K\n",
+				"02:03:42 event 9\x1b[K\n",
+				"u update  c copy gridsquare  q quit\x1b[K\x1b[J",
+			},
+			dontWant: []string{
+				"GL  70 ",
+				"event 7",
+			},
+		},
+		{
+			name:   "Narrow",
+			width:  8,
+			height: 38,
+			events: 2,
+			want: []string{
+				"GP   9  \x1b[K\n",
+				"GL  70  \x1b[K\n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+		t.Run(ttt.name, func(t *testing.T) {
+			d := newDashboard("at localhost:8080")
+			d.status = g
+			for i := 0; i < ttt.events; i++ {
+				d.addEvent(now, "event "+string(rune('0'+i)))
+			}
+
+			var b bytes.Buffer
+			err := d.render(&b, ttt.width, ttt.height, now)
+			if err != nil {
+				t.Fatalf("render() error = %v", err)
+			}
+
+			got := b.String()
+			if strings.Count(got, "\n") != ttt.height-1 {
+				t.Errorf("render() drew %d lines, want %d", strings.Count(got, "\n")+1, ttt.height)
+			}
+			for _, want := range ttt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("render() = %q, want %q", got, want)
+				}
+			}
+			for _, dontWant := range ttt.dontWant {
+				if strings.Contains(got, dontWant) {
+					t.Errorf("render() = %q, don't want %q", got, dontWant)
+				}
+			}
+		})
+	}
+}
+
+func Test_dashboard_apply(t *testing.T) {
+	grid := "FM18lw"
+	quality := "GPS fix (SPS)"
+	ok := apiStatus{Status: "OK", Gridsquare: &grid, FixQuality: &quality}
+	failed := apiStatus{Status: "Read timeout", Gridsquare: &grid, Stale: true}
+
+	tests := []struct {
+		name    string
+		updates []dashboardUpdate
+		want    []string
+	}{
+		{
+			name:    "Status",
+			updates: []dashboardUpdate{{event: "status", status: ok}},
+		},
+		{
+			name:    "Events",
+			updates: []dashboardUpdate{{event: "status", status: ok}, {event: "fixacquired", status: ok}, {event: "gridchanged", status: ok}},
+			want:    []string{"Gridsquare changed to FM18lw", "Fix acquired, GPS fix (SPS)"},
+		},
+		{
+			name:    "Failed polls",
+			updates: []dashboardUpdate{{event: "status", status: failed}, {event: "status", status: failed}, {event: "status", status: ok}},
+			want:    []string{"Read timeout"},
+		},
+		{
+			name:    "Error",
+			updates: []dashboardUpdate{{err: errors.New("lost gps-qth-qtr")}},
+			want:    []string{"lost gps-qth-qtr"},
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+		t.Run(ttt.name, func(t *testing.T) {
+			d := newDashboard("")
+			for _, u := range ttt.updates {
+				d.apply(u, time.Now())
+			}
+
+			var got []string
+			for _, e := range d.events {
+				got = append(got, e.text)
+			}
+			if strings.Join(got, "|") != strings.Join(ttt.want, "|") {
+				t.Errorf("events = %q, want %q", got, ttt.want)
+			}
+		})
+	}
+}
+
+func Test_osc52(t *testing.T) {
+	if got := osc52("FM18lw"); got != "\x1b]52;c;Rk0xOGx3\a" {
+		t.Errorf("osc52() = %q", got)
+	}
+}
+
+func Test_readEvents(t *testing.T) {
+	var got []dashboardUpdate
+	err := readEvents(strings.NewReader("event: status\ndata: {\"status\":\"OK\"}\n\n: comment\n\nevent: fixlost\ndata: {\"status\":\"No fix\"}\n\n"), func(u dashboardUpdate) {
+		got = append(got, u)
+	})
+	if err == nil {
+		t.Errorf("readEvents() error = nil, want the end of the stream")
+	}
+	if len(got) != 2 || got[0].event != "status" || got[0].status.Status != "OK" || got[1].event != "fixlost" || got[1].status.Status != "No fix" {
+		t.Errorf("readEvents() = %+v", got)
+	}
+
+	err = readEvents(strings.NewReader("event: status\ndata: {\n\n"), func(u dashboardUpdate) {})
+	if err == nil {
+		t.Errorf("readEvents() of bad JSON error = nil")
+	}
+}
+
+// fakeDashboardSource sends what it is given and counts the updates asked for.
+type fakeDashboardSource struct {
+	c       chan dashboardUpdate
+	updated chan struct{}
+}
+
+func (f *fakeDashboardSource) updates() <-chan dashboardUpdate {
+	return f.c
+}
+
+func (f *fakeDashboardSource) update() error {
+	f.updated <- struct{}{}
+	return nil
+}
+
+func (f *fakeDashboardSource) close() {}
+
+func Test_runDashboardLoop(t *testing.T) {
+	grid := "FM18lw"
+	src := &fakeDashboardSource{
+		c:       make(chan dashboardUpdate),
+		updated: make(chan struct{}, 1),
+	}
+
+	keys := make(chan byte)
+	var out bytes.Buffer
+	d := newDashboard("")
+
+	done := make(chan error, 1)
+	go func() {
+		done <- runDashboardLoop(d, src, keys, &out, func() (int, int) { return 80, 24 })
+	}()
+
+	src.c <- dashboardUpdate{event: "status", status: apiStatus{Status: "OK", Gridsquare: &grid}}
+	for _, k := range []byte("zuc") {
+		keys <- k
+	}
+	select {
+	case <-src.updated:
+	case <-time.After(5 * time.Second):
+		t.Fatal("u didn't ask for an update")
+	}
+	keys <- 'q'
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("runDashboardLoop() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("runDashboardLoop() didn't return on q")
+	}
+
+	if !strings.Contains(out.String(), osc52(grid)) {
+		t.Errorf("runDashboardLoop() didn't copy the gridsquare")
+	}
+	if len(d.events) != 2 || d.events[0].text != "Copied FM18lw to the clipboard" || d.events[1].text != "Update requested" {
+		t.Errorf("events = %+v", d.events)
+	}
+}
+
+func Test_apiDashboardSource(t *testing.T) {
+	ts := httptest.NewServer(newAPIHandler())
+	defer ts.Close()
+
+	a := newAPIDashboardSource(strings.TrimPrefix(ts.URL, "http://"))
+	defer a.close()
+
+	next := func() dashboardUpdate {
+		select {
+		case u := <-a.updates():
+			return u
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for an update")
+		}
+		return dashboardUpdate{}
+	}
+
+	// current values come first
+	if u := next(); u.err != nil || u.event != "status" {
+		t.Fatalf("updates() = %+v, want status", u)
+	}
+
+	v := newGPSData().values()
+	v.Gridsquare = "RB32id"
+	publish([]gpsEvent{eventGridChanged}, v)
+
+	if u := next(); u.event != "status" || u.status.Gridsquare == nil || *u.status.Gridsquare != "RB32id" {
+		t.Errorf("updates() = %+v, want status with gridsquare", u)
+	}
+	if u := next(); u.event != "gridchanged" {
+		t.Errorf("updates() = %+v, want gridchanged", u)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+)
+
+var (
+	// checksum at the end of a sentence doesn't match its contents.
+	errBadChecksum = errors.New("bad checksum")
+
+	// receiver says its data isn't valid.
+	errInvalidState = errors.New("receiver not in valid state")
+
+	// gps device stopped sending.
+	errReadTimeout = errors.New("no data from gps device")
+
+	// gps device didn't send everything we need in time.
+	errPollTimeout = errors.New("gps device didn't send a complete fix in time")
+)
+
+// portError is an error from communicating with the gps device.
+type portError struct {
+	err error
+}
+
+// Error returns the underlying error message.
+func (e *portError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *portError) Unwrap() error {
+	return e.err
+}
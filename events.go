@@ -0,0 +1,104 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// gpsEvent is a change in location or time state that other things can react to.
+type gpsEvent string
+
+const (
+	// gridsquare is different from the last poll
+	eventGridChanged gpsEvent = "gridchanged"
+
+	// receiver had a fix on the last poll but doesn't now
+	eventFixLost gpsEvent = "fixlost"
+
+	// receiver didn't have a fix on the last poll but does now
+	eventFixAcquired gpsEvent = "fixacquired"
+
+	// system time was off by at least clockStepThreshold when it was set
+	eventClockStepped gpsEvent = "clockstepped"
+)
+
+// clockStepThreshold is how far off the system time has to be before setting it counts as a step.
+const clockStepThreshold = time.Second
+
+// gpsUpdate is what subscribers are sent after every poll.
+type gpsUpdate struct {
+	events []gpsEvent
+	values gpsValues
+}
+
+var (
+	// channels of everything interested in updates.
+	subscribers   = make(map[chan gpsUpdate]struct{})
+	subscribersMu sync.Mutex
+)
+
+// detectEvents compares gps data from the previous and current polls and returns the events that occurred
+// stepped indicates whether the system time was set during the current poll.
+func detectEvents(prev, cur *gpsData, stepped bool) []gpsEvent {
+	events := make([]gpsEvent, 0, 4)
+
+	// last good values kept from an earlier poll, or from before we started, don't count
+	hadFix := prev.hasCurrentFix()
+	hasFix := cur.hasCurrentFix()
+
+	if hadFix && !hasFix {
+		events = append(events, eventFixLost)
+	}
+	if !hadFix && hasFix {
+		events = append(events, eventFixAcquired)
+	}
+
+	l := cur.getGridsquare()
+	if l != "" && cur.isCurrent() && (l != prev.getGridsquare() || prev.isStale()) {
+		events = append(events, eventGridChanged)
+	}
+
+	if stepped {
+		o := cur.getClockOffset()
+		if o >= clockStepThreshold || o <= -clockStepThreshold {
+			events = append(events, eventClockStepped)
+		}
+	}
+
+	return events
+}
+
+// subscribe returns a channel that receives updates after every poll and a function to stop receiving them
+// updates are dropped if the receiver isn't keeping up.
+func subscribe() (<-chan gpsUpdate, func()) {
+	c := make(chan gpsUpdate, 8)
+
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+
+	subscribers[c] = struct{}{}
+
+	return c, func() {
+		subscribersMu.Lock()
+		defer subscribersMu.Unlock()
+
+		if _, ok := subscribers[c]; ok {
+			delete(subscribers, c)
+			close(c)
+		}
+	}
+}
+
+// publish sends an update to all subscribers without blocking.
+func publish(events []gpsEvent, v gpsValues) {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+
+	u := gpsUpdate{events: events, values: v}
+	for c := range subscribers {
+		select {
+		case c <- u:
+		default:
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_detectEvents(t *testing.T) {
+	gps := func(l, q string, o time.Duration) *gpsData {
+		g := newGPSData()
+		g.setGridsquare(l)
+		g.setFixQuality(q)
+		g.setClockOffset(o)
+		return g
+	}
+	failed := func(l, q string) *gpsData {
+		g := gps(l, q, 0)
+		g.setStatus("GGA line bad checksum")
+		return g
+	}
+	stale := func(l, q string) *gpsData {
+		g := gps(l, q, 0)
+		g.setStale(true)
+		return g
+	}
+
+	type args struct {
+		prev    *gpsData
+		cur     *gpsData
+		stepped bool
+	}
+	tests := []struct {
+		name string
+		args args
+		want []gpsEvent
+	}{
+		{
+			name: "First fix",
+			args: args{prev: newGPSData(), cur: gps("FM18lw", "GPS fix (SPS)", 0), stepped: true},
+			want: []gpsEvent{eventFixAcquired, eventGridChanged},
+		},
+		{
+			name: "No change",
+			args: args{prev: gps("FM18lw", "GPS fix (SPS)", 0), cur: gps("FM18lw", "DGPS fix", 0), stepped: true},
+			want: []gpsEvent{},
+		},
+		{
+			name: "Moved",
+			args: args{prev: gps("FM18lw", "GPS fix (SPS)", 0), cur: gps("FM18lx", "GPS fix (SPS)", 0), stepped: true},
+			want: []gpsEvent{eventGridChanged},
+		},
+		{
+			name: "Lost fix",
+			args: args{prev: gps("FM18lw", "GPS fix (SPS)", 0), cur: newGPSData(), stepped: false},
+			want: []gpsEvent{eventFixLost},
+		},
+		{
+			name: "Invalid fix",
+			args: args{prev: gps("FM18lw", "GPS fix (SPS)", 0), cur: gps("FM18lw", "invalid", 0), stepped: false},
+			want: []gpsEvent{eventFixLost},
+		},
+		{
+			name: "Clock stepped",
+			args: args{prev: gps("FM18lw", "GPS fix (SPS)", 0), cur: gps("FM18lw", "GPS fix (SPS)", -3*time.Second), stepped: true},
+			want: []gpsEvent{eventClockStepped},
+		},
+		{
+			name: "Clock close enough",
+			args: args{prev: gps("FM18lw", "GPS fix (SPS)", 0), cur: gps("FM18lw", "GPS fix (SPS)", 200*time.Millisecond), stepped: true},
+			want: []gpsEvent{},
+		},
+		{
+			name: "Poll failed",
+			args: args{prev: gps("FM18lw", "GPS fix (SPS)", 0), cur: failed("FM18lw", "GPS fix (SPS)"), stepped: false},
+			want: []gpsEvent{eventFixLost},
+		},
+		{
+			name: "Poll recovered",
+			args: args{prev: failed("FM18lw", "GPS fix (SPS)"), cur: gps("FM18lw", "GPS fix (SPS)", 0), stepped: true},
+			want: []gpsEvent{eventFixAcquired},
+		},
+		{
+			name: "Fix after restart",
+			args: args{prev: stale("FM18lw", "GPS fix (SPS)"), cur: gps("FM18lw", "GPS fix (SPS)", 0), stepped: true},
+			want: []gpsEvent{eventFixAcquired, eventGridChanged},
+		},
+		{
+			name: "Still stale",
+			args: args{prev: stale("FM18lw", "GPS fix (SPS)"), cur: stale("FM18lw", "GPS fix (SPS)"), stepped: false},
+			want: []gpsEvent{},
+		},
+		{
+			name: "Clock not set",
+			args: args{prev: gps("FM18lw", "GPS fix (SPS)", 0), cur: gps("FM18lw", "GPS fix (SPS)", 3*time.Second), stepped: false},
+			want: []gpsEvent{},
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			got := detectEvents(ttt.args.prev, ttt.args.cur, ttt.args.stepped)
+			if !reflect.DeepEqual(got, ttt.want) {
+				t.Errorf("detectEvents() = %v, want %v", got, ttt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gridVisit is a gridsquare the track passed through and when it was first and last there.
+type gridVisit struct {
+	Grid  string
+	First time.Time
+	Last  time.Time
+}
+
+// visitedGrids returns the n character gridsquares of pts in the order they were first visited.
+func visitedGrids(pts []gpxPoint, n int) ([]gridVisit, error) {
+	var visits []gridVisit
+	idx := make(map[string]int)
+
+	for _, p := range pts {
+		grid, err := latLonToGridsquare(p.Lat, p.Lon)
+		if err != nil {
+			return nil, err
+		}
+		grid = truncateGrid(grid, n)
+
+		i, ok := idx[grid]
+		if !ok {
+			idx[grid] = len(visits)
+			visits = append(visits, gridVisit{Grid: grid, First: p.Time, Last: p.Time})
+			continue
+		}
+		if p.Time.Before(visits[i].First) {
+			visits[i].First = p.Time
+		}
+		if p.Time.After(visits[i].Last) {
+			visits[i].Last = p.Time
+		}
+	}
+
+	sort.SliceStable(visits, func(i, j int) bool {
+		return visits[i].First.Before(visits[j].First)
+	})
+
+	return visits, nil
+}
+
+// gridRing returns the corners of grid as a closed ring of lon, lat pairs, counterclockwise.
+func gridRing(grid string) ([][2]float64, error) {
+	s, w, n, e, err := gridsquareBounds(grid)
+	if err != nil {
+		return nil, err
+	}
+
+	return [][2]float64{{w, s}, {e, s}, {e, n}, {w, n}, {w, s}}, nil
+}
+
+// formatVisitTimes returns the first and last time in a grid to show user.
+func formatVisitTimes(v gridVisit) string {
+	return fmt.Sprintf("First %s, last %s", v.First.UTC().Format(time.RFC3339), v.Last.UTC().Format(time.RFC3339))
+}
+
+// kmlPlacemark is a KML Placemark with either a line or a polygon.
+type kmlPlacemark struct {
+	Name        string `xml:"name"`
+	Description string `xml:"description,omitempty"`
+	TimeSpan    *struct {
+		Begin string `xml:"begin"`
+		End   string `xml:"end"`
+	} `xml:"TimeSpan,omitempty"`
+	StyleURL string `xml:"styleUrl"`
+	Point    *struct {
+		Coordinates string `xml:"coordinates"`
+	} `xml:"Point,omitempty"`
+	LineString *struct {
+		Coordinates string `xml:"coordinates"`
+	} `xml:"LineString,omitempty"`
+	Polygon *struct {
+		Coordinates string `xml:"outerBoundaryIs>LinearRing>coordinates"`
+	} `xml:"Polygon,omitempty"`
+}
+
+// kmlStyle is a KML Style for lines and polygons.
+type kmlStyle struct {
+	ID        string `xml:"id,attr"`
+	LineColor string `xml:"LineStyle>color"`
+	LineWidth int    `xml:"LineStyle>width"`
+	PolyStyle *struct {
+		Color string `xml:"color"`
+	} `xml:"PolyStyle,omitempty"`
+}
+
+// kmlDocument is a KML file with a track and the gridsquares visited.
+type kmlDocument struct {
+	XMLName xml.Name       `xml:"http://www.opengis.net/kml/2.2 kml"`
+	Name    string         `xml:"Document>name"`
+	Styles  []kmlStyle     `xml:"Document>Style"`
+	Track   kmlPlacemark   `xml:"Document>Placemark"`
+	Folder  string         `xml:"Document>Folder>name"`
+	Grids   []kmlPlacemark `xml:"Document>Folder>Placemark"`
+}
+
+// formatKMLCoordinates returns pairs of lon, lat as KML coordinates.
+func formatKMLCoordinates(coords [][2]float64) string {
+	s := make([]string, len(coords))
+	for i, c := range coords {
+		s[i] = strconv.FormatFloat(c[0], 'f', -1, 64) + "," + strconv.FormatFloat(c[1], 'f', -1, 64)
+	}
+	return strings.Join(s, " ")
+}
+
+// encodeKML returns the track and gridsquares visited as a KML file.
+func encodeKML(name string, pts []gpxPoint, visits []gridVisit) ([]byte, error) {
+	doc := kmlDocument{
+		Name: name,
+		Styles: []kmlStyle{
+			{ID: "track", LineColor: "ff0000ff", LineWidth: 3},
+			{ID: "grid", LineColor: "ff00aa00", LineWidth: 1},
+		},
+		Track: kmlPlacemark{
+			Name:     "Track",
+			StyleURL: "#track",
+		},
+		Folder: "Gridsquares",
+	}
+
+	// grids are see through so the track shows
+	doc.Styles[1].PolyStyle = &struct {
+		Color string `xml:"color"`
+	}{Color: "4000ff00"}
+
+	coords := make([][2]float64, len(pts))
+	for i, p := range pts {
+		coords[i] = [2]float64{p.Lon, p.Lat}
+	}
+	// a line needs two points
+	if len(coords) == 1 {
+		doc.Track.Point = &struct {
+			Coordinates string `xml:"coordinates"`
+		}{Coordinates: formatKMLCoordinates(coords)}
+	} else {
+		doc.Track.LineString = &struct {
+			Coordinates string `xml:"coordinates"`
+		}{Coordinates: formatKMLCoordinates(coords)}
+	}
+
+	for _, v := range visits {
+		ring, err := gridRing(v.Grid)
+		if err != nil {
+			return nil, err
+		}
+
+		pm := kmlPlacemark{
+			Name:        v.Grid,
+			Description: formatVisitTimes(v),
+			StyleURL:    "#grid",
+		}
+		pm.TimeSpan = &struct {
+			Begin string `xml:"begin"`
+			End   string `xml:"end"`
+		}{Begin: v.First.UTC().Format(time.RFC3339), End: v.Last.UTC().Format(time.RFC3339)}
+		pm.Polygon = &struct {
+			Coordinates string `xml:"outerBoundaryIs>LinearRing>coordinates"`
+		}{Coordinates: formatKMLCoordinates(ring)}
+
+		doc.Grids = append(doc.Grids, pm)
+	}
+
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+
+	enc := xml.NewEncoder(&b)
+	enc.Indent("", "  ")
+	err := enc.Encode(doc)
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+	b.WriteByte('\n')
+
+	return b.Bytes(), nil
+}
+
+// geoJSONGeometry is a GeoJSON Point, LineString or Polygon.
+type geoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// geoJSONFeature is a GeoJSON Feature.
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   geoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// geoJSONFeatureCollection is a GeoJSON file.
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+// encodeGeoJSON returns the track and gridsquares visited as a GeoJSON FeatureCollection.
+func encodeGeoJSON(name string, pts []gpxPoint, visits []gridVisit) ([]byte, error) {
+	fc := geoJSONFeatureCollection{Type: "FeatureCollection", Features: []geoJSONFeature{}}
+
+	// positions are lon, lat and altitude when we have it
+	coords := make([][]float64, len(pts))
+	times := make([]string, len(pts))
+	for i, p := range pts {
+		coords[i] = []float64{p.Lon, p.Lat}
+		if p.Ele != nil && !math.IsNaN(*p.Ele) {
+			coords[i] = append(coords[i], *p.Ele)
+		}
+		times[i] = p.Time.UTC().Format(time.RFC3339)
+	}
+
+	// a line needs two points
+	track := geoJSONGeometry{Type: "LineString", Coordinates: coords}
+	if len(coords) == 1 {
+		track = geoJSONGeometry{Type: "Point", Coordinates: coords[0]}
+	}
+	if len(coords) > 0 {
+		fc.Features = append(fc.Features, geoJSONFeature{
+			Type:     "Feature",
+			Geometry: track,
+			Properties: map[string]interface{}{
+				"name":  name,
+				"times": times,
+			},
+		})
+	}
+
+	for _, v := range visits {
+		ring, err := gridRing(v.Grid)
+		if err != nil {
+			return nil, err
+		}
+
+		fc.Features = append(fc.Features, geoJSONFeature{
+			Type:     "Feature",
+			Geometry: geoJSONGeometry{Type: "Polygon", Coordinates: [][][2]float64{ring}},
+			Properties: map[string]interface{}{
+				"name":    v.Grid,
+				"locator": v.Grid,
+				"first":   v.First.UTC().Format(time.RFC3339),
+				"last":    v.Last.UTC().Format(time.RFC3339),
+			},
+		})
+	}
+
+	b, err := json.MarshalIndent(fc, "", "  ")
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	return append(b, '\n'), nil
+}
+
+// runExport is the export command, it writes recorded tracks and the gridsquares visited as KML or GeoJSON.
+func runExport(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", "kml", "output `format`, kml or geojson")
+	out := fs.String("out", "", "write to `file` instead of standard output")
+	gridLength := fs.Int("gridlength", 4, "`length` of the gridsquares, 4 or 6")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: gps-qth-qtr export [-format kml|geojson] [-out file] [-gridlength 4|6] track.gpx...\n")
+		fs.PrintDefaults()
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return exitUsage
+	}
+	if fs.NArg() == 0 || (*format != "kml" && *format != "geojson") || (*gridLength != 4 && *gridLength != 6) {
+		fs.Usage()
+		return exitUsage
+	}
+
+	pts, err := readGPXFiles(fs.Args())
+	if err != nil {
+		return exitFailed
+	}
+
+	visits, err := visitedGrids(pts, *gridLength)
+	if err != nil {
+		return exitFailed
+	}
+
+	name := fmt.Sprintf("gps-qth-qtr %s to %s", pts[0].Time.UTC().Format(time.RFC3339), pts[len(pts)-1].Time.UTC().Format(time.RFC3339))
+
+	var b []byte
+	if *format == "kml" {
+		b, err = encodeKML(name, pts, visits)
+	} else {
+		b, err = encodeGeoJSON(name, pts, visits)
+	}
+	if err != nil {
+		return exitFailed
+	}
+
+	if *out == "" {
+		_, err = stdout.Write(b)
+	} else {
+		err = ioutil.WriteFile(*out, b, 0666)
+	}
+	if err != nil {
+		log.Printf("%+v", err)
+		return exitFailed
+	}
+
+	log.Printf("exported %d points in %d gridsquares", len(pts), len(visits))
+	return exitOK
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// exportTrack is a drive from FM18 to FM19 and back.
+func exportTrack() []gpxPoint {
+	base := time.Date(2024, 6, 1, 14, 0, 0, 0, time.UTC)
+	ele := 85.5
+
+	return []gpxPoint{
+		{Lat: 38.92, Lon: -77.065, Ele: &ele, Time: base},
+		{Lat: 39.2, Lon: -77.065, Time: base.Add(time.Hour)},
+		{Lat: 39.3, Lon: -76.9, Time: base.Add(2 * time.Hour)},
+		{Lat: 38.95, Lon: -77.0, Time: base.Add(3 * time.Hour)},
+	}
+}
+
+func Test_visitedGrids(t *testing.T) {
+	pts := exportTrack()
+
+	got, err := visitedGrids(pts, 4)
+	if err != nil {
+		t.Fatalf("visitedGrids() error = %v", err)
+	}
+	want := []gridVisit{
+		{Grid: "FM18", First: pts[0].Time, Last: pts[3].Time},
+		{Grid: "FM19", First: pts[1].Time, Last: pts[2].Time},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("visitedGrids() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].Grid != want[i].Grid || !got[i].First.Equal(want[i].First) || !got[i].Last.Equal(want[i].Last) {
+			t.Errorf("visitedGrids() = %+v, want %+v", got[i], want[i])
+		}
+	}
+
+	got, err = visitedGrids(pts, 6)
+	if err != nil {
+		t.Fatalf("visitedGrids() error = %v", err)
+	}
+	if len(got) != 4 || got[0].Grid != "FM18lw" {
+		t.Errorf("visitedGrids() = %+v, want 4 subsquares starting with FM18lw", got)
+	}
+}
+
+func Test_encodeGeoJSON(t *testing.T) {
+	pts := exportTrack()
+	visits, err := visitedGrids(pts, 4)
+	if err != nil {
+		t.Fatalf("visitedGrids() error = %v", err)
+	}
+
+	b, err := encodeGeoJSON("test", pts, visits)
+	if err != nil {
+		t.Fatalf("encodeGeoJSON() error = %v", err)
+	}
+
+	var fc struct {
+		Type     string
+		Features []struct {
+			Geometry struct {
+				Type        string
+				Coordinates json.RawMessage
+			}
+			Properties map[string]interface{}
+		}
+	}
+	var compact bytes.Buffer
+	err = json.Compact(&compact, b)
+	if err != nil {
+		t.Fatalf("encodeGeoJSON() is not json: %v", err)
+	}
+	err = json.Unmarshal(compact.Bytes(), &fc)
+	if err != nil {
+		t.Fatalf("encodeGeoJSON() is not json: %v", err)
+	}
+
+	if fc.Type != "FeatureCollection" || len(fc.Features) != 3 {
+		t.Fatalf("encodeGeoJSON() = %s", b)
+	}
+	if fc.Features[0].Geometry.Type != "LineString" || !strings.HasPrefix(string(fc.Features[0].Geometry.Coordinates), "[[-77.065,38.92,85.5],[-77.065,39.2]") {
+		t.Errorf("encodeGeoJSON() track = %s", fc.Features[0].Geometry.Coordinates)
+	}
+
+	g := fc.Features[1]
+	if g.Geometry.Type != "Polygon" || string(g.Geometry.Coordinates) != "[[[-78,38],[-76,38],[-76,39],[-78,39],[-78,38]]]" {
+		t.Errorf("encodeGeoJSON() grid = %s", g.Geometry.Coordinates)
+	}
+	if g.Properties["locator"] != "FM18" || g.Properties["first"] != "2024-06-01T14:00:00Z" || g.Properties["last"] != "2024-06-01T17:00:00Z" {
+		t.Errorf("encodeGeoJSON() grid properties = %v", g.Properties)
+	}
+}
+
+func Test_encodeGeoJSON_track(t *testing.T) {
+	tests := []struct {
+		name     string
+		pts      []gpxPoint
+		features int
+		want     string
+	}{
+		{name: "One point", pts: exportTrack()[:1], features: 2, want: `{"type":"Point","coordinates":[-77.065,38.92,85.5]}`},
+		{name: "Two points", pts: exportTrack()[:2], features: 3, want: `{"type":"LineString","coordinates":[[-77.065,38.92,85.5],[-77.065,39.2]]}`},
+		{name: "No points", features: 0},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			visits, err := visitedGrids(ttt.pts, 4)
+			if err != nil {
+				t.Fatalf("visitedGrids() error = %v", err)
+			}
+
+			b, err := encodeGeoJSON("test", ttt.pts, visits)
+			if err != nil {
+				t.Fatalf("encodeGeoJSON() error = %v", err)
+			}
+
+			var fc struct {
+				Features []struct {
+					Geometry json.RawMessage
+				}
+			}
+			var compact bytes.Buffer
+			err = json.Compact(&compact, b)
+			if err != nil {
+				t.Fatalf("encodeGeoJSON() is not json: %v", err)
+			}
+			err = json.Unmarshal(compact.Bytes(), &fc)
+			if err != nil {
+				t.Fatalf("encodeGeoJSON() is not json: %v", err)
+			}
+
+			if len(fc.Features) != ttt.features {
+				t.Fatalf("encodeGeoJSON() = %s, want %d features", b, ttt.features)
+			}
+			if ttt.features > 0 && string(fc.Features[0].Geometry) != ttt.want {
+				t.Errorf("encodeGeoJSON() track = %s, want %s", fc.Features[0].Geometry, ttt.want)
+			}
+		})
+	}
+}
+
+func Test_encodeKML(t *testing.T) {
+	pts := exportTrack()
+	visits, err := visitedGrids(pts, 4)
+	if err != nil {
+		t.Fatalf("visitedGrids() error = %v", err)
+	}
+
+	b, err := encodeKML("test", pts, visits)
+	if err != nil {
+		t.Fatalf("encodeKML() error = %v", err)
+	}
+
+	var doc kmlDocument
+	err = xml.Unmarshal(b, &doc)
+	if err != nil {
+		t.Fatalf("encodeKML() is not xml: %v", err)
+	}
+
+	if doc.Track.LineString == nil || doc.Track.LineString.Coordinates != "-77.065,38.92 -77.065,39.2 -76.9,39.3 -77,38.95" {
+		t.Errorf("encodeKML() track = %+v", doc.Track)
+	}
+	if len(doc.Grids) != 2 {
+		t.Fatalf("encodeKML() grids = %+v", doc.Grids)
+	}
+
+	// a line needs two points
+	one, err := encodeKML("test", pts[:1], visits[:1])
+	if err != nil {
+		t.Fatalf("encodeKML() one point error = %v", err)
+	}
+	var oneDoc kmlDocument
+	err = xml.Unmarshal(one, &oneDoc)
+	if err != nil {
+		t.Fatalf("encodeKML() one point is not xml: %v", err)
+	}
+	if oneDoc.Track.LineString != nil || oneDoc.Track.Point == nil || oneDoc.Track.Point.Coordinates != "-77.065,38.92" {
+		t.Errorf("encodeKML() one point track = %+v", oneDoc.Track)
+	}
+
+	g := doc.Grids[1]
+	if g.Name != "FM19" || g.Polygon == nil || g.Polygon.Coordinates != "-78,39 -76,39 -76,40 -78,40 -78,39" {
+		t.Errorf("encodeKML() grid = %+v", g)
+	}
+	if g.TimeSpan == nil || g.TimeSpan.Begin != "2024-06-01T15:00:00Z" || g.TimeSpan.End != "2024-06-01T16:00:00Z" {
+		t.Errorf("encodeKML() grid time span = %+v", g.TimeSpan)
+	}
+}
+
+func Test_runExport(t *testing.T) {
+	f, err := ioutil.TempFile("", "export*.gpx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	_, err = f.WriteString(`<gpx version="1.1"><trk><trkseg>
+<trkpt lat="38.92" lon="-77.065"><time>2024-06-01T14:00:00Z</time></trkpt>
+</trkseg></trk></gpx>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if code := runExport([]string{"-format", "geojson", f.Name()}, &out, ioutil.Discard); code != 0 {
+		t.Fatalf("runExport() = %v, want 0", code)
+	}
+	if !strings.Contains(out.String(), `"locator": "FM18"`) {
+		t.Errorf("runExport() output = %s", out.String())
+	}
+
+	if code := runExport([]string{"-format", "shp", f.Name()}, &out, ioutil.Discard); code != 2 {
+		t.Errorf("runExport() with bad format = %v, want 2", code)
+	}
+}
@@ -4,10 +4,12 @@ go 1.13
 
 require (
 	github.com/akavel/rsrc v0.10.1 // indirect
+	github.com/godbus/dbus/v5 v5.1.0
 	github.com/lxn/walk v0.0.0-20191113135339-bf589de20b3c
 	github.com/lxn/win v0.0.0-20191106123917-121afc750dd3
 	github.com/tarm/serial v0.0.0-20180830185346-98f6abe2eb07
 	golang.org/x/sys v0.0.0-20200117145432-59e60aa80a0c
 	gopkg.in/Knetic/govaluate.v3 v3.0.0 // indirect
 	gopkg.in/yaml.v2 v2.2.7
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -1,29 +1,67 @@
 package main
 
 import (
+	"flag"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
-	"path"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/tarm/serial"
-	"gopkg.in/yaml.v2"
 )
 
+// gpsDeviceConfig is how to read the gps device.
+type gpsDeviceConfig struct {
+	Port     string
+	Baud     int
+	PollRate time.Duration
+	// worst HDOP the system time is set with
+	MaxHDOP float64
+}
+
+// defaultMaxHDOP is the worst HDOP the system time is set with when the configuration doesn't say.
+const defaultMaxHDOP = 5
+
+const (
+	// how long to wait for the gps device to send anything, they send every second
+	serialReadTimeout = 5 * time.Second
+
+	// how long a poll can take, a cold receiver can take a few seconds to send all it knows
+	gpsPollTimeout = 30 * time.Second
+)
+
+// maxHDOP returns the worst HDOP the system time is set with.
+func (c gpsDeviceConfig) maxHDOP() float64 {
+	if c.MaxHDOP <= 0 {
+		return defaultMaxHDOP
+	}
+	return c.MaxHDOP
+}
+
 // configuration holds the application configuration.
 type configuration struct {
-	GPSDevice struct {
-		Port     string
-		Baud     int
-		PollRate time.Duration
+	GPSDevice gpsDeviceConfig
+	Hooks     []hookConfig
+	API       struct {
+		Address string
 	}
+	MQTT         mqttConfig
+	WSJTX        wsjtxConfig
+	JS8Call      js8callConfig
+	UDPBroadcast udpBroadcastConfig
+	APRS         aprsConfig
+	Cloudlog     cloudlogConfig
+	Track        trackConfig
+	History      historyConfig
 }
 
 var (
-	// application configuration.
-	config configuration
+	// application configuration, changed with setConfig once anything is running.
+	config   configuration
+	configMu sync.RWMutex
 
 	// last data from gps device.
 	gpsdata = newGPSData()
@@ -32,6 +70,62 @@ var (
 	nbmGatherGpsData = NewNonBlockingMutex()
 )
 
+// currentConfig returns the application configuration, safe to call while it is being reloaded.
+func currentConfig() configuration {
+	configMu.RLock()
+	defer configMu.RUnlock()
+
+	return config
+}
+
+// setConfig replaces the application configuration with cfg.
+func setConfig(cfg configuration) {
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	config = cfg
+}
+
+// newConfiguration returns the configuration used before there is a configuration file.
+func newConfiguration() configuration {
+	var cfg configuration
+
+	cfg.GPSDevice.Baud = 9600
+	cfg.GPSDevice.PollRate = 900
+	cfg.GPSDevice.MaxHDOP = defaultMaxHDOP
+	return cfg
+}
+
+// readConfig returns the configuration in the yaml file fn, with the defaults for what it leaves out
+// the error lists every problem with the file.
+func readConfig(fn string) (configuration, error) {
+	// #nosec G304
+	bytes, err := ioutil.ReadFile(fn)
+	if err != nil {
+		log.Printf("%+v", err)
+		return configuration{}, err
+	}
+
+	cfg, problems := decodeConfig(bytes)
+	if len(problems) > 0 {
+		err := &configError{File: fn, Problems: problems}
+		log.Printf("%+v", err)
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// loadConfig reads the application configuration from the yaml file fn.
+func loadConfig(fn string) error {
+	cfg, err := readConfig(fn)
+	if err != nil {
+		return err
+	}
+
+	setConfig(cfg)
+	return nil
+}
+
 // readLineFromPort reads bytes from port and accumulates string until delim is met
 // does not return delim in string.
 func readLineFromPort(p *serial.Port, delim byte) (string, error) {
@@ -40,70 +134,176 @@ func readLineFromPort(p *serial.Port, delim byte) (string, error) {
 
 	for {
 		n, err := p.Read(buf)
+		if n == 0 && (err == nil || err == io.EOF) {
+			// nothing before the read timeout
+			err = errReadTimeout
+		}
 		if err != nil {
 			log.Printf("%+v", err)
 			return "", err
 		}
 
-		if n > 0 {
-			if buf[0] == delim {
-				return s, nil
+		if buf[0] == delim {
+			return s, nil
+		}
+		s += string(buf[:n])
+	}
+}
+
+// openGPSPort opens the serial port of the gps device and purges anything already buffered.
+func openGPSPort(cfg gpsDeviceConfig) (*serial.Port, error) {
+	p, err := serial.OpenPort(&serial.Config{
+		Name:        cfg.Port,
+		Baud:        cfg.Baud,
+		ReadTimeout: serialReadTimeout,
+	})
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, &portError{err: err}
+	}
+
+	// purge any com port buffers, there may be nothing in them
+	buf := make([]byte, 4096)
+	_, err = p.Read(buf)
+	if err == io.EOF {
+		err = nil
+	}
+	if err != nil {
+		log.Printf("%+v", err)
+		p.Close()
+		return nil, &portError{err: err}
+	}
+
+	return p, nil
+}
+
+// readMotion reads the position, speed and course from the next RMC sentence, leaving the gps data as it is and telling no one
+// false if a poll is in progress, its result comes as an update instead, or there is no fix.
+func readMotion() (gpsValues, bool) {
+	if !nbmGatherGpsData.Lock() {
+		return gpsValues{}, false
+	}
+	defer nbmGatherGpsData.Unlock()
+
+	p, err := openGPSPort(currentConfig().GPSDevice)
+	if err != nil {
+		return gpsValues{}, false
+	}
+	defer p.Close()
+
+	// the receiver sends an RMC sentence every second or so
+	deadline := time.Now().Add(serialReadTimeout)
+	for time.Now().Before(deadline) {
+		var s string
+		s, err = readLineFromPort(p, '$')
+		if err != nil {
+			return gpsValues{}, false
+		}
+
+		if len(s) > 5 && s[2:5] == "RMC" {
+			// altitude and the rest are from the last poll
+			v := gpsdata.values()
+			v.Status = ""
+			v.Time, v.Gridsquare, v.Latitude, v.Longitude, v.Speed, v.Course, err = parseRMC(s)
+			if err != nil {
+				return gpsValues{}, false
 			}
-			s += string(buf[:n])
+			return v, true
 		}
 	}
+
+	return gpsValues{}, false
+}
+
+// stepClock sets the system time to the time of the fix in g with set, g is only marked synced if that worked.
+func stepClock(g *gpsData, set func(time.Time) error) error {
+	err := set(g.getTime())
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+	g.setSynced(time.Now(), g.getClockOffset())
+
+	return nil
 }
 
-// gatherGpsData reads from gps port until **RMC & **GGA lines are successfully processed
-// system time is updated as long as the quality of the gps signal is good enough (HDOP < 5).
-func gatherGpsData() bool {
+// gatherGpsData reads from gps port until **RMC & **GGA lines are successfully processed, along with the **GSA & **GSV lines of the same second if the receiver sends them
+// system time is updated if setTime is true, as long as the quality of the gps signal is good enough (HDOP under the configured maximum, 5 by default).
+func gatherGpsData(setTime bool) bool {
 	if nbmGatherGpsData.Lock() {
 		defer nbmGatherGpsData.Unlock()
 
-		// we want the update to the global gpsdata to be atomic and only if there was no errors in gathering data
+		// we want the update to the global gpsdata to be atomic and the fix only replaced if there was no errors in gathering data
 		var err error
+		var stepped bool
+		start := time.Now()
 		newgpsdata := newGPSData()
 		defer func() {
 			if err != nil {
-				// reset newgpsdata
-				newgpsdata = newGPSData()
+				// keep the last good fix, only the attempt changes
+				newgpsdata.copy(gpsdata)
 
 				// set message to error string
 				newgpsdata.setStatus(err.Error())
+			} else if !stepped {
+				// keep when the system time was last set
+				newgpsdata.setSynced(gpsdata.getSynced())
 			}
+			newgpsdata.setAttempted(time.Now())
+
+			// keep previous values so we can tell what changed
+			prevgpsdata := newGPSData()
+			prevgpsdata.copy(gpsdata)
+
 			// copy over new values
 			gpsdata.copy(newgpsdata)
+
+			recordPoll(time.Since(start), err, gpsdata)
+			recordHistory(time.Now(), time.Since(start), err, gpsdata.values())
+
+			// remember the position for next time we start
+			if err == nil && statePath != "" {
+				_ = saveState(statePath, gpsdata.values(), time.Now())
+			}
+
+			// let everyone know
+			events := detectEvents(prevgpsdata, gpsdata, stepped)
+			values := gpsdata.values()
+			runHooks(events, values)
+			publish(events, values)
 		}()
 
-		config := &serial.Config{
-			Name: config.GPSDevice.Port,
-			Baud: config.GPSDevice.Baud,
-		}
+		// the configuration can be reloaded while we poll
+		cfg := currentConfig()
+		maxhdop := cfg.GPSDevice.maxHDOP()
 
 		var p *serial.Port
-		p, err = serial.OpenPort(config)
+		p, err = openGPSPort(cfg.GPSDevice)
 		if err != nil {
-			log.Printf("%+v", err)
 			return false
 		}
 		defer p.Close()
 
-		// purge any com port buffers
-		buf := make([]byte, 4096)
-		_, err = p.Read(buf)
-		if err != nil {
-			log.Printf("%+v", err)
-			return false
-		}
-
-		var gotrmc bool
-		var gotgga bool
+		var rmcs int
+		var gotgga, gotgsa bool
+		sky := newSkyView()
+		defer func() {
+			newgpsdata.setSatellites(sky.satellites())
+		}()
 
+		deadline := start.Add(gpsPollTimeout)
 		for {
+			if time.Now().After(deadline) {
+				err = errPollTimeout
+				log.Printf("%+v", err)
+				return false
+			}
+
 			var s string
 			s, err = readLineFromPort(p, '$')
 			if err != nil {
 				log.Printf("%+v", err)
+				err = &portError{err: err}
 				return false
 			}
 
@@ -114,8 +314,8 @@ func gatherGpsData() bool {
 				case "RMC":
 					var t time.Time
 					var l string
-					var lat, lon float64
-					t, l, lat, lon, err = parseRMC(s)
+					var lat, lon, spd, crs float64
+					t, l, lat, lon, spd, crs, err = parseRMC(s)
 					if err != nil {
 						log.Printf("%+v|%+s", err, s)
 						return false
@@ -126,13 +326,15 @@ func gatherGpsData() bool {
 					newgpsdata.setGridsquare(l)
 					newgpsdata.setLatitude(lat)
 					newgpsdata.setLongitude(lon)
+					newgpsdata.setSpeed(spd)
+					newgpsdata.setCourse(crs)
 
-					gotrmc = true
+					rmcs++
 				case "GGA":
 					var q string
 					var n int
-					var h float64
-					q, n, h, err = parseGGA(s)
+					var h, alt float64
+					q, n, h, alt, err = parseGGA(s)
 					if err != nil {
 						log.Printf("%+v|%+s", err, s)
 						return false
@@ -142,21 +344,52 @@ func gatherGpsData() bool {
 					newgpsdata.setFixQuality(q)
 					newgpsdata.setNumSatellites(n)
 					newgpsdata.setHDOP(h)
+					newgpsdata.setAltitude(alt)
 
 					gotgga = true
+				case "GSA":
+					// dilutions of precision are nice to have, so a bad one doesn't fail the poll
+					// receivers send one per system, they all have the same ones and the best mode is the fix
+					m, gsaerr := parseGSA(s)
+					if gsaerr == nil {
+						newgpsdata.setPDOP(m.PDOP)
+						newgpsdata.setVDOP(m.VDOP)
+						sky.use(m)
+						if m.Mode > newgpsdata.getFixMode() {
+							newgpsdata.setFixMode(m.Mode)
+						}
+						gotgsa = true
+					}
+				case "GSV":
+					// satellites are nice to have, so a bad one doesn't fail the poll
+					m, gsverr := parseGSV(s)
+					if gsverr == nil {
+						sky.add(m)
+					}
 				}
 			}
 
+			// satellites come once a second, a second RMC means we have seen them all if there are any
+			gotsky := !sky.inProgress() && (sky.hasSatellites() || rmcs > 1)
+			gotdop := gotgsa || rmcs > 1
+
 			// if we were able to capture all the data we need
-			if gotrmc && gotgga {
+			if rmcs > 0 && gotgga && gotsky && gotdop {
 				// and gps signal good enough
-				if newgpsdata.getHDOP() < 5 {
+				if newgpsdata.getHDOP() < maxhdop {
+					// measure how far off the system time is
+					newgpsdata.setClockOffset(newgpsdata.getTime().Sub(time.Now().UTC()))
+
+					if !setTime {
+						return true
+					}
+
 					// update system time
-					err = setSystemTime(newgpsdata.getTime())
+					err = stepClock(newgpsdata, setSystemTime)
 					if err != nil {
-						log.Printf("%+v", err)
 						return false
 					}
+					stepped = true
 					return true
 				}
 			}
@@ -165,63 +398,80 @@ func gatherGpsData() bool {
 	return false
 }
 
-func main() {
-	// show file & location, date & time
-	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
+// formatStartupProblem returns why we couldn't start the way we were configured to, to show user.
+func formatStartupProblem(err error) string {
+	ce, ok := err.(*configError)
+	if !ok {
+		return "Couldn't start: " + err.Error()
+	}
 
-	// log & config files are in the same directory as the executable with the same base name
-	fn, err := os.Executable()
-	if err != nil {
-		log.Fatalf("%+v", err)
+	var b strings.Builder
+	b.WriteString("Running with the defaults until the configuration file is fixed:")
+	for _, p := range ce.Problems {
+		b.WriteString("\n" + p.format(ce.File))
 	}
-	basefn := strings.TrimSuffix(fn, path.Ext(fn))
+	return b.String()
+}
 
-	// log to file
-	f, err := os.OpenFile(basefn+".log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
-	if err != nil {
-		log.Fatalf("%+v", err)
+// runRun is the run command, it polls the gps device in the background and runs in the system tray until exit.
+func runRun(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	err := fs.Parse(args)
+	if err != nil || fs.NArg() != 0 {
+		return exitUsage
 	}
-	defer f.Close()
-	log.SetOutput(f)
 
-	// read config
-	// #nosec G304
-	bytes, err := ioutil.ReadFile(basefn + ".yaml")
-	if err != nil {
-		log.Fatalf("%+v", err)
+	// problems starting are shown in the tray rather than exiting, so they can be fixed from there
+	var problem string
+
+	err = loadConfig(options.configPath)
+	if os.IsNotExist(err) {
+		// first run, the gps device is set up from the settings window
+		log.Printf("no configuration file %s yet, starting with the defaults", options.configPath)
+		setConfig(newConfiguration())
+	} else if err != nil {
+		log.Printf("configuration in %s has problems, starting with the defaults", options.configPath)
+		setConfig(newConfiguration())
+		problem = formatStartupProblem(err)
 	}
 
-	err = yaml.Unmarshal(bytes, &config)
-	if err != nil {
-		log.Fatalf("%+v", err)
+	// start with the last known position until we get a fix
+	statePath = options.statePath
+	if _, err := os.Stat(statePath); err == nil {
+		st, err := loadState(statePath)
+		if err == nil {
+			gpsdata.copy(st)
+		}
 	}
 
-	// create a task that fires every 30 secs until we get the first reading
-	// then use config.GPSDevice.PollRate
-	quit := make(chan bool)
-	go func() {
-		startup := true
-		ticker := time.NewTicker(30 * time.Second)
+	err = startRunning()
+	if err != nil && problem == "" {
+		problem = formatStartupProblem(err)
+	}
+	defer stopRunning()
 
-		for {
-			select {
-			case <-ticker.C:
-				if gatherGpsData() && startup {
-					ticker.Stop()
-					ticker = time.NewTicker(config.GPSDevice.PollRate * time.Second)
-					startup = false
-				}
-			case <-quit:
-				ticker.Stop()
-				return
-			}
-		}
-	}()
-	defer close(quit)
+	// pick up edits to the configuration file without restarting
+	w := newConfigWatcher(options.configPath, configWatchInterval, reloadConfig)
+	w.start()
+	defer w.stop()
 
 	// returns on exit
-	err = systemTray()
+	err = systemTray(problem)
 	if err != nil {
 		log.Fatalf("%+v", err)
 	}
+	return exitOK
+}
+
+func main() {
+	// show file & location, date & time
+	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
+
+	// commands print to the console they were run from
+	if len(os.Args) > 1 {
+		attachConsole()
+	}
+
+	os.Exit(runCommand(os.Args[1:], os.Stdout, os.Stderr))
 }
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"errors"
 	"io/ioutil"
 	"log"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestMain(m *testing.M) {
@@ -13,3 +15,82 @@ func TestMain(m *testing.M) {
 
 	os.Exit(m.Run())
 }
+
+func Test_stepClock(t *testing.T) {
+	fixed := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		err        error
+		wantSynced bool
+	}{
+		{
+			name:       "Set",
+			wantSynced: true,
+		},
+		{
+			name: "Not allowed",
+			err:  errors.New("operation not permitted"),
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			g := newGPSData()
+			g.setTime(fixed)
+			g.setClockOffset(time.Second)
+
+			var got time.Time
+			err := stepClock(g, func(t time.Time) error {
+				got = t
+				return ttt.err
+			})
+			if err != ttt.err {
+				t.Errorf("stepClock() error = %v, want %v", err, ttt.err)
+			}
+			if !got.Equal(fixed) {
+				t.Errorf("stepClock() set %v, want %v", got, fixed)
+			}
+
+			sy, o := g.getSynced()
+			if (sy != time.Time{}) != ttt.wantSynced {
+				t.Errorf("stepClock() synced = %v, want synced %v", sy, ttt.wantSynced)
+			}
+			if ttt.wantSynced && o != time.Second {
+				t.Errorf("stepClock() offset = %v, want %v", o, time.Second)
+			}
+		})
+	}
+}
+
+func Test_formatStartupProblem(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "Configuration",
+			err: &configError{File: "gps-qth-qtr.yaml", Problems: []configProblem{
+				{Key: "gpsdevice.prt", Line: 3, Message: "is not a setting"},
+				{Key: "wsjtx.gridlength", Line: 7, Message: "must be 4 or 6"},
+			}},
+			want: "Running with the defaults until the configuration file is fixed:\ngps-qth-qtr.yaml:3: gpsdevice.prt is not a setting\ngps-qth-qtr.yaml:7: wsjtx.gridlength must be 4 or 6",
+		},
+		{
+			name: "Services",
+			err:  errors.New("listen tcp 127.0.0.1:8080: bind: address already in use"),
+			want: "Couldn't start: listen tcp 127.0.0.1:8080: bind: address already in use",
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			if got := formatStartupProblem(ttt.err); got != ttt.want {
+				t.Errorf("formatStartupProblem() = %q, want %q", got, ttt.want)
+			}
+		})
+	}
+}
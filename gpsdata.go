@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"sync"
@@ -11,15 +12,27 @@ import (
 // our type
 // gpsData is a structure to control concurrent access to the data from the gps device.
 type gpsData struct {
-	s   string
-	tm  time.Time
-	lat float64
-	lon float64
-	loc string
-	q   string
-	n   int
-	h   float64
-	mu  sync.RWMutex
+	s    string
+	tm   time.Time
+	lat  float64
+	lon  float64
+	loc  string
+	q    string
+	fm   int
+	n    int
+	h    float64
+	o    time.Duration
+	alt  float64
+	spd  float64
+	crs  float64
+	pd   float64
+	vd   float64
+	st   bool
+	at   time.Time
+	sy   time.Time
+	so   time.Duration
+	sats []satellite
+	mu   sync.RWMutex
 }
 
 // newGPSData is for initializing a new gpsData.
@@ -29,13 +42,20 @@ func newGPSData() *gpsData {
 		lon: -181.0,
 		n:   -1,
 		h:   -1.0,
+		alt: math.NaN(),
+		spd: -1.0,
+		crs: -1.0,
+		pd:  -1.0,
+		vd:  -1.0,
 	}
 }
 
 // copy duplicate values from new.
 func (g *gpsData) copy(new *gpsData) {
-	g.mu.RLock()
-	defer g.mu.RUnlock()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	new.mu.RLock()
+	defer new.mu.RUnlock()
 
 	g.s = new.s
 	g.tm = new.tm
@@ -43,8 +63,95 @@ func (g *gpsData) copy(new *gpsData) {
 	g.lat = new.lat
 	g.lon = new.lon
 	g.q = new.q
+	g.fm = new.fm
 	g.n = new.n
 	g.h = new.h
+	g.o = new.o
+	g.alt = new.alt
+	g.spd = new.spd
+	g.crs = new.crs
+	g.pd = new.pd
+	g.vd = new.vd
+	g.st = new.st
+	g.at = new.at
+	g.sy = new.sy
+	g.so = new.so
+	g.sats = new.sats
+}
+
+// gpsValues is a point-in-time copy of the gps data, with exported fields so it can be used in templates.
+type gpsValues struct {
+	Status        string
+	Time          time.Time
+	Gridsquare    string
+	Latitude      float64
+	Longitude     float64
+	FixQuality    string
+	FixMode       int
+	NumSatellites int
+	HDOP          float64
+	ClockOffset   time.Duration
+	Altitude      float64
+	Speed         float64
+	Course        float64
+	PDOP          float64
+	VDOP          float64
+	Stale         bool
+	Attempted     time.Time
+	Synced        time.Time
+	SyncOffset    time.Duration
+	Satellites    []satellite
+}
+
+// values returns a consistent copy of all the values.
+func (g *gpsData) values() gpsValues {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return gpsValues{
+		Status:        g.s,
+		Time:          g.tm,
+		Gridsquare:    g.loc,
+		Latitude:      g.lat,
+		Longitude:     g.lon,
+		FixQuality:    g.q,
+		FixMode:       g.fm,
+		NumSatellites: g.n,
+		HDOP:          g.h,
+		ClockOffset:   g.o,
+		Altitude:      g.alt,
+		Speed:         g.spd,
+		Course:        g.crs,
+		PDOP:          g.pd,
+		VDOP:          g.vd,
+		Stale:         g.st,
+		Attempted:     g.at,
+		Synced:        g.sy,
+		SyncOffset:    g.so,
+		Satellites:    append([]satellite(nil), g.sats...),
+	}
+}
+
+// hasFix returns true if the receiver reported a usable fix.
+func (v gpsValues) hasFix() bool {
+	return v.FixQuality != "" && v.FixQuality != "invalid"
+}
+
+// hasFix returns true if the receiver reported a usable fix.
+func (g *gpsData) hasFix() bool {
+	q := g.getFixQuality()
+
+	return q != "" && q != "invalid"
+}
+
+// isCurrent returns true if the values are from the last attempt, not kept from an earlier one.
+func (g *gpsData) isCurrent() bool {
+	return g.getStatus() == "" && !g.isStale()
+}
+
+// hasCurrentFix returns true if the last attempt got a usable fix.
+func (g *gpsData) hasCurrentFix() bool {
+	return g.isCurrent() && g.hasFix()
 }
 
 // getStatus returns the status.
@@ -63,10 +170,33 @@ func (g *gpsData) setStatus(s string) {
 	g.s = s
 }
 
+// isStale returns true if the values are the last known ones from before we started, not from a poll.
+func (g *gpsData) isStale() bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.st
+}
+
+// setStale sets whether the values are the last known ones.
+func (g *gpsData) setStale(st bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.st = st
+}
+
 // formatStatus returns a string representation of status to show user.
 func (g *gpsData) formatStatus() string {
 	s := g.getStatus()
 
+	if g.isStale() {
+		if s == "" {
+			return "Stale, waiting for a fix"
+		}
+		return strings.ToUpper(s[0:1]) + s[1:] + ", showing last known position"
+	}
+
 	if s == "" {
 		return "OK"
 	}
@@ -94,11 +224,50 @@ func (g *gpsData) formatTime() string {
 	tm := g.getTime()
 
 	if tm != (time.Time{}) {
+		if g.isStale() {
+			return tm.Format("02-Jan-2006 15:04:05 UTC") + " (stale)"
+		}
 		return tm.Format("02-Jan-2006 15:04:05 UTC")
 	}
 	return ""
 }
 
+// formatFixAge returns how long before now the last good fix was to show user.
+func (g *gpsData) formatFixAge(now time.Time) string {
+	tm := g.getTime()
+
+	if tm != (time.Time{}) {
+		return now.Sub(tm).Round(time.Second).String() + " ago"
+	}
+	return ""
+}
+
+// getAttempted returns when the gps device was last polled, whether or not it worked.
+func (g *gpsData) getAttempted() time.Time {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.at
+}
+
+// setAttempted sets when the gps device was last polled.
+func (g *gpsData) setAttempted(t time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.at = t
+}
+
+// formatAttempted returns a string representation of when the gps device was last polled to show user.
+func (g *gpsData) formatAttempted() string {
+	at := g.getAttempted()
+
+	if at != (time.Time{}) {
+		return at.UTC().Format("02-Jan-2006 15:04:05 UTC")
+	}
+	return ""
+}
+
 // getLocation returns the gridsquare.
 func (g *gpsData) getGridsquare() string {
 	g.mu.RLock()
@@ -193,6 +362,22 @@ func (g *gpsData) formatFixQuality() string {
 	return g.getFixQuality()
 }
 
+// getFixMode returns the fix mode from the **GSA sentences, 1 no fix, 2 2D, 3 3D, 0 if the receiver doesn't send them.
+func (g *gpsData) getFixMode() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.fm
+}
+
+// setFixMode sets the fix mode.
+func (g *gpsData) setFixMode(fm int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.fm = fm
+}
+
 // getNumSatellites returns the number of satellites.
 func (g *gpsData) getNumSatellites() int {
 	g.mu.RLock()
@@ -244,3 +429,192 @@ func (g *gpsData) formatHDOP() string {
 	}
 	return ""
 }
+
+// getClockOffset returns the offset between gps time and system time measured before the system time was set.
+func (g *gpsData) getClockOffset() time.Duration {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.o
+}
+
+// setClockOffset sets the clock offset.
+func (g *gpsData) setClockOffset(o time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.o = o
+}
+
+// getAltitude returns the altitude in meters above mean sea level.
+func (g *gpsData) getAltitude() float64 {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.alt
+}
+
+// setAltitude sets the altitude.
+func (g *gpsData) setAltitude(a float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.alt = a
+}
+
+// getSpeed returns the speed over ground in knots.
+func (g *gpsData) getSpeed() float64 {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.spd
+}
+
+// setSpeed sets the speed over ground.
+func (g *gpsData) setSpeed(s float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.spd = s
+}
+
+// getCourse returns the course over ground in degrees.
+func (g *gpsData) getCourse() float64 {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.crs
+}
+
+// setCourse sets the course over ground.
+func (g *gpsData) setCourse(c float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.crs = c
+}
+
+// formatAltitude returns a string representation of the altitude to show user.
+func (g *gpsData) formatAltitude() string {
+	alt := g.getAltitude()
+
+	if !math.IsNaN(alt) {
+		return strconv.FormatFloat(alt, 'f', 1, 64) + " m"
+	}
+	return ""
+}
+
+// formatSpeedCourse returns a string representation of the speed and course over ground to show user.
+func (g *gpsData) formatSpeedCourse() string {
+	spd := g.getSpeed()
+	crs := g.getCourse()
+
+	if spd < 0 {
+		return ""
+	}
+	if crs < 0 {
+		return strconv.FormatFloat(spd, 'f', 1, 64) + " kn"
+	}
+	return strconv.FormatFloat(spd, 'f', 1, 64) + " kn, " + strconv.FormatFloat(crs, 'f', 1, 64) + "°"
+}
+
+// getPDOP returns the position dilution of precision.
+func (g *gpsData) getPDOP() float64 {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.pd
+}
+
+// setPDOP sets the position dilution of precision.
+func (g *gpsData) setPDOP(p float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.pd = p
+}
+
+// getVDOP returns the vertical dilution of precision.
+func (g *gpsData) getVDOP() float64 {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.vd
+}
+
+// setVDOP sets the vertical dilution of precision.
+func (g *gpsData) setVDOP(v float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.vd = v
+}
+
+// formatPDOPVDOP returns a string representation of the position and vertical dilutions of precision to show user.
+func (g *gpsData) formatPDOPVDOP() string {
+	p := g.getPDOP()
+	v := g.getVDOP()
+
+	if p > -1 && v > -1 {
+		return strconv.FormatFloat(p, 'f', -1, 64) + " / " + strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+// getSynced returns when the system time was last set and how far off it was then.
+func (g *gpsData) getSynced() (time.Time, time.Duration) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.sy, g.so
+}
+
+// setSynced sets when the system time was last set and how far off it was.
+func (g *gpsData) setSynced(t time.Time, o time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.sy = t
+	g.so = o
+}
+
+// formatSynced returns a string representation of when the system time was last set to show user.
+func (g *gpsData) formatSynced() string {
+	sy, _ := g.getSynced()
+
+	if sy != (time.Time{}) {
+		return sy.UTC().Format("02-Jan-2006 15:04:05 UTC")
+	}
+	return ""
+}
+
+// formatSyncOffset returns a string representation of how far off the system time was when it was last set to show user.
+func (g *gpsData) formatSyncOffset() string {
+	sy, o := g.getSynced()
+
+	switch {
+	case sy == (time.Time{}):
+		return ""
+	case o < 0:
+		return (-o).Round(time.Millisecond).String() + " ahead"
+	case o > 0:
+		return o.Round(time.Millisecond).String() + " behind"
+	}
+	return "0s"
+}
+
+// getSatellites returns the satellites in view.
+func (g *gpsData) getSatellites() []satellite {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return append([]satellite(nil), g.sats...)
+}
+
+// setSatellites sets the satellites in view.
+func (g *gpsData) setSatellites(sats []satellite) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.sats = sats
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	// start of the GPX files we write, up to the track segment the points go in
+	gpxHead = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="gps-qth-qtr" xmlns="http://www.topografix.com/GPX/1/1" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://www.topografix.com/GPX/1/1 http://www.topografix.com/GPX/1/1/gpx.xsd">
+<trk>
+<name>%s</name>
+<trkseg>
+`
+
+	// end of the GPX files we write, always after the last point
+	gpxTail = "</trkseg>\n</trk>\n</gpx>\n"
+)
+
+// gpxPoint is a GPX 1.1 waypoint, the optional values are nil when not known.
+type gpxPoint struct {
+	Lat  float64   `xml:"lat,attr"`
+	Lon  float64   `xml:"lon,attr"`
+	Ele  *float64  `xml:"ele,omitempty"`
+	Time time.Time `xml:"time"`
+	Sat  *int      `xml:"sat,omitempty"`
+	HDOP *float64  `xml:"hdop,omitempty"`
+}
+
+// gpxSegment is a GPX 1.1 track segment.
+type gpxSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+// gpxTrack is a GPX 1.1 track.
+type gpxTrack struct {
+	Name     string       `xml:"name,omitempty"`
+	Segments []gpxSegment `xml:"trkseg"`
+}
+
+// gpxFile is the part of a GPX 1.1 file we use.
+type gpxFile struct {
+	XMLName xml.Name   `xml:"gpx"`
+	Tracks  []gpxTrack `xml:"trk"`
+}
+
+// readGPXTrack returns all the track points in the GPX file in r in time order, points without a time are skipped.
+func readGPXTrack(r io.Reader) ([]gpxPoint, error) {
+	var f gpxFile
+
+	err := xml.NewDecoder(r).Decode(&f)
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	var pts []gpxPoint
+	for _, t := range f.Tracks {
+		for _, s := range t.Segments {
+			for _, p := range s.Points {
+				if !p.Time.IsZero() {
+					pts = append(pts, p)
+				}
+			}
+		}
+	}
+
+	if len(pts) == 0 {
+		err = fmt.Errorf("GPX file has no timestamped track points")
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	sort.SliceStable(pts, func(i, j int) bool {
+		return pts[i].Time.Before(pts[j].Time)
+	})
+
+	return pts, nil
+}
+
+// readGPXFiles returns the track points of all the GPX files in fns in time order.
+func readGPXFiles(fns []string) ([]gpxPoint, error) {
+	var all []gpxPoint
+
+	for _, fn := range fns {
+		// #nosec G304
+		f, err := os.Open(fn)
+		if err != nil {
+			log.Printf("%+v", err)
+			return nil, err
+		}
+
+		pts, err := readGPXTrack(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, pts...)
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Time.Before(all[j].Time)
+	})
+
+	return all, nil
+}
+
+// nearestGPXPoint returns the point in pts, which must be in time order, closest in time to t.
+func nearestGPXPoint(pts []gpxPoint, t time.Time) gpxPoint {
+	i := sort.Search(len(pts), func(i int) bool {
+		return !pts[i].Time.Before(t)
+	})
+
+	if i == 0 {
+		return pts[0]
+	}
+	if i == len(pts) {
+		return pts[len(pts)-1]
+	}
+	if t.Sub(pts[i-1].Time) <= pts[i].Time.Sub(t) {
+		return pts[i-1]
+	}
+	return pts[i]
+}
+
+// encodeGPXPoint returns p as a trkpt element on its own line.
+func encodeGPXPoint(p gpxPoint) ([]byte, error) {
+	var b bytes.Buffer
+
+	p.Time = p.Time.UTC()
+	err := xml.NewEncoder(&b).EncodeElement(p, xml.StartElement{Name: xml.Name{Local: "trkpt"}})
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+	b.WriteByte('\n')
+
+	return b.Bytes(), nil
+}
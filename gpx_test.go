@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_readGPXTrack(t *testing.T) {
+	gpx := `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="gps-qth-qtr" xmlns="http://www.topografix.com/GPX/1/1">
+<trk><trkseg>
+<trkpt lat="38.92" lon="-77.065"><ele>85.5</ele><time>2024-06-01T14:10:00Z</time><sat>9</sat><hdop>0.9</hdop></trkpt>
+<trkpt lat="38.5" lon="-77.5"><time>2024-06-01T14:00:00Z</time></trkpt>
+<trkpt lat="0" lon="0"></trkpt>
+</trkseg></trk>
+</gpx>`
+
+	pts, err := readGPXTrack(strings.NewReader(gpx))
+	if err != nil {
+		t.Fatalf("readGPXTrack() error = %v", err)
+	}
+	if len(pts) != 2 {
+		t.Fatalf("readGPXTrack() = %d points, want 2", len(pts))
+	}
+
+	// sorted by time
+	if pts[0].Lat != 38.5 || pts[1].Lat != 38.92 {
+		t.Errorf("readGPXTrack() = %+v, not in time order", pts)
+	}
+	if pts[1].Ele == nil || *pts[1].Ele != 85.5 || pts[1].Sat == nil || *pts[1].Sat != 9 || pts[1].HDOP == nil || *pts[1].HDOP != 0.9 {
+		t.Errorf("readGPXTrack() = %+v, want ele, sat and hdop", pts[1])
+	}
+	if pts[0].Ele != nil {
+		t.Errorf("readGPXTrack() ele = %v, want nil", *pts[0].Ele)
+	}
+
+	_, err = readGPXTrack(strings.NewReader(`<gpx version="1.1"></gpx>`))
+	if err == nil {
+		t.Errorf("readGPXTrack() error = nil, want error for no points")
+	}
+}
+
+func Test_nearestGPXPoint(t *testing.T) {
+	base := time.Date(2024, 6, 1, 14, 0, 0, 0, time.UTC)
+	pts := []gpxPoint{
+		{Lat: 1, Time: base},
+		{Lat: 2, Time: base.Add(10 * time.Minute)},
+		{Lat: 3, Time: base.Add(20 * time.Minute)},
+	}
+
+	tests := []struct {
+		name string
+		t    time.Time
+		want float64
+	}{
+		{name: "Before", t: base.Add(-time.Hour), want: 1},
+		{name: "After", t: base.Add(time.Hour), want: 3},
+		{name: "Exact", t: base.Add(10 * time.Minute), want: 2},
+		{name: "Closer to earlier", t: base.Add(14 * time.Minute), want: 2},
+		{name: "Closer to later", t: base.Add(16 * time.Minute), want: 3},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			if got := nearestGPXPoint(pts, ttt.t); got.Lat != ttt.want {
+				t.Errorf("nearestGPXPoint() = %v, want %v", got.Lat, ttt.want)
+			}
+		})
+	}
+}
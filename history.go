@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// historyConfig is the configuration of the poll history.
+type historyConfig struct {
+	Size int
+}
+
+// defaultHistorySize is how many polls are kept when the size isn't configured.
+const defaultHistorySize = 100
+
+// size returns how many polls to keep.
+func (c historyConfig) size() int {
+	if c.Size > 0 {
+		return c.Size
+	}
+	return defaultHistorySize
+}
+
+// pollRecord is the outcome of one gatherGpsData run.
+type pollRecord struct {
+	Time     time.Time
+	Duration time.Duration
+	Err      string
+	Reason   string
+	Values   gpsValues
+}
+
+// ok returns true if the poll succeeded.
+func (r pollRecord) ok() bool {
+	return r.Err == ""
+}
+
+// pollHistory is a ring buffer of the most recent polls, kept apart from the current gps data.
+type pollHistory struct {
+	records  []pollRecord
+	next     int
+	full     bool
+	lastGood pollRecord
+	mu       sync.RWMutex
+}
+
+var (
+	// recent polls of the gps device.
+	history = newPollHistory(defaultHistorySize)
+)
+
+// newPollHistory returns an empty history that keeps the last size polls.
+func newPollHistory(size int) *pollHistory {
+	if size < 1 {
+		size = 1
+	}
+
+	return &pollHistory{
+		records: make([]pollRecord, size),
+	}
+}
+
+// add keeps r, replacing the oldest poll if the history is full.
+func (h *pollHistory) add(r pollRecord) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.records[h.next] = r
+	h.next++
+	if h.next == len(h.records) {
+		h.next = 0
+		h.full = true
+	}
+
+	if r.ok() {
+		h.lastGood = r
+	}
+}
+
+// resize keeps the last size polls from now on, along with as many of the most recent ones already kept as fit.
+func (h *pollHistory) resize(size int) {
+	if size < 1 {
+		size = 1
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if size == len(h.records) {
+		return
+	}
+
+	rs := h.newestFirst()
+	if len(rs) > size {
+		rs = rs[:size]
+	}
+
+	h.records = make([]pollRecord, size)
+	h.next = 0
+	h.full = false
+	for i := len(rs) - 1; i >= 0; i-- {
+		h.records[h.next] = rs[i]
+		h.next++
+	}
+	if h.next == size {
+		h.next = 0
+		h.full = true
+	}
+}
+
+// recent returns the polls in the history, newest first.
+func (h *pollHistory) recent() []pollRecord {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.newestFirst()
+}
+
+// newestFirst returns a copy of the polls in the history, newest first, h.mu must be held.
+func (h *pollHistory) newestFirst() []pollRecord {
+	n := h.next
+	if h.full {
+		n = len(h.records)
+	}
+
+	rs := make([]pollRecord, 0, n)
+	for i := 1; i <= n; i++ {
+		rs = append(rs, h.records[(h.next-i+len(h.records))%len(h.records)])
+	}
+	return rs
+}
+
+// getLastGood returns the most recent successful poll, which is kept even after it leaves the history
+// false if there hasn't been one.
+func (h *pollHistory) getLastGood() (pollRecord, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.lastGood, !h.lastGood.Time.IsZero()
+}
+
+// recordHistory adds the outcome of a gatherGpsData run that finished at now, took d and ended with err and v, to the global history.
+func recordHistory(now time.Time, d time.Duration, err error, v gpsValues) {
+	r := pollRecord{
+		Time:     now,
+		Duration: d,
+		Values:   v,
+	}
+	if err != nil {
+		r.Err = err.Error()
+		r.Reason = failureReason(err)
+	}
+
+	history.add(r)
+}
+
+// formatLastGood returns a string representation of the last good fix to show user.
+func (h *pollHistory) formatLastGood() string {
+	r, ok := h.getLastGood()
+	if !ok {
+		return ""
+	}
+
+	return fmt.Sprintf("%s at %s", r.Values.Gridsquare, r.Values.Time.Format("02-Jan-2006 15:04:05 UTC"))
+}
+
+// formatRecent returns a string representation of how the recent polls went to show user.
+func (h *pollHistory) formatRecent() string {
+	rs := h.recent()
+	if len(rs) == 0 {
+		return ""
+	}
+
+	var good int
+	var lastErr string
+	for _, r := range rs {
+		if r.ok() {
+			good++
+		} else if lastErr == "" {
+			lastErr = r.Err
+		}
+	}
+
+	s := fmt.Sprintf("%d of %d OK", good, len(rs))
+	if lastErr != "" {
+		s += ", last error: " + lastErr
+	}
+	return s
+}
+
+// apiPoll is the JSON representation of a poll.
+type apiPoll struct {
+	Time     time.Time  `json:"time"`
+	Duration float64    `json:"durationSeconds"`
+	OK       bool       `json:"ok"`
+	Reason   string     `json:"reason,omitempty"`
+	Error    string     `json:"error,omitempty"`
+	Fix      *apiStatus `json:"fix,omitempty"`
+}
+
+// apiHistory is the JSON representation of the history.
+type apiHistory struct {
+	LastGood *apiPoll  `json:"lastGood"`
+	Polls    []apiPoll `json:"polls"`
+}
+
+// newAPIPoll converts r to its JSON representation, the fix is only included for successful polls.
+func newAPIPoll(r pollRecord) apiPoll {
+	a := apiPoll{
+		Time:     r.Time,
+		Duration: r.Duration.Seconds(),
+		OK:       r.ok(),
+		Reason:   r.Reason,
+		Error:    r.Err,
+	}
+	if r.ok() {
+		s := newAPIStatus(r.Values)
+		a.Fix = &s
+	}
+	return a
+}
+
+// newAPIHistory converts h to its JSON representation.
+func newAPIHistory(h *pollHistory) apiHistory {
+	a := apiHistory{
+		Polls: []apiPoll{},
+	}
+
+	if r, ok := h.getLastGood(); ok {
+		p := newAPIPoll(r)
+		a.LastGood = &p
+	}
+	for _, r := range h.recent() {
+		a.Polls = append(a.Polls, newAPIPoll(r))
+	}
+
+	return a
+}
+
+// handleHistory returns the recent polls and the last good fix.
+func handleHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newAPIHistory(history))
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func Test_pollHistory(t *testing.T) {
+	h := newPollHistory(3)
+
+	if _, ok := h.getLastGood(); ok || len(h.recent()) != 0 || h.formatRecent() != "" || h.formatLastGood() != "" {
+		t.Fatalf("newPollHistory() isn't empty")
+	}
+
+	base := time.Date(2024, 6, 1, 14, 0, 0, 0, time.UTC)
+	good := newGPSData()
+	good.setGridsquare("FM18lw")
+	good.setTime(base)
+
+	h.add(pollRecord{Time: base, Values: good.values()})
+	for i := 1; i <= 3; i++ {
+		h.add(pollRecord{Time: base.Add(time.Duration(i) * time.Minute), Err: fmt.Sprintf("error %d", i), Reason: reasonOther})
+	}
+
+	// oldest is gone, newest first
+	rs := h.recent()
+	if len(rs) != 3 || rs[0].Err != "error 3" || rs[2].Err != "error 1" {
+		t.Errorf("recent() = %+v", rs)
+	}
+
+	// last good is kept after it leaves the buffer
+	r, ok := h.getLastGood()
+	if !ok || r.Values.Gridsquare != "FM18lw" {
+		t.Errorf("getLastGood() = %+v, %v", r, ok)
+	}
+	if got := h.formatLastGood(); got != "FM18lw at 01-Jun-2024 14:00:00 UTC" {
+		t.Errorf("formatLastGood() = %v", got)
+	}
+	if got := h.formatRecent(); got != "0 of 3 OK, last error: error 3" {
+		t.Errorf("formatRecent() = %v", got)
+	}
+
+	h.add(pollRecord{Time: base.Add(time.Hour), Values: good.values()})
+	if got := h.formatRecent(); got != "1 of 3 OK, last error: error 3" {
+		t.Errorf("formatRecent() = %v", got)
+	}
+}
+
+func Test_pollHistory_resize(t *testing.T) {
+	base := time.Date(2024, 6, 1, 14, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		size int
+		// errors of the polls kept, newest first
+		want []string
+	}{
+		{name: "Same", size: 4, want: []string{"error 5", "error 4", "error 3", "error 2"}},
+		{name: "Smaller", size: 2, want: []string{"error 5", "error 4"}},
+		{name: "Larger", size: 6, want: []string{"error 5", "error 4", "error 3", "error 2"}},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			h := newPollHistory(4)
+			h.add(pollRecord{Time: base})
+			for i := 1; i <= 5; i++ {
+				h.add(pollRecord{Time: base.Add(time.Duration(i) * time.Minute), Err: fmt.Sprintf("error %d", i)})
+			}
+
+			h.resize(ttt.size)
+
+			var got []string
+			for _, r := range h.recent() {
+				got = append(got, r.Err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(ttt.want) {
+				t.Errorf("resize() recent = %q, want %q", got, ttt.want)
+			}
+			if _, ok := h.getLastGood(); !ok {
+				t.Errorf("resize() lost the last good poll")
+			}
+
+			// and new polls replace the oldest at the new size
+			for i := 6; i < 6+ttt.size; i++ {
+				h.add(pollRecord{Time: base.Add(time.Duration(i) * time.Minute), Err: fmt.Sprintf("error %d", i)})
+			}
+			rs := h.recent()
+			if len(rs) != ttt.size || rs[0].Err != fmt.Sprintf("error %d", 5+ttt.size) {
+				t.Errorf("resize() then add recent = %+v", rs)
+			}
+		})
+	}
+}
+
+func Test_pollHistory_resize_add(t *testing.T) {
+	h := newPollHistory(500)
+
+	// add polls while the history is resized
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for i := 0; i < 200; i++ {
+			h.add(pollRecord{Time: time.Now(), Err: fmt.Sprintf("error %d", i)})
+		}
+	}()
+
+	for i := 0; i < 200; i++ {
+		h.resize(500 + i%2)
+	}
+	<-done
+
+	if got := len(h.recent()); got != 200 {
+		t.Errorf("recent() = %d polls, want %d", got, 200)
+	}
+}
+
+func Test_newAPIHistory(t *testing.T) {
+	h := newPollHistory(5)
+
+	b, err := json.Marshal(newAPIHistory(h))
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	if string(b) != `{"lastGood":null,"polls":[]}` {
+		t.Errorf("newAPIHistory() = %s", b)
+	}
+
+	base := time.Date(2024, 6, 1, 14, 0, 0, 0, time.UTC)
+	g := newGPSData()
+	g.setGridsquare("FM18lw")
+	h.add(pollRecord{Time: base, Duration: 1500 * time.Millisecond, Values: g.values()})
+	h.add(pollRecord{Time: base.Add(time.Minute), Duration: time.Second, Err: "GGA line bad checksum", Reason: reasonBadChecksum, Values: newGPSData().values()})
+
+	a := newAPIHistory(h)
+	if a.LastGood == nil || a.LastGood.Fix == nil || *a.LastGood.Fix.Gridsquare != "FM18lw" || a.LastGood.Duration != 1.5 {
+		t.Errorf("newAPIHistory() lastGood = %+v", a.LastGood)
+	}
+	if len(a.Polls) != 2 || a.Polls[0].OK || a.Polls[0].Reason != reasonBadChecksum || a.Polls[0].Fix != nil || !a.Polls[1].OK {
+		t.Errorf("newAPIHistory() polls = %+v", a.Polls)
+	}
+}
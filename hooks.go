@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// hookConfig is the configuration of an external command or HTTP POST to run when an event occurs.
+type hookConfig struct {
+	Event       string
+	Command     []string
+	URL         string
+	Body        string
+	ContentType string
+	Timeout     time.Duration
+	Retries     int
+}
+
+// hook is a hookConfig with its templates parsed and ready to run.
+type hook struct {
+	event       gpsEvent
+	command     []*template.Template
+	url         *template.Template
+	body        *template.Template
+	contentType string
+	timeout     time.Duration
+	retries     int
+}
+
+// hookData is what hook templates are rendered with.
+type hookData struct {
+	Event string
+	gpsValues
+}
+
+var (
+	// hooks from the application configuration.
+	hooks   []*hook
+	hooksMu sync.RWMutex
+
+	// delay between hook attempts.
+	hookRetryDelay = 5 * time.Second
+)
+
+// currentHooks returns the hooks from the application configuration.
+func currentHooks() []*hook {
+	hooksMu.RLock()
+	defer hooksMu.RUnlock()
+
+	return hooks
+}
+
+// setHooks replaces the hooks from the application configuration.
+func setHooks(hs []*hook) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+
+	hooks = hs
+}
+
+// newHook validates hc and parses its templates.
+func newHook(hc hookConfig) (*hook, error) {
+	h := &hook{
+		event:       gpsEvent(hc.Event),
+		contentType: hc.ContentType,
+		timeout:     hc.Timeout * time.Second,
+		retries:     hc.Retries,
+	}
+
+	switch h.event {
+	case eventGridChanged, eventFixLost, eventFixAcquired, eventClockStepped:
+	default:
+		return nil, fmt.Errorf("hook has unknown event %q", hc.Event)
+	}
+
+	if (len(hc.Command) > 0) == (hc.URL != "") {
+		return nil, fmt.Errorf("%s hook must have either a command or a url", hc.Event)
+	}
+
+	if h.timeout <= 0 {
+		h.timeout = 10 * time.Second
+	}
+	if h.retries < 0 {
+		h.retries = 0
+	}
+	if h.contentType == "" {
+		h.contentType = "application/json"
+	}
+
+	var err error
+	for i, a := range hc.Command {
+		var t *template.Template
+		t, err = template.New(fmt.Sprintf("%s command %d", hc.Event, i)).Parse(a)
+		if err != nil {
+			return nil, err
+		}
+		h.command = append(h.command, t)
+	}
+
+	if hc.URL != "" {
+		h.url, err = template.New(hc.Event + " url").Parse(hc.URL)
+		if err != nil {
+			return nil, err
+		}
+
+		h.body, err = template.New(hc.Event + " body").Parse(hc.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return h, nil
+}
+
+// initHooks prepares the hooks from the application configuration.
+func initHooks(hcs []hookConfig) error {
+	hs := make([]*hook, 0, len(hcs))
+
+	for _, hc := range hcs {
+		h, err := newHook(hc)
+		if err != nil {
+			log.Printf("%+v", err)
+			return err
+		}
+		hs = append(hs, h)
+	}
+
+	setHooks(hs)
+	return nil
+}
+
+// render executes the template with data and returns the result.
+func render(t *template.Template, data hookData) (string, error) {
+	var b bytes.Buffer
+
+	err := t.Execute(&b, data)
+	if err != nil {
+		log.Printf("%+v", err)
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// execute does one attempt of running the hook.
+func (h *hook) execute(data hookData) error {
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	if h.url != nil {
+		u, err := render(h.url, data)
+		if err != nil {
+			return err
+		}
+
+		b, err := render(h.body, data)
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequest(http.MethodPost, u, bytes.NewBufferString(b))
+		if err != nil {
+			log.Printf("%+v", err)
+			return err
+		}
+		req.Header.Set("Content-Type", h.contentType)
+
+		resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+		if err != nil {
+			log.Printf("%+v", err)
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			err = fmt.Errorf("%s hook POST to %s returned %s", h.event, u, resp.Status)
+			log.Printf("%+v", err)
+			return err
+		}
+		return nil
+	}
+
+	args := make([]string, 0, len(h.command))
+	for _, t := range h.command {
+		a, err := render(t, data)
+		if err != nil {
+			return err
+		}
+		args = append(args, a)
+	}
+
+	// #nosec G204
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		log.Printf("%+v|%s", err, out)
+		return err
+	}
+	return nil
+}
+
+// run executes the hook, retrying on failure.
+func (h *hook) run(data hookData) error {
+	var err error
+
+	for attempt := 0; attempt <= h.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(hookRetryDelay)
+		}
+
+		err = h.execute(data)
+		if err == nil {
+			return nil
+		}
+	}
+
+	log.Printf("%s hook failed after %d attempts", h.event, h.retries+1)
+	return err
+}
+
+// runHooks starts all the hooks for events in the background.
+func runHooks(events []gpsEvent, v gpsValues) {
+	hs := currentHooks()
+
+	for _, e := range events {
+		for _, h := range hs {
+			if h.event == e {
+				hh := h
+				data := hookData{Event: string(e), gpsValues: v}
+
+				go func() {
+					_ = hh.run(data)
+				}()
+			}
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_newHook(t *testing.T) {
+	type args struct {
+		hc hookConfig
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name:    "Command",
+			args:    args{hc: hookConfig{Event: "gridchanged", Command: []string{"logger", "{{.Gridsquare}}"}}},
+			wantErr: false,
+		},
+		{
+			name:    "URL",
+			args:    args{hc: hookConfig{Event: "fixlost", URL: "http://localhost/{{.Event}}", Body: `{"grid":"{{.Gridsquare}}"}`}},
+			wantErr: false,
+		},
+		{
+			name:    "Unknown event",
+			args:    args{hc: hookConfig{Event: "moved", Command: []string{"logger"}}},
+			wantErr: true,
+		},
+		{
+			name:    "No action",
+			args:    args{hc: hookConfig{Event: "fixacquired"}},
+			wantErr: true,
+		},
+		{
+			name:    "Both actions",
+			args:    args{hc: hookConfig{Event: "fixacquired", Command: []string{"logger"}, URL: "http://localhost"}},
+			wantErr: true,
+		},
+		{
+			name:    "Bad template",
+			args:    args{hc: hookConfig{Event: "clockstepped", URL: "http://localhost", Body: "{{.Gridsquare"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			_, err := newHook(ttt.args.hc)
+			if (err != nil) != ttt.wantErr {
+				t.Errorf("newHook() error = %v, wantErr %v", err, ttt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_hook_run(t *testing.T) {
+	prev := hookRetryDelay
+	defer func() {
+		hookRetryDelay = prev
+	}()
+	hookRetryDelay = 0
+
+	var calls int32
+	bodies := make(chan string, 2)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// fail the first attempt
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies <- string(b)
+	}))
+	defer ts.Close()
+
+	h, err := newHook(hookConfig{
+		Event:   "gridchanged",
+		URL:     ts.URL,
+		Body:    `{{.Event}} {{.Gridsquare}} {{.NumSatellites}} {{.Time.Format "15:04"}}`,
+		Retries: 1,
+	})
+	if err != nil {
+		t.Fatalf("newHook() error = %v", err)
+	}
+
+	data := hookData{
+		Event: "gridchanged",
+		gpsValues: gpsValues{
+			Gridsquare:    "FM18lw",
+			NumSatellites: 9,
+			Time:          time.Date(2020, time.Month(1), 18, 20, 34, 34, 0, time.UTC),
+		},
+	}
+
+	err = h.run(data)
+	if err != nil {
+		t.Fatalf("hook.run() error = %v", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("hook.run() calls = %v, want %v", got, 2)
+	}
+	select {
+	case body := <-bodies:
+		if body != "gridchanged FM18lw 9 20:34" {
+			t.Errorf("hook.run() body = %v, want %v", body, "gridchanged FM18lw 9 20:34")
+		}
+	default:
+		t.Errorf("hook.run() body wasn't sent")
+	}
+}
+
+func Test_runHooks_reload(t *testing.T) {
+	defer setHooks(currentHooks())
+
+	hcs := []hookConfig{
+		{
+			Event:   "fixlost",
+			Command: []string{"true"},
+		},
+	}
+
+	// reload the hooks while polls are running them
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for i := 0; i < 100; i++ {
+			err := initHooks(hcs)
+			if err != nil {
+				t.Errorf("initHooks() error = %v", err)
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		runHooks([]gpsEvent{eventGridChanged}, gpsValues{Gridsquare: "FM18lw"})
+	}
+	<-done
+
+	if got := len(currentHooks()); got != 1 {
+		t.Errorf("currentHooks() = %d hooks, want %d", got, 1)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// js8callConfig is the configuration of the JS8Call integration.
+type js8callConfig struct {
+	Address    string
+	GridLength int
+	Timeout    time.Duration
+}
+
+// js8callMessage is a message in the JS8Call API.
+type js8callMessage struct {
+	Type   string                 `json:"type"`
+	Value  string                 `json:"value"`
+	Params map[string]interface{} `json:"params"`
+}
+
+// js8callIntegration keeps the grid of a JS8Call instance up to date.
+type js8callIntegration struct {
+	cfg    js8callConfig
+	grid   string
+	status string
+	quit   chan struct{}
+	done   chan struct{}
+	mu     sync.RWMutex
+}
+
+var (
+	// configured JS8Call integration, nil if there isn't one.
+	js8call   *js8callIntegration
+	js8callMu sync.RWMutex
+)
+
+// currentJS8Call returns the configured JS8Call integration, nil if there isn't one.
+func currentJS8Call() *js8callIntegration {
+	js8callMu.RLock()
+	defer js8callMu.RUnlock()
+
+	return js8call
+}
+
+// setJS8Call replaces the configured JS8Call integration.
+func setJS8Call(ji *js8callIntegration) {
+	js8callMu.Lock()
+	defer js8callMu.Unlock()
+
+	js8call = ji
+}
+
+// newJS8CallIntegration validates cfg and returns an integration ready to start.
+func newJS8CallIntegration(cfg js8callConfig) (*js8callIntegration, error) {
+	switch cfg.GridLength {
+	case 0:
+		cfg.GridLength = 6
+	case 4, 6, 8:
+	default:
+		err := fmt.Errorf("JS8Call grid length must be 4, 6 or 8")
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10
+	}
+	cfg.Timeout *= time.Second
+
+	_, _, err := net.SplitHostPort(cfg.Address)
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	return &js8callIntegration{
+		cfg:    cfg,
+		status: "Waiting for gridsquare",
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// setStatus sets the status.
+func (ji *js8callIntegration) setStatus(s string) {
+	ji.mu.Lock()
+	defer ji.mu.Unlock()
+
+	ji.status = s
+}
+
+// formatStatus returns a string representation of the connection status to show user.
+func (ji *js8callIntegration) formatStatus() string {
+	ji.mu.RLock()
+	defer ji.mu.RUnlock()
+
+	return ji.status
+}
+
+// sendGrid connects to JS8Call, sets its grid and waits for it to confirm the change.
+func (ji *js8callIntegration) sendGrid(grid string) error {
+	conn, err := net.DialTimeout("tcp", ji.cfg.Address, ji.cfg.Timeout)
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(ji.cfg.Timeout))
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+
+	id := strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)
+
+	enc := json.NewEncoder(conn)
+	for _, m := range []js8callMessage{
+		{Type: "STATION.SET_GRID", Value: grid, Params: map[string]interface{}{}},
+		{Type: "STATION.GET_GRID", Params: map[string]interface{}{"_ID": id}},
+	} {
+		err = enc.Encode(m)
+		if err != nil {
+			log.Printf("%+v", err)
+			return err
+		}
+	}
+
+	// js8call sends other messages whenever it likes, so look for the answer
+	s := bufio.NewScanner(conn)
+	for s.Scan() {
+		var m js8callMessage
+		err = json.Unmarshal(s.Bytes(), &m)
+		if err != nil || m.Type != "STATION.GRID" {
+			continue
+		}
+
+		if m.Value != grid {
+			err = fmt.Errorf("JS8Call grid is %s, not %s", m.Value, grid)
+			log.Printf("%+v", err)
+			return err
+		}
+		return nil
+	}
+
+	err = s.Err()
+	if err == nil {
+		err = fmt.Errorf("JS8Call closed the connection")
+	}
+	log.Printf("%+v", err)
+	return err
+}
+
+// update sends the grid for v to JS8Call if it hasn't already been set.
+func (ji *js8callIntegration) update(v gpsValues) {
+	// don't send the last known grid from before we started
+	if v.Stale {
+		return
+	}
+
+	grid := gridsquareOfLength(v, ji.cfg.GridLength)
+	if grid == "" || grid == ji.grid {
+		return
+	}
+
+	err := ji.sendGrid(grid)
+	if err != nil {
+		ji.setStatus("Error: " + err.Error())
+		return
+	}
+
+	ji.grid = grid
+	ji.setStatus(fmt.Sprintf("Grid set to %s at %s", grid, time.Now().UTC().Format("15:04:05 UTC")))
+}
+
+// start keeps JS8Call up to date in the background until stop is called
+// failures are retried after every poll.
+func (ji *js8callIntegration) start() {
+	updates, unsubscribe := subscribe()
+
+	go func() {
+		defer close(ji.done)
+		defer unsubscribe()
+
+		ji.update(gpsdata.values())
+		for {
+			select {
+			case u := <-updates:
+				ji.update(u.values)
+			case <-ji.quit:
+				return
+			}
+		}
+	}()
+}
+
+// stop waits for the background work to finish.
+func (ji *js8callIntegration) stop() {
+	close(ji.quit)
+	<-ji.done
+}
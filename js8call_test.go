@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+)
+
+// js8callTestServer is a fake JS8Call API that keeps the grid it is sent.
+type js8callTestServer struct {
+	l    net.Listener
+	grid string
+}
+
+func newJS8CallTestServer(t *testing.T) *js8callTestServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+
+	srv := &js8callTestServer{l: l, grid: "FN42"}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			enc := json.NewEncoder(conn)
+
+			// unsolicited message
+			_ = enc.Encode(js8callMessage{Type: "RIG.FREQ", Params: map[string]interface{}{"DIAL": 14078000}})
+
+			s := bufio.NewScanner(conn)
+			for s.Scan() {
+				var m js8callMessage
+				if json.Unmarshal(s.Bytes(), &m) != nil {
+					break
+				}
+
+				switch m.Type {
+				case "STATION.SET_GRID":
+					srv.grid = m.Value
+				case "STATION.GET_GRID":
+					_ = enc.Encode(js8callMessage{Type: "STATION.GRID", Value: srv.grid, Params: m.Params})
+				}
+			}
+			conn.Close()
+		}
+	}()
+
+	return srv
+}
+
+func Test_js8callIntegration_update(t *testing.T) {
+	srv := newJS8CallTestServer(t)
+	defer srv.l.Close()
+
+	g := newGPSData()
+	g.setGridsquare("FM18lw")
+	g.setLatitude(38.92)
+	g.setLongitude(-77.065)
+
+	tests := []struct {
+		name       string
+		gridLength int
+		want       string
+	}{
+		{name: "4 characters", gridLength: 4, want: "FM18"},
+		{name: "6 characters", gridLength: 6, want: "FM18lw"},
+		{name: "8 characters", gridLength: 8, want: "FM18lw20"},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			ji, err := newJS8CallIntegration(js8callConfig{Address: srv.l.Addr().String(), GridLength: ttt.gridLength})
+			if err != nil {
+				t.Fatalf("newJS8CallIntegration() error = %v", err)
+			}
+
+			ji.update(g.values())
+			if srv.grid != ttt.want {
+				t.Errorf("update() grid = %v, want %v", srv.grid, ttt.want)
+			}
+			if !strings.HasPrefix(ji.formatStatus(), "Grid set to "+ttt.want) {
+				t.Errorf("formatStatus() = %v", ji.formatStatus())
+			}
+		})
+	}
+}
+
+func Test_js8callIntegration_unavailable(t *testing.T) {
+	// nothing listening on the port
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	address := l.Addr().String()
+	l.Close()
+
+	ji, err := newJS8CallIntegration(js8callConfig{Address: address})
+	if err != nil {
+		t.Fatalf("newJS8CallIntegration() error = %v", err)
+	}
+
+	v := newGPSData().values()
+	ji.update(v)
+	if ji.formatStatus() != "Waiting for gridsquare" {
+		t.Errorf("formatStatus() = %v, want %v", ji.formatStatus(), "Waiting for gridsquare")
+	}
+
+	v.Gridsquare = "FM18lw"
+	ji.update(v)
+	if !strings.HasPrefix(ji.formatStatus(), "Error: ") {
+		t.Errorf("formatStatus() = %v, want error", ji.formatStatus())
+	}
+	if ji.grid != "" {
+		t.Errorf("grid = %v, want it to be retried", ji.grid)
+	}
+}
+
+func Test_newJS8CallIntegration(t *testing.T) {
+	_, err := newJS8CallIntegration(js8callConfig{Address: "127.0.0.1:2442", GridLength: 5})
+	if err == nil {
+		t.Errorf("newJS8CallIntegration() error = nil, want error for grid length")
+	}
+
+	_, err = newJS8CallIntegration(js8callConfig{Address: "localhost"})
+	if err == nil {
+		t.Errorf("newJS8CallIntegration() error = nil, want error for address")
+	}
+}
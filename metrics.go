@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// pollMetrics accumulates what happened in gatherGpsData runs.
+type pollMetrics struct {
+	started   time.Time
+	successes uint64
+	failures  map[string]uint64
+	lastFix   time.Time
+	offset    float64
+	buckets   []float64
+	counts    []uint64
+	count     uint64
+	sum       float64
+	mu        sync.Mutex
+}
+
+// failure reasons for gatherGpsData runs.
+const (
+	reasonPortError    = "port_error"
+	reasonBadChecksum  = "bad_checksum"
+	reasonInvalidState = "invalid_state"
+	reasonOther        = "other"
+)
+
+var (
+	// metrics about polling the gps device.
+	metrics = newPollMetrics()
+)
+
+// newPollMetrics is for initializing a new pollMetrics.
+func newPollMetrics() *pollMetrics {
+	buckets := []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+	return &pollMetrics{
+		started:  time.Now(),
+		failures: make(map[string]uint64),
+		offset:   math.NaN(),
+		buckets:  buckets,
+		counts:   make([]uint64, len(buckets)),
+	}
+}
+
+// failureReason classifies the error from a gatherGpsData run.
+func failureReason(err error) string {
+	var pe *portError
+
+	switch {
+	case errors.As(err, &pe):
+		return reasonPortError
+	case errors.Is(err, errBadChecksum):
+		return reasonBadChecksum
+	case errors.Is(err, errInvalidState):
+		return reasonInvalidState
+	}
+	return reasonOther
+}
+
+// record accumulates the results of a gatherGpsData run that took d and ended with err and g.
+func (m *pollMetrics) record(d time.Duration, err error, g *gpsData) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err != nil {
+		m.failures[failureReason(err)]++
+	} else {
+		m.successes++
+		m.lastFix = time.Now()
+		m.offset = g.getClockOffset().Seconds()
+	}
+
+	s := d.Seconds()
+	for i, b := range m.buckets {
+		if s <= b {
+			m.counts[i]++
+		}
+	}
+	m.count++
+	m.sum += s
+}
+
+// recordPoll accumulates the results of a gatherGpsData run in the global metrics.
+func recordPoll(d time.Duration, err error, g *gpsData) {
+	metrics.record(d, err, g)
+}
+
+// formatFloat returns a string representation of f in Prometheus text format.
+func formatFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// writeTo writes the metrics with current values from v to w in Prometheus text format.
+func (m *pollMetrics) writeTo(w io.Writer, v gpsValues, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var err error
+	metric := func(name, typ, help string, samples ...string) {
+		if err != nil {
+			return
+		}
+
+		_, err = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+		for _, s := range samples {
+			if err == nil {
+				_, err = fmt.Fprintf(w, "%s\n", s)
+			}
+		}
+	}
+
+	hdop := math.NaN()
+	if v.HDOP > -1 {
+		hdop = v.HDOP
+	}
+	metric("gps_hdop", "gauge", "Horizontal dilution of precision from the last poll.",
+		"gps_hdop "+formatFloat(hdop),
+	)
+
+	satellites := math.NaN()
+	if v.NumSatellites > -1 {
+		satellites = float64(v.NumSatellites)
+	}
+	metric("gps_satellites", "gauge", "Number of satellites being tracked at the last poll.",
+		"gps_satellites "+formatFloat(satellites),
+	)
+
+	quality := math.NaN()
+	if v.FixQuality != "" {
+		quality = float64(fixQualityIndicator(v.FixQuality))
+	}
+	metric("gps_fix_quality", "gauge", "GGA fix quality indicator from the last poll, 0 is invalid.",
+		"gps_fix_quality "+formatFloat(quality),
+	)
+
+	last := m.lastFix
+	if last.IsZero() {
+		last = m.started
+	}
+	metric("gps_last_fix_age_seconds", "gauge", "Seconds since the last successful poll, or since startup if there hasn't been one.",
+		"gps_last_fix_age_seconds "+formatFloat(now.Sub(last).Seconds()),
+	)
+
+	metric("gps_clock_offset_seconds", "gauge", "GPS time minus system time, measured before the system time was last set.",
+		"gps_clock_offset_seconds "+formatFloat(m.offset),
+	)
+
+	metric("gps_polls_succeeded_total", "counter", "Number of times polling the gps device succeeded.",
+		fmt.Sprintf("gps_polls_succeeded_total %d", m.successes),
+	)
+
+	failures := make([]string, 0, 4)
+	for _, r := range []string{reasonPortError, reasonBadChecksum, reasonInvalidState, reasonOther} {
+		failures = append(failures, fmt.Sprintf("gps_polls_failed_total{reason=%q} %d", r, m.failures[r]))
+	}
+	metric("gps_polls_failed_total", "counter", "Number of times polling the gps device failed, by reason.", failures...)
+
+	durations := make([]string, 0, len(m.buckets)+3)
+	for i, b := range m.buckets {
+		durations = append(durations, fmt.Sprintf("gps_poll_duration_seconds_bucket{le=%q} %d", formatFloat(b), m.counts[i]))
+	}
+	durations = append(durations,
+		fmt.Sprintf("gps_poll_duration_seconds_bucket{le=\"+Inf\"} %d", m.count),
+		"gps_poll_duration_seconds_sum "+formatFloat(m.sum),
+		fmt.Sprintf("gps_poll_duration_seconds_count %d", m.count),
+	)
+	metric("gps_poll_duration_seconds", "histogram", "How long polling the gps device took.", durations...)
+
+	return err
+}
+
+// handleMetrics returns the metrics in Prometheus text format.
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = metrics.writeTo(w, gpsdata.values(), time.Now())
+}
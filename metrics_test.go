@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_failureReason(t *testing.T) {
+	type args struct {
+		err error
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "Port",
+			args: args{err: &portError{err: errors.New("The system cannot find the file specified.")}},
+			want: reasonPortError,
+		},
+		{
+			name: "RMC checksum",
+			args: args{err: fmt.Errorf("RMC line %w", errBadChecksum)},
+			want: reasonBadChecksum,
+		},
+		{
+			name: "State",
+			args: args{err: errInvalidState},
+			want: reasonInvalidState,
+		},
+		{
+			name: "Other",
+			args: args{err: errors.New("invalid GGA line")},
+			want: reasonOther,
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			if got := failureReason(ttt.args.err); got != ttt.want {
+				t.Errorf("failureReason() = %v, want %v", got, ttt.want)
+			}
+		})
+	}
+}
+
+func Test_pollMetrics_writeTo(t *testing.T) {
+	m := newPollMetrics()
+
+	g := newGPSData()
+	g.setFixQuality("DGPS fix")
+	g.setNumSatellites(7)
+	g.setHDOP(1.33)
+	g.setClockOffset(-1500 * time.Millisecond)
+
+	m.record(300*time.Millisecond, nil, g)
+	m.record(2*time.Second, fmt.Errorf("GGA line %w", errBadChecksum), newGPSData())
+	m.record(40*time.Second, &portError{err: errors.New("timeout")}, newGPSData())
+
+	var b bytes.Buffer
+	err := m.writeTo(&b, g.values(), m.lastFix.Add(90*time.Second))
+	if err != nil {
+		t.Fatalf("writeTo() error = %v", err)
+	}
+
+	want := []string{
+		"# TYPE gps_hdop gauge\ngps_hdop 1.33\n",
+		"gps_satellites 7\n",
+		"gps_fix_quality 2\n",
+		"gps_last_fix_age_seconds 90\n",
+		"gps_clock_offset_seconds -1.5\n",
+		"gps_polls_succeeded_total 1\n",
+		"gps_polls_failed_total{reason=\"port_error\"} 1\n",
+		"gps_polls_failed_total{reason=\"bad_checksum\"} 1\n",
+		"gps_polls_failed_total{reason=\"invalid_state\"} 0\n",
+		"gps_poll_duration_seconds_bucket{le=\"0.25\"} 0\n",
+		"gps_poll_duration_seconds_bucket{le=\"0.5\"} 1\n",
+		"gps_poll_duration_seconds_bucket{le=\"2.5\"} 2\n",
+		"gps_poll_duration_seconds_bucket{le=\"+Inf\"} 3\n",
+		"gps_poll_duration_seconds_sum 42.3\n",
+		"gps_poll_duration_seconds_count 3\n",
+	}
+	for _, w := range want {
+		if !strings.Contains(b.String(), w) {
+			t.Errorf("writeTo() missing %q in\n%s", w, b.String())
+		}
+	}
+
+	// unknown values
+	b.Reset()
+	err = newPollMetrics().writeTo(&b, newGPSData().values(), time.Now())
+	if err != nil {
+		t.Fatalf("writeTo() error = %v", err)
+	}
+	for _, w := range []string{"gps_hdop NaN\n", "gps_satellites NaN\n", "gps_fix_quality NaN\n", "gps_clock_offset_seconds NaN\n"} {
+		if !strings.Contains(b.String(), w) {
+			t.Errorf("writeTo() missing %q in\n%s", w, b.String())
+		}
+	}
+}
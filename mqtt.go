@@ -0,0 +1,461 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// mqttConfig is the configuration of the MQTT publisher.
+type mqttConfig struct {
+	Broker             string
+	ClientID           string
+	Username           string
+	Password           string
+	TopicPrefix        string
+	KeepAlive          time.Duration
+	CAFile             string
+	InsecureSkipVerify bool
+}
+
+// MQTT control packet types, already shifted into the high nibble of the fixed header.
+const (
+	mqttConnect    byte = 0x10
+	mqttConnack    byte = 0x20
+	mqttPublish    byte = 0x30
+	mqttPingreq    byte = 0xC0
+	mqttPingresp   byte = 0xD0
+	mqttDisconnect byte = 0xE0
+)
+
+const (
+	// published to the availability topic
+	mqttOnline  = "online"
+	mqttOffline = "offline"
+
+	// how long to wait before reconnecting to the broker
+	mqttReconnectDelay = 30 * time.Second
+)
+
+// encodeMQTTString returns s prefixed with its length.
+func encodeMQTTString(s string) []byte {
+	b := make([]byte, 2, 2+len(s))
+	binary.BigEndian.PutUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// encodeMQTTPacket returns a control packet with the fixed header for typ and body.
+func encodeMQTTPacket(typ byte, body []byte) []byte {
+	b := []byte{typ}
+
+	// remaining length is 7 bits per byte, high bit set means more bytes follow
+	n := len(body)
+	for {
+		d := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			d |= 0x80
+		}
+		b = append(b, d)
+		if n == 0 {
+			break
+		}
+	}
+
+	return append(b, body...)
+}
+
+// encodeMQTTConnect returns a CONNECT packet with a retained Last Will of offline on willTopic.
+func encodeMQTTConnect(clientID, username, password, willTopic string, keepAlive time.Duration) []byte {
+	// clean session, will flag, will retain
+	flags := byte(0x02 | 0x04 | 0x20)
+	if username != "" {
+		flags |= 0x80
+
+		// MQTT 3.1.1 only allows a password along with a username
+		if password != "" {
+			flags |= 0x40
+		}
+	}
+
+	body := append(encodeMQTTString("MQTT"), 4, flags, 0, 0)
+	binary.BigEndian.PutUint16(body[len(body)-2:], uint16(keepAlive/time.Second))
+
+	body = append(body, encodeMQTTString(clientID)...)
+	body = append(body, encodeMQTTString(willTopic)...)
+	body = append(body, encodeMQTTString(mqttOffline)...)
+	if flags&0x80 != 0 {
+		body = append(body, encodeMQTTString(username)...)
+	}
+	if flags&0x40 != 0 {
+		body = append(body, encodeMQTTString(password)...)
+	}
+
+	return encodeMQTTPacket(mqttConnect, body)
+}
+
+// encodeMQTTPublish returns a QoS 0 PUBLISH packet.
+func encodeMQTTPublish(topic string, payload []byte, retain bool) []byte {
+	typ := mqttPublish
+	if retain {
+		typ |= 0x01
+	}
+
+	return encodeMQTTPacket(typ, append(encodeMQTTString(topic), payload...))
+}
+
+// readMQTTPacket reads a control packet and returns the first byte of its fixed header and its body.
+func readMQTTPacket(r *bufio.Reader) (byte, []byte, error) {
+	typ, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	n := 0
+	for m := 1; ; m *= 128 {
+		if m > 128*128*128 {
+			return 0, nil, fmt.Errorf("malformed MQTT remaining length")
+		}
+
+		var d byte
+		d, err = r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n += int(d&0x7F) * m
+		if d&0x80 == 0 {
+			break
+		}
+	}
+
+	body := make([]byte, n)
+	_, err = io.ReadFull(r, body)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return typ, body, nil
+}
+
+// mqttMessages returns the retained topics and their payloads for v.
+func mqttMessages(prefix string, v gpsValues) (map[string]string, error) {
+	a := newAPIStatus(v)
+
+	m := map[string]string{
+		prefix + "/status":     a.Status,
+		prefix + "/gridsquare": "",
+		prefix + "/latitude":   "",
+		prefix + "/longitude":  "",
+		prefix + "/fix":        "",
+	}
+	if a.Gridsquare != nil {
+		m[prefix+"/gridsquare"] = *a.Gridsquare
+	}
+	if a.Latitude != nil {
+		m[prefix+"/latitude"] = strconv.FormatFloat(*a.Latitude, 'f', -1, 64)
+	}
+	if a.Longitude != nil {
+		m[prefix+"/longitude"] = strconv.FormatFloat(*a.Longitude, 'f', -1, 64)
+	}
+	if a.FixQuality != nil {
+		m[prefix+"/fix"] = *a.FixQuality
+	}
+
+	b, err := json.Marshal(a)
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+	m[prefix+"/state"] = string(b)
+
+	return m, nil
+}
+
+// mqttPublisher keeps the broker up to date with the gps data.
+type mqttPublisher struct {
+	cfg       mqttConfig
+	tlsConfig *tls.Config
+	address   string
+	quit      chan struct{}
+	done      chan struct{}
+
+	// last payloads published, to only publish changes
+	published map[string]string
+
+	conn net.Conn
+	mu   sync.Mutex
+}
+
+// newMQTTPublisher validates cfg and returns a publisher ready to start.
+func newMQTTPublisher(cfg mqttConfig) (*mqttPublisher, error) {
+	u, err := url.Parse(cfg.Broker)
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	if cfg.Password != "" && cfg.Username == "" {
+		err = fmt.Errorf("MQTT password needs a username")
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	if cfg.ClientID == "" {
+		cfg.ClientID = "gps-qth-qtr"
+	}
+	if cfg.TopicPrefix == "" {
+		cfg.TopicPrefix = "gps-qth-qtr"
+	}
+	if cfg.KeepAlive <= 0 {
+		cfg.KeepAlive = 60
+	}
+	cfg.KeepAlive *= time.Second
+
+	p := &mqttPublisher{
+		cfg:  cfg,
+		quit: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	port := u.Port()
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		if port == "" {
+			port = "1883"
+		}
+	case "ssl", "tls", "mqtts":
+		if port == "" {
+			port = "8883"
+		}
+
+		// #nosec G402
+		p.tlsConfig = &tls.Config{
+			ServerName:         u.Hostname(),
+			InsecureSkipVerify: cfg.InsecureSkipVerify,
+		}
+
+		if cfg.CAFile != "" {
+			// #nosec G304
+			pem, err := ioutil.ReadFile(cfg.CAFile)
+			if err != nil {
+				log.Printf("%+v", err)
+				return nil, err
+			}
+
+			p.tlsConfig.RootCAs = x509.NewCertPool()
+			if !p.tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+				err = fmt.Errorf("no certificates found in %s", cfg.CAFile)
+				log.Printf("%+v", err)
+				return nil, err
+			}
+		}
+	default:
+		err = fmt.Errorf("unsupported MQTT broker scheme %q", u.Scheme)
+		log.Printf("%+v", err)
+		return nil, err
+	}
+	p.address = net.JoinHostPort(u.Hostname(), port)
+
+	return p, nil
+}
+
+// write sends packet to the broker.
+func (p *mqttPublisher) write(packet []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conn == nil {
+		return fmt.Errorf("not connected to MQTT broker")
+	}
+
+	err := p.conn.SetWriteDeadline(time.Now().Add(p.cfg.KeepAlive))
+	if err != nil {
+		return err
+	}
+
+	_, err = p.conn.Write(packet)
+	return err
+}
+
+// connect establishes a session with the broker and returns a reader for its packets.
+func (p *mqttPublisher) connect() (*bufio.Reader, error) {
+	d := &net.Dialer{Timeout: p.cfg.KeepAlive}
+
+	var conn net.Conn
+	var err error
+	if p.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(d, "tcp", p.address, p.tlsConfig)
+	} else {
+		conn, err = d.Dial("tcp", p.address)
+	}
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.conn = conn
+	p.published = make(map[string]string)
+	p.mu.Unlock()
+
+	err = p.write(encodeMQTTConnect(p.cfg.ClientID, p.cfg.Username, p.cfg.Password, p.cfg.TopicPrefix+"/availability", p.cfg.KeepAlive))
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	err = conn.SetReadDeadline(time.Now().Add(p.cfg.KeepAlive))
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	typ, body, err := readMQTTPacket(r)
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+	if typ != mqttConnack || len(body) != 2 {
+		err = fmt.Errorf("expected CONNACK from MQTT broker")
+		log.Printf("%+v", err)
+		return nil, err
+	}
+	if body[1] != 0 {
+		err = fmt.Errorf("MQTT broker refused connection, return code %d", body[1])
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	err = p.write(encodeMQTTPublish(p.cfg.TopicPrefix+"/availability", []byte(mqttOnline), true))
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// disconnect closes the connection, gracefully if the broker should not send the Last Will.
+func (p *mqttPublisher) disconnect(graceful bool) {
+	if graceful {
+		_ = p.write(encodeMQTTPublish(p.cfg.TopicPrefix+"/availability", []byte(mqttOffline), true))
+		_ = p.write(encodeMQTTPacket(mqttDisconnect, nil))
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+}
+
+// publishValues publishes the topics for v whose payload changed since they were last published.
+func (p *mqttPublisher) publishValues(v gpsValues) error {
+	msgs, err := mqttMessages(p.cfg.TopicPrefix, v)
+	if err != nil {
+		return err
+	}
+
+	for topic, payload := range msgs {
+		if last, ok := p.published[topic]; ok && last == payload {
+			continue
+		}
+
+		err = p.write(encodeMQTTPublish(topic, []byte(payload), true))
+		if err != nil {
+			log.Printf("%+v", err)
+			return err
+		}
+		p.published[topic] = payload
+	}
+
+	return nil
+}
+
+// session publishes updates until the connection fails or the publisher is stopped
+// returns true if the publisher was stopped.
+func (p *mqttPublisher) session(updates <-chan gpsUpdate) bool {
+	r, err := p.connect()
+	if err != nil {
+		p.disconnect(false)
+		return false
+	}
+
+	p.mu.Lock()
+	conn := p.conn
+	p.mu.Unlock()
+
+	// read packets from the broker, any failure ends the session
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			err := conn.SetReadDeadline(time.Now().Add(p.cfg.KeepAlive * 3 / 2))
+			if err == nil {
+				_, _, err = readMQTTPacket(r)
+			}
+			if err != nil {
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(p.cfg.KeepAlive / 2)
+	defer ping.Stop()
+
+	err = p.publishValues(gpsdata.values())
+	for err == nil {
+		select {
+		case u := <-updates:
+			err = p.publishValues(u.values)
+		case <-ping.C:
+			err = p.write(encodeMQTTPacket(mqttPingreq, nil))
+		case err = <-readErr:
+			log.Printf("%+v", err)
+		case <-p.quit:
+			p.disconnect(true)
+			return true
+		}
+	}
+
+	p.disconnect(false)
+	return false
+}
+
+// start runs the publisher in the background until stop is called.
+func (p *mqttPublisher) start() {
+	updates, unsubscribe := subscribe()
+
+	go func() {
+		defer close(p.done)
+		defer unsubscribe()
+
+		for !p.session(updates) {
+			select {
+			case <-time.After(mqttReconnectDelay):
+			case <-p.quit:
+				return
+			}
+		}
+	}()
+}
+
+// stop disconnects from the broker and waits for the publisher to finish.
+func (p *mqttPublisher) stop() {
+	close(p.quit)
+	<-p.done
+}
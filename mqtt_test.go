@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_encodeMQTTPacket(t *testing.T) {
+	type args struct {
+		typ  byte
+		body []byte
+	}
+	tests := []struct {
+		name string
+		args args
+		want []byte
+	}{
+		{
+			name: "Empty",
+			args: args{typ: mqttPingreq, body: nil},
+			want: []byte{0xC0, 0x00},
+		},
+		{
+			name: "One byte length",
+			args: args{typ: mqttPublish, body: bytes.Repeat([]byte{'a'}, 127)},
+			want: append([]byte{0x30, 0x7F}, bytes.Repeat([]byte{'a'}, 127)...),
+		},
+		{
+			name: "Two byte length",
+			args: args{typ: mqttPublish, body: bytes.Repeat([]byte{'a'}, 321)},
+			want: append([]byte{0x30, 0xC1, 0x02}, bytes.Repeat([]byte{'a'}, 321)...),
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			got := encodeMQTTPacket(ttt.args.typ, ttt.args.body)
+			if !bytes.Equal(got, ttt.want) {
+				t.Errorf("encodeMQTTPacket() = %x, want %x", got, ttt.want)
+			}
+
+			typ, body, err := readMQTTPacket(bufio.NewReader(bytes.NewReader(got)))
+			if err != nil {
+				t.Fatalf("readMQTTPacket() error = %v", err)
+			}
+			if typ != ttt.args.typ || len(body) != len(ttt.args.body) {
+				t.Errorf("readMQTTPacket() = %x %d, want %x %d", typ, len(body), ttt.args.typ, len(ttt.args.body))
+			}
+		})
+	}
+}
+
+func Test_encodeMQTTConnect(t *testing.T) {
+	tests := []struct {
+		name      string
+		username  string
+		password  string
+		wantFlags byte
+		wantBody  string
+	}{
+		{name: "No credentials", wantFlags: 0x26},
+		{name: "Username", username: "station", wantFlags: 0xA6, wantBody: "\x00\x07station"},
+		{name: "Username and password", username: "station", password: "secret", wantFlags: 0xE6, wantBody: "\x00\x07station\x00\x06secret"},
+		{name: "Password without username", password: "secret", wantFlags: 0x26},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			_, body, err := readMQTTPacket(bufio.NewReader(bytes.NewReader(encodeMQTTConnect("id", ttt.username, ttt.password, "will", time.Minute))))
+			if err != nil {
+				t.Fatalf("readMQTTPacket() error = %v", err)
+			}
+
+			if body[7] != ttt.wantFlags {
+				t.Errorf("encodeMQTTConnect() flags = %#x, want %#x", body[7], ttt.wantFlags)
+			}
+
+			// protocol, flags, keep alive, client id, will topic and message come first
+			rest := string(body[10+6+len("id")+len("will")+len(mqttOffline):])
+			if rest != ttt.wantBody {
+				t.Errorf("encodeMQTTConnect() credentials = %q, want %q", rest, ttt.wantBody)
+			}
+		})
+	}
+}
+
+func Test_newMQTTPublisher(t *testing.T) {
+	_, err := newMQTTPublisher(mqttConfig{
+		Broker:   "tcp://127.0.0.1:1883",
+		Password: "secret",
+	})
+	if err == nil || !strings.Contains(err.Error(), "needs a username") {
+		t.Errorf("newMQTTPublisher() error = %v, want a password without a username rejected", err)
+	}
+}
+
+// mqttTestConnect is what the test broker got in a CONNECT packet.
+type mqttTestConnect struct {
+	flags    byte
+	clientID string
+	will     string
+	username string
+	password string
+}
+
+// mqttTestBroker is a minimal in-process broker that records what clients send it.
+type mqttTestBroker struct {
+	l        net.Listener
+	connects chan mqttTestConnect
+	publish  chan [2]string
+}
+
+func newMQTTTestBroker(t *testing.T) *mqttTestBroker {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+
+	b := &mqttTestBroker{
+		l:        l,
+		connects: make(chan mqttTestConnect, 1),
+		publish:  make(chan [2]string, 100),
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn)
+		}
+	}()
+
+	return b
+}
+
+func (b *mqttTestBroker) serve(conn net.Conn) {
+	defer conn.Close()
+
+	str := func(p []byte) (string, []byte) {
+		n := int(binary.BigEndian.Uint16(p))
+		return string(p[2 : 2+n]), p[2+n:]
+	}
+
+	r := bufio.NewReader(conn)
+	for {
+		typ, body, err := readMQTTPacket(r)
+		if err != nil {
+			return
+		}
+
+		switch typ & 0xF0 {
+		case mqttConnect:
+			var c mqttTestConnect
+			c.flags = body[7]
+			p := body[10:]
+			c.clientID, p = str(p)
+			c.will, p = str(p)
+			_, p = str(p)
+			if c.flags&0x80 != 0 {
+				c.username, p = str(p)
+			}
+			if c.flags&0x40 != 0 {
+				c.password, _ = str(p)
+			}
+			b.connects <- c
+
+			_, _ = conn.Write(encodeMQTTPacket(mqttConnack, []byte{0, 0}))
+		case mqttPublish:
+			if typ&0x01 == 0 {
+				// only retained messages are expected
+				return
+			}
+			topic, payload := str(body)
+			b.publish <- [2]string{topic, string(payload)}
+		case mqttPingreq:
+			_, _ = conn.Write(encodeMQTTPacket(mqttPingresp, nil))
+		case mqttDisconnect:
+			return
+		}
+	}
+}
+
+func Test_mqttPublisher(t *testing.T) {
+	b := newMQTTTestBroker(t)
+	defer b.l.Close()
+
+	p, err := newMQTTPublisher(mqttConfig{
+		Broker:      "tcp://" + b.l.Addr().String(),
+		Username:    "station",
+		Password:    "secret",
+		TopicPrefix: "shack/gps",
+	})
+	if err != nil {
+		t.Fatalf("newMQTTPublisher() error = %v", err)
+	}
+	p.start()
+
+	select {
+	case c := <-b.connects:
+		want := mqttTestConnect{flags: 0xE6, clientID: "gps-qth-qtr", will: "shack/gps/availability", username: "station", password: "secret"}
+		if !reflect.DeepEqual(c, want) {
+			t.Errorf("CONNECT = %+v, want %+v", c, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no CONNECT")
+	}
+
+	// wait for everything to be published
+	got := make(map[string]string)
+	next := func() {
+		select {
+		case m := <-b.publish:
+			got[m[0]] = m[1]
+		case <-time.After(5 * time.Second):
+			t.Fatal("no PUBLISH")
+		}
+	}
+	for len(got) < 7 {
+		next()
+	}
+	if got["shack/gps/availability"] != mqttOnline {
+		t.Errorf("availability = %q, want %q", got["shack/gps/availability"], mqttOnline)
+	}
+
+	// only changed topics get published again
+	v := newGPSData().values()
+	v.Status = got["shack/gps/status"]
+	v.Gridsquare = "FM18lw"
+	publish([]gpsEvent{eventGridChanged}, v)
+
+	delete(got, "shack/gps/state")
+	next()
+	next()
+	if got["shack/gps/gridsquare"] != "FM18lw" {
+		t.Errorf("gridsquare = %q, want %q", got["shack/gps/gridsquare"], "FM18lw")
+	}
+	if _, ok := got["shack/gps/state"]; !ok {
+		t.Errorf("state not published")
+	}
+	select {
+	case m := <-b.publish:
+		t.Errorf("unexpected PUBLISH %v", m)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	p.stop()
+
+	next()
+	if got["shack/gps/availability"] != mqttOffline {
+		t.Errorf("availability = %q, want %q", got["shack/gps/availability"], mqttOffline)
+	}
+}
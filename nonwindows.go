@@ -3,29 +3,53 @@
 package main
 
 import (
+	"errors"
 	"log"
+	"os"
 	"time"
+
+	"golang.org/x/sys/unix"
 )
 
+// errSetTimePermission is returned when we aren't allowed to set the system time.
+var errSetTimePermission = errors.New("setting the system time needs root or the CAP_SYS_TIME capability")
+
+// setSystemTime calls settimeofday
 func setSystemTime(t time.Time) error {
-	// NOP
+	tv := unix.NsecToTimeval(t.UnixNano())
+	err := unix.Settimeofday(&tv)
+	if err == unix.EPERM {
+		err = errSetTimePermission
+	}
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
 	return nil
 }
 
-func systemTray() error {
-	// satisfy 'unused' linter
-	log.Printf(
-		"%s %s %s %s %s %s %s %s",
-		gpsdata.formatStatus(),
-		gpsdata.formatGridsquare(),
-		gpsdata.formatLatitude(),
-		gpsdata.formatLongitude(),
-		gpsdata.formatTime(),
-		gpsdata.formatFixQuality(),
-		gpsdata.formatNumSatellites(),
-		gpsdata.formatHDOP(),
-	)
-
+func attachConsole() {
 	// NOP
-	return nil
+}
+
+// portConnected returns true if the serial port name is there.
+func portConnected(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+// systemTray puts us in the desktop's system tray and runs until exit, problem is shown in a notification if we couldn't start the way we were configured to
+// without a desktop session there is no tray, so we run headless.
+func systemTray(problem string) error {
+	t, err := startTray()
+	if err != nil {
+		return runDaemon(nil, problem)
+	}
+	defer t.stop()
+
+	if problem != "" {
+		t.notify("gps-qth-qtr", problem)
+	}
+
+	return runDaemon(t.exit, problem)
 }
@@ -4,25 +4,49 @@ import (
 	"encoding/csv"
 	"fmt"
 	"log"
+	"math"
 	"strconv"
 	"strings"
 )
 
-// parseGGA extracts the fix quality, number of satellites, and horizontal dilution of precision being tracked from a **GGA line.
-func parseGGA(s string) (string, int, float64, error) {
+// fixQualities are the descriptions of the GGA fix quality indicators.
+var fixQualities = map[int]string{
+	1: "GPS fix (SPS)",
+	2: "DGPS fix",
+	3: "PPS fix",
+	4: "Real Time Kinematic",
+	5: "Float RTK",
+	6: "estimated (dead reckoning)",
+	7: "Manual input mode",
+	8: "Simulation mode",
+}
+
+// fixQualityIndicator returns the GGA fix quality indicator for the description qs, 0 (invalid) if it is unknown.
+func fixQualityIndicator(qs string) int {
+	for q, s := range fixQualities {
+		if s == qs {
+			return q
+		}
+	}
+	return 0
+}
+
+// parseGGA extracts the fix quality, number of satellites, horizontal dilution of precision, and altitude being tracked from a **GGA line
+// altitude is meters above mean sea level, NaN when the receiver doesn't report it.
+func parseGGA(s string) (string, int, float64, float64, error) {
 	// parse comma delimted records to fields
 	r := csv.NewReader(strings.NewReader(s))
 	fields, err := r.Read()
 	if err != nil {
 		log.Printf("%+v", err)
-		return "", 0, 0.0, err
+		return "", 0, 0.0, 0.0, err
 	}
 
-	// need at least 9 fields to get fix quality, number of satellites, and horizontal dilution of precision
-	if len(fields) < 9 {
+	// need at least 10 fields to get fix quality, number of satellites, horizontal dilution of precision, and altitude
+	if len(fields) < 10 {
 		err := fmt.Errorf("invalid GGA line")
 		log.Printf("%+v", err)
-		return "", 0, 0.0, err
+		return "", 0, 0.0, 0.0, err
 	}
 
 	// validate checksum
@@ -31,57 +55,52 @@ func parseGGA(s string) (string, int, float64, error) {
 	if len(strchk) < 2 {
 		err := fmt.Errorf("missing checksum")
 		log.Printf("%+v", err)
-		return "", 0, 0.0, err
+		return "", 0, 0.0, 0.0, err
 	}
 
 	for _, c := range strchk[0] {
 		checksum ^= int(c)
 	}
 	if fmt.Sprintf("%X", checksum) != strings.TrimSpace(strchk[1]) {
-		err := fmt.Errorf("GGA line bad checksum")
+		err := fmt.Errorf("GGA line %w", errBadChecksum)
 		log.Printf("%+v", err)
-		return "", 0, 0.0, err
+		return "", 0, 0.0, 0.0, err
 	}
 
 	// get fix quality
 	q, err := strconv.Atoi(fields[6])
 	if err != nil {
 		log.Printf("%+v", err)
-		return "", 0, 0.0, err
+		return "", 0, 0.0, 0.0, err
 	}
-	qs := "invalid"
-	switch q {
-	case 1:
-		qs = "GPS fix (SPS)"
-	case 2:
-		qs = "DGPS fix"
-	case 3:
-		qs = "PPS fix"
-	case 4:
-		qs = "Real Time Kinematic"
-	case 5:
-		qs = "Float RTK"
-	case 6:
-		qs = "estimated (dead reckoning)"
-	case 7:
-		qs = "Manual input mode"
-	case 8:
-		qs = "Simulation mode"
+	qs, ok := fixQualities[q]
+	if !ok {
+		qs = "invalid"
 	}
 
 	// get number of satellites being tracked
 	n, err := strconv.Atoi(fields[7])
 	if err != nil {
 		log.Printf("%+v", err)
-		return "", 0, 0.0, err
+		return "", 0, 0.0, 0.0, err
 	}
 
 	// get HDOP
 	h, err := strconv.ParseFloat(fields[8], 64)
 	if err != nil {
 		log.Printf("%+v", err)
-		return "", 0, 0.0, err
+		return "", 0, 0.0, 0.0, err
+	}
+
+	// get altitude
+	alt := math.NaN()
+	if fields[9] != "" {
+		alt, err = strconv.ParseFloat(fields[9], 64)
+		if err != nil {
+			log.Printf("%+v", err)
+			return "", 0, 0.0, 0.0, err
+		}
 	}
 
-	return qs, n, h, nil
+	return qs, n, h, alt, nil
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"math"
 	"testing"
 )
 
@@ -14,6 +15,7 @@ func Test_parseGGA(t *testing.T) {
 		want    string
 		want1   int
 		want2   float64
+		want3   float64
 		wantErr bool
 	}{
 		{
@@ -22,6 +24,7 @@ func Test_parseGGA(t *testing.T) {
 			want:    "GPS fix (SPS)",
 			want1:   12,
 			want2:   0.96,
+			want3:   250.6,
 			wantErr: false,
 		},
 		{
@@ -30,6 +33,7 @@ func Test_parseGGA(t *testing.T) {
 			want:    "GPS fix (SPS)",
 			want1:   3,
 			want2:   99.3,
+			want3:   250.6,
 			wantErr: false,
 		},
 		{
@@ -38,6 +42,7 @@ func Test_parseGGA(t *testing.T) {
 			want:    "DGPS fix",
 			want1:   7,
 			want2:   1.33,
+			want3:   250.6,
 			wantErr: false,
 		},
 		{
@@ -46,6 +51,16 @@ func Test_parseGGA(t *testing.T) {
 			want:    "invalid",
 			want1:   99,
 			want2:   5.2,
+			want3:   250.6,
+			wantErr: false,
+		},
+		{
+			name:    "No altitude",
+			args:    args{s: "GNGGA,013016.00,7751.3,S,16642.4,E,0,0,99.99,,M,,M,,*6F"},
+			want:    "invalid",
+			want1:   0,
+			want2:   99.99,
+			want3:   math.NaN(),
 			wantErr: false,
 		},
 		{
@@ -54,6 +69,7 @@ func Test_parseGGA(t *testing.T) {
 			want:    "",
 			want1:   0,
 			want2:   0.0,
+			want3:   0.0,
 			wantErr: true,
 		},
 		{
@@ -62,6 +78,7 @@ func Test_parseGGA(t *testing.T) {
 			want:    "",
 			want1:   0,
 			want2:   0.0,
+			want3:   0.0,
 			wantErr: true,
 		},
 	}
@@ -69,7 +86,7 @@ func Test_parseGGA(t *testing.T) {
 		ttt := tt
 
 		t.Run(ttt.name, func(t *testing.T) {
-			got, got1, got2, err := parseGGA(ttt.args.s)
+			got, got1, got2, got3, err := parseGGA(ttt.args.s)
 			if (err != nil) != ttt.wantErr {
 				t.Errorf("parseGGA() error = %v, wantErr %v", err, ttt.wantErr)
 				return
@@ -83,6 +100,9 @@ func Test_parseGGA(t *testing.T) {
 			if got2 != ttt.want2 {
 				t.Errorf("parseGGA() got2 = %v, want %v", got2, ttt.want2)
 			}
+			if got3 != ttt.want3 && !(math.IsNaN(got3) && math.IsNaN(ttt.want3)) {
+				t.Errorf("parseGGA() got3 = %v, want %v", got3, ttt.want3)
+			}
 		})
 	}
 }
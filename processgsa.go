@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// gsaMessage is one **GSA sentence, the satellites used in the fix and the dilutions of precision
+// receivers tracking more than one system send one per system.
+type gsaMessage struct {
+	// talker of the satellites, like GSV, empty for GN when the receiver doesn't say which system
+	System string
+	// 1 no fix, 2 2D, 3 3D
+	Mode int
+	PRNs []int
+	// -1 if the receiver doesn't report it
+	PDOP float64
+	HDOP float64
+	VDOP float64
+}
+
+// gsaSystems are the talkers of the NMEA 4.10 system ids.
+var gsaSystems = map[string]string{
+	"1": "GP",
+	"2": "GL",
+	"3": "GA",
+	"4": "GB",
+	"5": "GQ",
+}
+
+// parseDOP returns the dilution of precision in field, -1 if it is empty.
+func parseDOP(field string) (float64, error) {
+	if field == "" {
+		return -1, nil
+	}
+
+	v, err := strconv.ParseFloat(field, 64)
+	if err != nil {
+		log.Printf("%+v", err)
+		return 0, err
+	}
+	return v, nil
+}
+
+// parseGSA extracts the fix mode, satellites used and dilutions of precision from a **GSA line.
+func parseGSA(s string) (gsaMessage, error) {
+	var m gsaMessage
+
+	// validate checksum
+	strchk := strings.Split(strings.TrimSpace(s), "*")
+	if len(strchk) < 2 {
+		err := fmt.Errorf("missing checksum")
+		log.Printf("%+v", err)
+		return m, err
+	}
+
+	checksum := 0
+	for _, c := range strchk[0] {
+		checksum ^= int(c)
+	}
+	want, err := strconv.ParseUint(strchk[1], 16, 8)
+	if err != nil || int(want) != checksum {
+		err := fmt.Errorf("GSA line %w", errBadChecksum)
+		log.Printf("%+v", err)
+		return m, err
+	}
+
+	// parse comma delimted records to fields
+	r := csv.NewReader(strings.NewReader(strchk[0]))
+	fields, err := r.Read()
+	if err != nil {
+		log.Printf("%+v", err)
+		return m, err
+	}
+
+	// need the mode, 12 satellite slots and the 3 dilutions of precision, NMEA 4.10 adds a system id at the end
+	if len(fields) < 18 || len(fields[0]) != 5 {
+		err := fmt.Errorf("invalid GSA line")
+		log.Printf("%+v", err)
+		return m, err
+	}
+
+	m.System = fields[0][:2]
+	if len(fields) > 18 {
+		m.System = gsaSystems[fields[18]]
+	}
+	if m.System == "GN" {
+		m.System = ""
+	}
+
+	m.Mode, err = strconv.Atoi(fields[2])
+	if err != nil {
+		log.Printf("%+v", err)
+		return m, err
+	}
+
+	for _, f := range fields[3:15] {
+		// empty slots pad out the list
+		if f == "" {
+			continue
+		}
+
+		prn, err := strconv.Atoi(f)
+		if err != nil {
+			log.Printf("%+v", err)
+			return m, err
+		}
+		m.PRNs = append(m.PRNs, prn)
+	}
+
+	for i, v := range []*float64{&m.PDOP, &m.HDOP, &m.VDOP} {
+		*v, err = parseDOP(fields[15+i])
+		if err != nil {
+			return m, err
+		}
+	}
+
+	return m, nil
+}
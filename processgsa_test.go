@@ -0,0 +1,70 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseGSA(t *testing.T) {
+	type args struct {
+		s string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    gsaMessage
+		wantErr bool
+	}{
+		{
+			name:    "Valid 1",
+			args:    args{s: "GPGSA,A,3,04,05,,09,12,,,24,,,,,2.5,1.3,2.1*39"},
+			want:    gsaMessage{System: "GP", Mode: 3, PRNs: []int{4, 5, 9, 12, 24}, PDOP: 2.5, HDOP: 1.3, VDOP: 2.1},
+			wantErr: false,
+		},
+		{
+			name:    "Valid system id",
+			args:    args{s: "GNGSA,A,3,65,67,,,,,,,,,,,1.9,1.0,1.6,2*3E"},
+			want:    gsaMessage{System: "GL", Mode: 3, PRNs: []int{65, 67}, PDOP: 1.9, HDOP: 1.0, VDOP: 1.6},
+			wantErr: false,
+		},
+		{
+			name:    "Valid no fix",
+			args:    args{s: "GPGSA,A,1,,,,,,,,,,,,,,,*1E"},
+			want:    gsaMessage{System: "GP", Mode: 1, PDOP: -1, HDOP: -1, VDOP: -1},
+			wantErr: false,
+		},
+		{
+			name:    "Invalid checksum",
+			args:    args{s: "GPGSA,A,3,04,05,,09,12,,,24,,,,,2.5,1.3,2.1*38"},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid missing checksum",
+			args:    args{s: "GPGSA,A,3,04,05,,09,12,,,24,,,,,2.5,1.3,2.1"},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid too short",
+			args:    args{s: "GPGSA,A,3,04,05,,09,12,,,24,,,,,2.5,1.3*38"},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid PRN",
+			args:    args{s: "GPGSA,A,3,x4,05,,09,12,,,24,,,,,2.5,1.3,2.1*71"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+		t.Run(ttt.name, func(t *testing.T) {
+			got, err := parseGSA(ttt.args.s)
+			if (err != nil) != ttt.wantErr {
+				t.Errorf("parseGSA() error = %v, wantErr %v", err, ttt.wantErr)
+				return
+			}
+			if !ttt.wantErr && !reflect.DeepEqual(got, ttt.want) {
+				t.Errorf("parseGSA() = %+v, want %+v", got, ttt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// satellite is a satellite in view of the receiver.
+type satellite struct {
+	// talker of the GSV sentence it was in, GP for GPS, GL for GLONASS, GA for Galileo, GB or BD for BeiDou
+	System string
+	PRN    int
+	// degrees, -1 if the receiver doesn't know
+	Elevation int
+	Azimuth   int
+	// signal to noise ratio in dB-Hz, -1 if it isn't being tracked
+	SNR int
+	// used in the fix, from the **GSA sentences
+	Used bool
+}
+
+// gsvMessage is one **GSV sentence, a sequence of them lists all the satellites in view.
+type gsvMessage struct {
+	System string
+	// NMEA 4.10 signal id, a system has a sequence for each signal it tracks
+	Signal     string
+	Total      int
+	Number     int
+	InView     int
+	Satellites []satellite
+}
+
+// parseGSVField returns the integer in field, -1 if it is empty.
+func parseGSVField(field string) (int, error) {
+	if field == "" {
+		return -1, nil
+	}
+
+	v, err := strconv.Atoi(field)
+	if err != nil {
+		log.Printf("%+v", err)
+		return 0, err
+	}
+	return v, nil
+}
+
+// parseGSV extracts the satellites in view from a **GSV line.
+func parseGSV(s string) (gsvMessage, error) {
+	var m gsvMessage
+
+	// validate checksum
+	strchk := strings.Split(strings.TrimSpace(s), "*")
+	if len(strchk) < 2 {
+		err := fmt.Errorf("missing checksum")
+		log.Printf("%+v", err)
+		return m, err
+	}
+
+	checksum := 0
+	for _, c := range strchk[0] {
+		checksum ^= int(c)
+	}
+	want, err := strconv.ParseUint(strchk[1], 16, 8)
+	if err != nil || int(want) != checksum {
+		err := fmt.Errorf("GSV line %w", errBadChecksum)
+		log.Printf("%+v", err)
+		return m, err
+	}
+
+	// parse comma delimted records to fields
+	r := csv.NewReader(strings.NewReader(strchk[0]))
+	fields, err := r.Read()
+	if err != nil {
+		log.Printf("%+v", err)
+		return m, err
+	}
+
+	// need the header and up to 4 satellites of 4 fields each, NMEA 4.10 adds a signal id at the end
+	if len(fields) < 4 || len(fields[0]) != 5 {
+		err := fmt.Errorf("invalid GSV line")
+		log.Printf("%+v", err)
+		return m, err
+	}
+	m.System = fields[0][:2]
+
+	for i, v := range []*int{&m.Total, &m.Number, &m.InView} {
+		*v, err = strconv.Atoi(fields[i+1])
+		if err != nil {
+			log.Printf("%+v", err)
+			return m, err
+		}
+	}
+	if m.Number < 1 || m.Number > m.Total {
+		err := fmt.Errorf("invalid GSV line")
+		log.Printf("%+v", err)
+		return m, err
+	}
+
+	if (len(fields)-4)%4 == 1 {
+		m.Signal = fields[len(fields)-1]
+	}
+
+	for i := 4; i+4 <= len(fields); i += 4 {
+		// empty slots pad out the last sentence
+		if fields[i] == "" {
+			continue
+		}
+
+		sat := satellite{System: m.System}
+		for j, v := range []*int{&sat.PRN, &sat.Elevation, &sat.Azimuth, &sat.SNR} {
+			*v, err = parseGSVField(fields[i+j])
+			if err != nil {
+				return m, err
+			}
+		}
+		m.Satellites = append(m.Satellites, sat)
+	}
+
+	return m, nil
+}
+
+// skyView puts together the satellites in view from sequences of **GSV sentences, one sequence per system and signal.
+type skyView struct {
+	pending  map[string][]satellite
+	next     map[string]int
+	complete map[string][]satellite
+	used     map[satellite]bool
+}
+
+// newSkyView returns an empty skyView.
+func newSkyView() *skyView {
+	return &skyView{
+		pending:  make(map[string][]satellite),
+		next:     make(map[string]int),
+		complete: make(map[string][]satellite),
+		used:     make(map[satellite]bool),
+	}
+}
+
+// add adds m to the sequence of its system, a sequence that is missing a sentence is dropped.
+func (sv *skyView) add(m gsvMessage) {
+	k := m.System + m.Signal
+
+	if m.Number == 1 {
+		sv.pending[k] = nil
+		sv.next[k] = 1
+	}
+
+	// started part way through a sequence or missed a sentence
+	if sv.next[k] != m.Number {
+		delete(sv.pending, k)
+		delete(sv.next, k)
+		return
+	}
+
+	sv.pending[k] = append(sv.pending[k], m.Satellites...)
+	sv.next[k]++
+
+	if m.Number == m.Total {
+		sv.complete[k] = sv.pending[k]
+		delete(sv.pending, k)
+		delete(sv.next, k)
+	}
+}
+
+// use marks the satellites of m as used in the fix.
+func (sv *skyView) use(m gsaMessage) {
+	for _, prn := range m.PRNs {
+		sv.used[satellite{System: m.System, PRN: prn}] = true
+	}
+}
+
+// inProgress returns true if a sequence has been started but not finished.
+func (sv *skyView) inProgress() bool {
+	return len(sv.pending) > 0
+}
+
+// hasSatellites returns true if a sequence has been finished.
+func (sv *skyView) hasSatellites() bool {
+	return len(sv.complete) > 0
+}
+
+// satellites returns the satellites of the finished sequences, by system and PRN
+// a satellite tracked on more than one signal has the best SNR.
+func (sv *skyView) satellites() []satellite {
+	idx := make(map[satellite]int)
+	var sats []satellite
+	for _, s := range sv.complete {
+		for _, sat := range s {
+			k := satellite{System: sat.System, PRN: sat.PRN}
+			i, ok := idx[k]
+			if !ok {
+				idx[k] = len(sats)
+				sats = append(sats, sat)
+				continue
+			}
+			if sat.SNR > sats[i].SNR {
+				sats[i].SNR = sat.SNR
+			}
+		}
+	}
+	for i := range sats {
+		// satellites of GNGSA without a system id are only known by PRN
+		sats[i].Used = sv.used[satellite{System: sats[i].System, PRN: sats[i].PRN}] || sv.used[satellite{PRN: sats[i].PRN}]
+	}
+
+	sort.Slice(sats, func(i, j int) bool {
+		if sats[i].System != sats[j].System {
+			return sats[i].System < sats[j].System
+		}
+		return sats[i].PRN < sats[j].PRN
+	})
+	return sats
+}
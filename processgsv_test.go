@@ -0,0 +1,201 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseGSV(t *testing.T) {
+	type args struct {
+		s string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    gsvMessage
+		wantErr bool
+	}{
+		{
+			name: "Valid 1",
+			args: args{s: "GPGSV,3,1,11,03,03,111,00,04,15,270,00,06,01,010,00,13,06,292,00*74"},
+			want: gsvMessage{
+				System: "GP",
+				Total:  3,
+				Number: 1,
+				InView: 11,
+				Satellites: []satellite{
+					{System: "GP", PRN: 3, Elevation: 3, Azimuth: 111, SNR: 0},
+					{System: "GP", PRN: 4, Elevation: 15, Azimuth: 270, SNR: 0},
+					{System: "GP", PRN: 6, Elevation: 1, Azimuth: 10, SNR: 0},
+					{System: "GP", PRN: 13, Elevation: 6, Azimuth: 292, SNR: 0},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Valid last of sequence",
+			args: args{s: "GPGSV,3,3,11,22,42,067,42,24,14,311,43,27,05,244,00,*61"},
+			want: gsvMessage{
+				System: "GP",
+				Total:  3,
+				Number: 3,
+				InView: 11,
+				Satellites: []satellite{
+					{System: "GP", PRN: 22, Elevation: 42, Azimuth: 67, SNR: 42},
+					{System: "GP", PRN: 24, Elevation: 14, Azimuth: 311, SNR: 43},
+					{System: "GP", PRN: 27, Elevation: 5, Azimuth: 244, SNR: 0},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Valid signal id, not tracked",
+			args: args{s: "GLGSV,1,1,02,71,45,120,35,72,,,,1*4D"},
+			want: gsvMessage{
+				System: "GL",
+				Signal: "1",
+				Total:  1,
+				Number: 1,
+				InView: 2,
+				Satellites: []satellite{
+					{System: "GL", PRN: 71, Elevation: 45, Azimuth: 120, SNR: 35},
+					{System: "GL", PRN: 72, Elevation: -1, Azimuth: -1, SNR: -1},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Valid none in view",
+			args: args{s: "GPGSV,1,1,00*79"},
+			want: gsvMessage{
+				System: "GP",
+				Total:  1,
+				Number: 1,
+			},
+			wantErr: false,
+		},
+		{
+			name:    "Invalid checksum",
+			args:    args{s: "GPGSV,1,1,00*78"},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid missing checksum",
+			args:    args{s: "GPGSV,1,1,00"},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid number",
+			args:    args{s: "GPGSV,2,3,11,03,03,111,00*49"},
+			wantErr: true,
+		},
+		{
+			name:    "Invalid PRN",
+			args:    args{s: "GPGSV,1,1,01,xx,03,111,00*4A"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+		t.Run(ttt.name, func(t *testing.T) {
+			got, err := parseGSV(ttt.args.s)
+			if (err != nil) != ttt.wantErr {
+				t.Errorf("parseGSV() error = %v, wantErr %v", err, ttt.wantErr)
+				return
+			}
+			if !ttt.wantErr && !reflect.DeepEqual(got, ttt.want) {
+				t.Errorf("parseGSV() = %+v, want %+v", got, ttt.want)
+			}
+		})
+	}
+}
+
+func Test_skyView(t *testing.T) {
+	sat := func(system string, prn, snr int) satellite {
+		return satellite{System: system, PRN: prn, Elevation: 10, Azimuth: 20, SNR: snr}
+	}
+	used := func(s satellite) satellite {
+		s.Used = true
+		return s
+	}
+
+	tests := []struct {
+		name         string
+		messages     []gsvMessage
+		used         []gsaMessage
+		wantProgress bool
+		want         []satellite
+	}{
+		{
+			name: "Complete sequences",
+			messages: []gsvMessage{
+				{System: "GP", Total: 2, Number: 1, Satellites: []satellite{sat("GP", 9, 30), sat("GP", 2, 20)}},
+				{System: "GL", Total: 1, Number: 1, Satellites: []satellite{sat("GL", 70, 25)}},
+				{System: "GP", Total: 2, Number: 2, Satellites: []satellite{sat("GP", 5, -1)}},
+			},
+			want: []satellite{sat("GL", 70, 25), sat("GP", 2, 20), sat("GP", 5, -1), sat("GP", 9, 30)},
+		},
+		{
+			name: "In progress",
+			messages: []gsvMessage{
+				{System: "GP", Total: 2, Number: 1, Satellites: []satellite{sat("GP", 9, 30)}},
+			},
+			wantProgress: true,
+		},
+		{
+			name: "Started part way through",
+			messages: []gsvMessage{
+				{System: "GP", Total: 2, Number: 2, Satellites: []satellite{sat("GP", 9, 30)}},
+			},
+		},
+		{
+			name: "Missed a sentence",
+			messages: []gsvMessage{
+				{System: "GP", Total: 3, Number: 1, Satellites: []satellite{sat("GP", 9, 30)}},
+				{System: "GP", Total: 3, Number: 3, Satellites: []satellite{sat("GP", 2, 20)}},
+			},
+		},
+		{
+			name: "Best signal",
+			messages: []gsvMessage{
+				{System: "GA", Signal: "1", Total: 1, Number: 1, Satellites: []satellite{sat("GA", 4, 22)}},
+				{System: "GA", Signal: "7", Total: 1, Number: 1, Satellites: []satellite{sat("GA", 4, 31)}},
+			},
+			want: []satellite{sat("GA", 4, 31)},
+		},
+		{
+			name: "Used in fix",
+			messages: []gsvMessage{
+				{System: "GP", Total: 1, Number: 1, Satellites: []satellite{sat("GP", 9, 30), sat("GP", 2, 20), sat("GP", 5, -1)}},
+				{System: "GL", Total: 1, Number: 1, Satellites: []satellite{sat("GL", 70, 25), sat("GL", 71, 22)}},
+			},
+			used: []gsaMessage{
+				{System: "GP", PRNs: []int{9, 70}},
+				{PRNs: []int{71}},
+			},
+			want: []satellite{sat("GL", 70, 25), used(sat("GL", 71, 22)), sat("GP", 2, 20), sat("GP", 5, -1), used(sat("GP", 9, 30))},
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+		t.Run(ttt.name, func(t *testing.T) {
+			sv := newSkyView()
+			for _, m := range ttt.messages {
+				sv.add(m)
+			}
+			for _, m := range ttt.used {
+				sv.use(m)
+			}
+
+			if sv.inProgress() != ttt.wantProgress {
+				t.Errorf("inProgress() = %v, want %v", sv.inProgress(), ttt.wantProgress)
+			}
+			if sv.hasSatellites() != (len(ttt.want) > 0) {
+				t.Errorf("hasSatellites() = %v, want %v", sv.hasSatellites(), len(ttt.want) > 0)
+			}
+			if got := sv.satellites(); !reflect.DeepEqual(got, ttt.want) {
+				t.Errorf("satellites() = %+v, want %+v", got, ttt.want)
+			}
+		})
+	}
+}
@@ -62,6 +62,33 @@ func parseRMCTime(fields []string) (time.Time, error) {
 	return time.Date(year, time.Month(mon), day, hour, min, sec, msec*1000000, time.UTC), nil
 }
 
+// parseRMCMotion extracts the speed over ground in knots and the course over ground in degrees from an **RMC line
+// either is -1 when the receiver doesn't report it.
+func parseRMCMotion(fields []string) (float64, float64, error) {
+	speed := -1.0
+	course := -1.0
+
+	if fields[7] != "" {
+		s, err := strconv.ParseFloat(fields[7], 64)
+		if err != nil {
+			log.Printf("%+v", err)
+			return 0.0, 0.0, err
+		}
+		speed = s
+	}
+
+	if fields[8] != "" {
+		c, err := strconv.ParseFloat(fields[8], 64)
+		if err != nil {
+			log.Printf("%+v", err)
+			return 0.0, 0.0, err
+		}
+		course = c
+	}
+
+	return speed, course, nil
+}
+
 // parseDegMinToFloat parses NMEA format of (d)ddmm.mmmm to decimal degrees.
 func parseDegMinToFloat(dm string) (float64, error) {
 	// handle latitude (0-90) or longitude (0-180) degrees
@@ -116,21 +143,101 @@ func latLonToGridsquare(lat, lon float64) (string, error) {
 	), nil
 }
 
-// parseRMC extracts the time, maidenhead gridsquare, latitude, and longitude from an **RMC line.
-func parseRMC(s string) (time.Time, string, float64, float64, error) {
+// latLonToExtendedGridsquare converts decimal latitude & longitude to an 8 character maidenhead gridsquare.
+func latLonToExtendedGridsquare(lat, lon float64) (string, error) {
+	l, err := latLonToGridsquare(lat, lon)
+	if err != nil {
+		log.Printf("%+v", err)
+		return "", err
+	}
+
+	// extended square divides the subsquare into 10 x 10
+	adjLat := lat + 90
+	adjLon := lon + 180
+	return fmt.Sprintf("%s%d%d",
+		l,
+		int(math.Mod(adjLon*60, 5)/0.5),
+		int(math.Mod(adjLat*60, 2.5)/0.25),
+	), nil
+}
+
+// gridsquareBounds returns the south west and north east corners of a 2, 4 or 6 character maidenhead gridsquare.
+func gridsquareBounds(grid string) (float64, float64, float64, float64, error) {
+	g := strings.ToUpper(grid)
+	if len(g) != 2 && len(g) != 4 && len(g) != 6 {
+		err := fmt.Errorf("invalid gridsquare %s", grid)
+		log.Printf("%+v", err)
+		return 0, 0, 0, 0, err
+	}
+
+	// field, square, subsquare
+	lon, lat := -180.0, -90.0
+	width, height := 20.0, 10.0
+	for i, r := range []struct {
+		first, last byte
+		lon, lat    float64
+	}{
+		{first: 'A', last: 'R', lon: 20, lat: 10},
+		{first: '0', last: '9', lon: 2, lat: 1},
+		{first: 'A', last: 'X', lon: 5.0 / 60, lat: 2.5 / 60},
+	} {
+		if 2*i == len(g) {
+			break
+		}
+
+		x, y := g[2*i], g[2*i+1]
+		if x < r.first || x > r.last || y < r.first || y > r.last {
+			err := fmt.Errorf("invalid gridsquare %s", grid)
+			log.Printf("%+v", err)
+			return 0, 0, 0, 0, err
+		}
+		lon += float64(x-r.first) * r.lon
+		lat += float64(y-r.first) * r.lat
+		width, height = r.lon, r.lat
+	}
+
+	return lat, lon, lat + height, lon + width, nil
+}
+
+// truncateGrid returns the first n characters of grid, or grid if it is shorter.
+func truncateGrid(grid string, n int) string {
+	if n > 0 && len(grid) > n {
+		return grid[:n]
+	}
+	return grid
+}
+
+// gridsquareOfLength returns the gridsquare for v with n characters, empty if there isn't one.
+func gridsquareOfLength(v gpsValues, n int) string {
+	if v.Gridsquare == "" {
+		return ""
+	}
+
+	if n == 8 {
+		l, err := latLonToExtendedGridsquare(v.Latitude, v.Longitude)
+		if err != nil {
+			return ""
+		}
+		return l
+	}
+	return truncateGrid(v.Gridsquare, n)
+}
+
+// parseRMC extracts the time, maidenhead gridsquare, latitude, longitude, speed, and course from an **RMC line.
+func parseRMC(s string) (time.Time, string, float64, float64, float64, float64, error) {
 	// parse comma delimted records to fields
 	r := csv.NewReader(strings.NewReader(s))
 	fields, err := r.Read()
 	if err != nil {
 		log.Printf("%+v", err)
-		return time.Time{}, "", 0.0, 0.0, err
+		return time.Time{}, "", 0.0, 0.0, 0.0, 0.0, err
 	}
 
 	// need at least 11 fields to get time, location, and checksum
 	if len(fields) < 11 {
 		err := fmt.Errorf("invalid RMC line")
 		log.Printf("%+v", err)
-		return time.Time{}, "", 0.0, 0.0, err
+		return time.Time{}, "", 0.0, 0.0, 0.0, 0.0, err
 	}
 
 	// validate checksum
@@ -139,38 +246,38 @@ func parseRMC(s string) (time.Time, string, float64, float64, error) {
 	if len(strchk) < 2 {
 		err := fmt.Errorf("missing checksum")
 		log.Printf("%+v", err)
-		return time.Time{}, "", 0.0, 0.0, err
+		return time.Time{}, "", 0.0, 0.0, 0.0, 0.0, err
 	}
 
 	for _, c := range strchk[0] {
 		checksum ^= int(c)
 	}
 	if fmt.Sprintf("%X", checksum) != strings.TrimSpace(strchk[1]) {
-		err := fmt.Errorf("RMC line bad checksum")
+		err := fmt.Errorf("RMC line %w", errBadChecksum)
 		log.Printf("%+v", err)
 		// fmt.Printf("%X ", checksum)
-		return time.Time{}, "", 0.0, 0.0, err
+		return time.Time{}, "", 0.0, 0.0, 0.0, 0.0, err
 	}
 
 	// status is valid?
 	if fields[2] != "A" {
-		err := fmt.Errorf("receiver not in valid state")
+		err := errInvalidState
 		log.Printf("%+v", err)
-		return time.Time{}, "", 0.0, 0.0, err
+		return time.Time{}, "", 0.0, 0.0, 0.0, 0.0, err
 	}
 
 	// get time
 	t, err := parseRMCTime(fields)
 	if err != nil {
 		log.Printf("%+v", err)
-		return time.Time{}, "", 0.0, 0.0, err
+		return time.Time{}, "", 0.0, 0.0, 0.0, 0.0, err
 	}
 
 	// get latitude
 	lat, err := parseDegMinToFloat(fields[3])
 	if err != nil {
 		log.Printf("%+v", err)
-		return time.Time{}, "", 0.0, 0.0, err
+		return time.Time{}, "", 0.0, 0.0, 0.0, 0.0, err
 	}
 	if fields[4] == "S" {
 		lat = -lat
@@ -180,7 +287,7 @@ func parseRMC(s string) (time.Time, string, float64, float64, error) {
 	lon, err := parseDegMinToFloat(fields[5])
 	if err != nil {
 		log.Printf("%+v", err)
-		return time.Time{}, "", 0.0, 0.0, err
+		return time.Time{}, "", 0.0, 0.0, 0.0, 0.0, err
 	}
 	if fields[6] == "W" {
 		lon = -lon
@@ -190,8 +297,15 @@ func parseRMC(s string) (time.Time, string, float64, float64, error) {
 	gridsquare, err := latLonToGridsquare(lat, lon)
 	if err != nil {
 		log.Printf("%+v", err)
-		return time.Time{}, "", 0.0, 0.0, err
+		return time.Time{}, "", 0.0, 0.0, 0.0, 0.0, err
+	}
+
+	// get speed & course
+	speed, course, err := parseRMCMotion(fields)
+	if err != nil {
+		log.Printf("%+v", err)
+		return time.Time{}, "", 0.0, 0.0, 0.0, 0.0, err
 	}
 
-	return t, gridsquare, lat, lon, nil
+	return t, gridsquare, lat, lon, speed, course, nil
 }
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"math"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -208,6 +210,126 @@ func Test_latLonToGridsquare(t *testing.T) {
 	}
 }
 
+func Test_latLonToExtendedGridsquare(t *testing.T) {
+	type args struct {
+		lat float64
+		lon float64
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "Budapest",
+			args:    args{lat: 47.44304, lon: 19.000968},
+			want:    "JN97mk06",
+			wantErr: false,
+		},
+		{
+			name:    "Washington DC",
+			args:    args{lat: 38.92, lon: -77.065},
+			want:    "FM18lw20",
+			wantErr: false,
+		},
+		{
+			name:    "Equator West",
+			args:    args{lat: 0.0, lon: 0.0},
+			want:    "JJ00aa00",
+			wantErr: false,
+		},
+		{
+			name:    "Nowhere",
+			args:    args{lat: 91.0, lon: 181.0},
+			want:    "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := latLonToExtendedGridsquare(ttt.args.lat, ttt.args.lon)
+			if (err != nil) != ttt.wantErr {
+				t.Errorf("latLonToExtendedGridsquare() error = %v, wantErr %v", err, ttt.wantErr)
+				return
+			}
+			if got != ttt.want {
+				t.Errorf("latLonToExtendedGridsquare() = %v, want %v", got, ttt.want)
+			}
+		})
+	}
+}
+
+func Test_gridsquareBounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		grid    string
+		want    [4]float64
+		wantErr bool
+	}{
+		{
+			name: "Field",
+			grid: "FM",
+			want: [4]float64{30, -80, 40, -60},
+		},
+		{
+			name: "Square",
+			grid: "FM18",
+			want: [4]float64{38, -78, 39, -76},
+		},
+		{
+			name: "Subsquare",
+			grid: "FM18lw",
+			want: [4]float64{38 + 22*2.5/60, -78 + 11*5.0/60, 38 + 23*2.5/60, -78 + 12*5.0/60},
+		},
+		{
+			name: "Lowercase",
+			grid: "jn97",
+			want: [4]float64{47, 18, 48, 20},
+		},
+		{
+			name:    "Bad field",
+			grid:    "ZZ00",
+			wantErr: true,
+		},
+		{
+			name:    "Bad length",
+			grid:    "FM1",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			s, w, n, e, err := gridsquareBounds(ttt.grid)
+			if (err != nil) != ttt.wantErr {
+				t.Errorf("gridsquareBounds() error = %v, wantErr %v", err, ttt.wantErr)
+				return
+			}
+			if ttt.wantErr {
+				return
+			}
+
+			got := [4]float64{s, w, n, e}
+			for i := range got {
+				if math.Abs(got[i]-ttt.want[i]) > 1e-9 {
+					t.Errorf("gridsquareBounds() = %v, want %v", got, ttt.want)
+					break
+				}
+			}
+
+			// the square contains its own center
+			l, err := latLonToGridsquare((s+n)/2, (w+e)/2)
+			if err != nil || !strings.EqualFold(truncateGrid(l, len(ttt.grid)), ttt.grid) {
+				t.Errorf("gridsquareBounds() center is in %v, want %v", l, ttt.grid)
+			}
+		})
+	}
+}
+
 func Test_parseRMC(t *testing.T) {
 	type args struct {
 		s string
@@ -219,6 +341,8 @@ func Test_parseRMC(t *testing.T) {
 		want1   string
 		want2   float64
 		want3   float64
+		want4   float64
+		want5   float64
 		wantErr bool
 	}{
 		{
@@ -228,6 +352,8 @@ func Test_parseRMC(t *testing.T) {
 			want1:   "JN97mk",
 			want2:   47.44304,
 			want3:   19.000968333333333,
+			want4:   0.149,
+			want5:   -1,
 			wantErr: false,
 		},
 		{
@@ -237,6 +363,8 @@ func Test_parseRMC(t *testing.T) {
 			want1:   "GG87jc",
 			want2:   -22.912328333333335,
 			want3:   -43.18261666666667,
+			want4:   0.149,
+			want5:   -1,
 			wantErr: false,
 		},
 		{
@@ -246,6 +374,8 @@ func Test_parseRMC(t *testing.T) {
 			want1:   "FM18lw",
 			want2:   38.92,
 			want3:   -77.065,
+			want4:   0.149,
+			want5:   -1,
 			wantErr: false,
 		},
 		{
@@ -255,6 +385,19 @@ func Test_parseRMC(t *testing.T) {
 			want1:   "RB32id",
 			want2:   -77.855000,
 			want3:   166.70666666666668,
+			want4:   0.149,
+			want5:   -1,
+			wantErr: false,
+		},
+		{
+			name:    "Washington DC moving",
+			args:    args{s: "GNRMC,203434.00,A,3855.2,N,07703.9,W,22.4,054.7,180120,,,A*6B"},
+			want:    time.Date(2020, time.Month(1), 18, 20, 34, 34, 0, time.UTC),
+			want1:   "FM18lw",
+			want2:   38.92,
+			want3:   -77.065,
+			want4:   22.4,
+			want5:   54.7,
 			wantErr: false,
 		},
 		{
@@ -264,6 +407,8 @@ func Test_parseRMC(t *testing.T) {
 			want1:   "",
 			want2:   0.0,
 			want3:   0.0,
+			want4:   0.0,
+			want5:   0.0,
 			wantErr: true,
 		},
 		{
@@ -273,6 +418,8 @@ func Test_parseRMC(t *testing.T) {
 			want1:   "",
 			want2:   0.0,
 			want3:   0.0,
+			want4:   0.0,
+			want5:   0.0,
 			wantErr: true,
 		},
 		{
@@ -282,6 +429,8 @@ func Test_parseRMC(t *testing.T) {
 			want1:   "",
 			want2:   0.0,
 			want3:   0.0,
+			want4:   0.0,
+			want5:   0.0,
 			wantErr: true,
 		},
 	}
@@ -289,7 +438,7 @@ func Test_parseRMC(t *testing.T) {
 		ttt := tt
 
 		t.Run(tt.name, func(t *testing.T) {
-			got, got1, got2, got3, err := parseRMC(ttt.args.s)
+			got, got1, got2, got3, got4, got5, err := parseRMC(ttt.args.s)
 			if (err != nil) != ttt.wantErr {
 				t.Errorf("parseRMC() error = %v, wantErr %v", err, ttt.wantErr)
 				return
@@ -306,6 +455,12 @@ func Test_parseRMC(t *testing.T) {
 			if got3 != ttt.want3 {
 				t.Errorf("parseRMC() got3 = %v, want %v", got3, ttt.want3)
 			}
+			if got4 != ttt.want4 {
+				t.Errorf("parseRMC() got4 = %v, want %v", got4, ttt.want4)
+			}
+			if got5 != ttt.want5 {
+				t.Errorf("parseRMC() got5 = %v, want %v", got5, ttt.want5)
+			}
 		})
 	}
 }
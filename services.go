@@ -0,0 +1,353 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// service is something started from the application configuration that runs in the background.
+type service interface {
+	start()
+	stop()
+}
+
+// poller polls the gps device every 30 secs until we get the first reading, then at the poll rate.
+type poller struct {
+	rate time.Duration
+	quit chan struct{}
+}
+
+// newPoller returns a poller that polls every rate once the gps device has answered.
+func newPoller(rate time.Duration) *poller {
+	return &poller{
+		rate: rate,
+		quit: make(chan struct{}),
+	}
+}
+
+// start polls the gps device in the background.
+func (p *poller) start() {
+	go func() {
+		startup := true
+		ticker := time.NewTicker(30 * time.Second)
+
+		for {
+			select {
+			case <-ticker.C:
+				if gatherGpsData(true) && startup {
+					ticker.Stop()
+					ticker = time.NewTicker(p.rate)
+					startup = false
+				}
+			case <-p.quit:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// stop stops polling, a poll in progress still finishes.
+func (p *poller) stop() {
+	close(p.quit)
+}
+
+// apiService runs the local api.
+type apiService struct {
+	srv *http.Server
+}
+
+// start does nothing, the api is already listening.
+func (a *apiService) start() {}
+
+// stop closes the api.
+func (a *apiService) stop() {
+	err := a.srv.Close()
+	if err != nil {
+		log.Printf("%+v", err)
+	}
+}
+
+// serviceSection is a part of the configuration and the service started from it.
+type serviceSection struct {
+	name string
+
+	// returns the part of cfg the service is started from
+	config func(cfg configuration) interface{}
+
+	// returns the service for cfg, nil if it is turned off or there is nothing to run in the background
+	newService func(cfg configuration) (service, error)
+}
+
+// serviceSections are the parts of the configuration with something running for them, in the order they are started.
+var serviceSections = []serviceSection{
+	{
+		name:   "hooks",
+		config: func(cfg configuration) interface{} { return cfg.Hooks },
+		newService: func(cfg configuration) (service, error) {
+			return nil, initHooks(cfg.Hooks)
+		},
+	},
+	{
+		// local api is optional
+		name:   "api",
+		config: func(cfg configuration) interface{} { return cfg.API },
+		newService: func(cfg configuration) (service, error) {
+			if cfg.API.Address == "" {
+				return nil, nil
+			}
+			srv, err := startAPI(cfg.API.Address)
+			if err != nil {
+				return nil, err
+			}
+			return &apiService{srv: srv}, nil
+		},
+	},
+	{
+		// mqtt publisher is optional
+		name:   "mqtt",
+		config: func(cfg configuration) interface{} { return cfg.MQTT },
+		newService: func(cfg configuration) (service, error) {
+			if cfg.MQTT.Broker == "" {
+				return nil, nil
+			}
+			return newMQTTPublisher(cfg.MQTT)
+		},
+	},
+	{
+		// wsjt-x integration is optional
+		name:   "wsjtx",
+		config: func(cfg configuration) interface{} { return cfg.WSJTX },
+		newService: func(cfg configuration) (service, error) {
+			if cfg.WSJTX.Address == "" {
+				return nil, nil
+			}
+			return newWSJTXIntegration(cfg.WSJTX)
+		},
+	},
+	{
+		// js8call integration is optional
+		name:   "js8call",
+		config: func(cfg configuration) interface{} { return cfg.JS8Call },
+		newService: func(cfg configuration) (service, error) {
+			setJS8Call(nil)
+			if cfg.JS8Call.Address == "" {
+				return nil, nil
+			}
+			ji, err := newJS8CallIntegration(cfg.JS8Call)
+			if err != nil {
+				return nil, err
+			}
+			setJS8Call(ji)
+			return ji, nil
+		},
+	},
+	{
+		// udp broadcast is optional
+		name:   "udpbroadcast",
+		config: func(cfg configuration) interface{} { return cfg.UDPBroadcast },
+		newService: func(cfg configuration) (service, error) {
+			if cfg.UDPBroadcast.Address == "" {
+				return nil, nil
+			}
+			return newUDPBroadcaster(cfg.UDPBroadcast)
+		},
+	},
+	{
+		// aprs beaconing is optional
+		name:   "aprs",
+		config: func(cfg configuration) interface{} { return cfg.APRS },
+		newService: func(cfg configuration) (service, error) {
+			if cfg.APRS.Callsign == "" {
+				return nil, nil
+			}
+			return newAPRSBeacon(cfg.APRS)
+		},
+	},
+	{
+		// cloudlog integration is optional
+		name:   "cloudlog",
+		config: func(cfg configuration) interface{} { return cfg.Cloudlog },
+		newService: func(cfg configuration) (service, error) {
+			if cfg.Cloudlog.URL == "" {
+				return nil, nil
+			}
+			return newCloudlogIntegration(cfg.Cloudlog)
+		},
+	},
+	{
+		// track logging is optional
+		name:   "track",
+		config: func(cfg configuration) interface{} { return cfg.Track },
+		newService: func(cfg configuration) (service, error) {
+			if cfg.Track.Directory == "" {
+				return nil, nil
+			}
+			return newTrackLogger(cfg.Track)
+		},
+	},
+	{
+		// nothing to poll until the gps device is set up
+		name:   "gpsdevice",
+		config: func(cfg configuration) interface{} { return cfg.GPSDevice },
+		newService: func(cfg configuration) (service, error) {
+			if cfg.GPSDevice.Port == "" {
+				return nil, nil
+			}
+			return newPoller(cfg.GPSDevice.PollRate * time.Second), nil
+		},
+	},
+}
+
+// changedSections returns the sections that differ between prev and cfg.
+func changedSections(prev, cfg configuration) []serviceSection {
+	var changed []serviceSection
+	for _, ss := range serviceSections {
+		if !reflect.DeepEqual(ss.config(prev), ss.config(cfg)) {
+			changed = append(changed, ss)
+		}
+	}
+	return changed
+}
+
+// services are the poller and the optional integrations running for a configuration.
+type services struct {
+	// by the name of the section they were started from
+	running map[string]service
+}
+
+var (
+	// services running for the application configuration.
+	running   *services
+	runningMu sync.Mutex
+)
+
+// startServices starts the poller and the optional integrations of cfg
+// nothing is left running if any of them can't be started.
+func startServices(cfg configuration) (*services, error) {
+	s := &services{running: make(map[string]service)}
+
+	err := s.start(cfg, serviceSections)
+	if err != nil {
+		s.stop(serviceSections)
+		setJS8Call(nil)
+		return nil, err
+	}
+	return s, nil
+}
+
+// start starts the services of sections for cfg, stopping at the first that can't be started
+// whatever was running for them has to be stopped first.
+func (s *services) start(cfg configuration, sections []serviceSection) error {
+	for _, ss := range sections {
+		sv, err := ss.newService(cfg)
+		if err != nil {
+			return err
+		}
+		if sv != nil {
+			sv.start()
+			s.running[ss.name] = sv
+		}
+	}
+	return nil
+}
+
+// stop stops the services of sections, last started first.
+func (s *services) stop(sections []serviceSection) {
+	for i := len(sections) - 1; i >= 0; i-- {
+		sv, ok := s.running[sections[i].name]
+		if ok {
+			sv.stop()
+			delete(s.running, sections[i].name)
+		}
+	}
+}
+
+// startRunning starts the services for the application configuration.
+func startRunning() error {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+
+	cfg := currentConfig()
+	history.resize(cfg.History.size())
+
+	s, err := startServices(cfg)
+	if err != nil {
+		return err
+	}
+	running = s
+	return nil
+}
+
+// stopRunning stops the services for the application configuration.
+func stopRunning() {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+
+	if running != nil {
+		running.stop(serviceSections)
+		running = nil
+	}
+}
+
+// applyConfig replaces the application configuration with cfg and restarts the services of the sections that changed
+// the previous configuration is restarted if cfg can't be.
+func applyConfig(cfg configuration) error {
+	runningMu.Lock()
+	defer runningMu.Unlock()
+
+	if running == nil {
+		running = &services{running: make(map[string]service)}
+	}
+
+	prev := currentConfig()
+	changed := changedSections(prev, cfg)
+
+	// services have to be stopped first so they let go of their ports
+	running.stop(changed)
+
+	setConfig(cfg)
+	err := running.start(cfg, changed)
+	if err != nil {
+		running.stop(changed)
+		setConfig(prev)
+
+		rerr := running.start(prev, changed)
+		if rerr != nil {
+			log.Printf("%+v", rerr)
+			running.stop(changed)
+			return fmt.Errorf("%v, and the previous configuration couldn't be restarted: %v", err, rerr)
+		}
+		return err
+	}
+
+	history.resize(cfg.History.size())
+	return nil
+}
+
+// reloadConfig reads the configuration file again and applies it if it changed, the running configuration is kept if it can't be.
+func reloadConfig() error {
+	cfg, err := readConfig(options.configPath)
+	if err != nil {
+		log.Printf("configuration in %s not reloaded, keeping the running one", options.configPath)
+		return err
+	}
+
+	if reflect.DeepEqual(cfg, currentConfig()) {
+		log.Printf("configuration in %s unchanged", options.configPath)
+		return nil
+	}
+
+	err = applyConfig(cfg)
+	if err != nil {
+		log.Printf("configuration in %s not applied, keeping the running one", options.configPath)
+		return err
+	}
+
+	log.Printf("reloaded configuration from %s", options.configPath)
+	return nil
+}
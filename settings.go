@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// limits of the gps device settings.
+const (
+	minBaud     = 300
+	maxBaud     = 921600
+	minPollRate = 10
+	maxPollRate = 24 * 60 * 60
+	maxMaxHDOP  = 50
+)
+
+// serialBauds are the baud rates gps devices usually use, offered in the settings window.
+var serialBauds = []int{4800, 9600, 19200, 38400, 57600, 115200}
+
+// settings are the parts of the configuration that can be changed from the settings window
+// everything else in the configuration file is kept as it is.
+type settings struct {
+	Port string
+	Baud int
+	// seconds
+	PollRate int
+	MaxHDOP  float64
+
+	// integrations are off when empty
+	APIAddress          string
+	MQTTBroker          string
+	WSJTXAddress        string
+	JS8CallAddress      string
+	UDPBroadcastAddress string
+	TrackDirectory      string
+}
+
+// settingsOf returns the settings of cfg.
+func settingsOf(cfg configuration) settings {
+	return settings{
+		Port:                cfg.GPSDevice.Port,
+		Baud:                cfg.GPSDevice.Baud,
+		PollRate:            int(cfg.GPSDevice.PollRate),
+		MaxHDOP:             cfg.GPSDevice.maxHDOP(),
+		APIAddress:          cfg.API.Address,
+		MQTTBroker:          cfg.MQTT.Broker,
+		WSJTXAddress:        cfg.WSJTX.Address,
+		JS8CallAddress:      cfg.JS8Call.Address,
+		UDPBroadcastAddress: cfg.UDPBroadcast.Address,
+		TrackDirectory:      cfg.Track.Directory,
+	}
+}
+
+// applyTo returns cfg changed to s.
+func (s settings) applyTo(cfg configuration) configuration {
+	cfg.GPSDevice.Port = s.Port
+	cfg.GPSDevice.Baud = s.Baud
+	cfg.GPSDevice.PollRate = time.Duration(s.PollRate)
+	cfg.GPSDevice.MaxHDOP = s.MaxHDOP
+	cfg.API.Address = s.APIAddress
+	cfg.MQTT.Broker = s.MQTTBroker
+	cfg.WSJTX.Address = s.WSJTXAddress
+	cfg.JS8Call.Address = s.JS8CallAddress
+	cfg.UDPBroadcast.Address = s.UDPBroadcastAddress
+	cfg.Track.Directory = s.TrackDirectory
+
+	return cfg
+}
+
+// validate returns an error describing the first setting that can't be used, named the way the settings window does.
+func (s settings) validate() error {
+	if s.Port == "" {
+		return fmt.Errorf("Port is required")
+	}
+
+	labels := make(map[string]string)
+	for _, sv := range s.values() {
+		labels[strings.Join(sv.path, ".")] = sv.label
+	}
+	for _, p := range checkConfig(s.applyTo(newConfiguration())) {
+		if l, ok := labels[p.Key]; ok {
+			return fmt.Errorf("%s %s", l, p.Message)
+		}
+	}
+
+	return nil
+}
+
+// settingValue is where a setting goes in the configuration file.
+type settingValue struct {
+	path []string
+	// what the settings window calls it
+	label string
+	value interface{}
+}
+
+// values returns where each of the settings goes in the configuration file, integrations that are off are nil.
+func (s settings) values() []settingValue {
+	optional := func(v string) interface{} {
+		if v == "" {
+			return nil
+		}
+		return v
+	}
+
+	return []settingValue{
+		{path: []string{"gpsdevice", "port"}, label: "Port", value: s.Port},
+		{path: []string{"gpsdevice", "baud"}, label: "Baud", value: s.Baud},
+		{path: []string{"gpsdevice", "pollrate"}, label: "Poll rate", value: s.PollRate},
+		{path: []string{"gpsdevice", "maxhdop"}, label: "Maximum HDOP", value: s.MaxHDOP},
+		{path: []string{"api", "address"}, label: "API address", value: optional(s.APIAddress)},
+		{path: []string{"mqtt", "broker"}, label: "MQTT broker", value: optional(s.MQTTBroker)},
+		{path: []string{"wsjtx", "address"}, label: "WSJT-X address", value: optional(s.WSJTXAddress)},
+		{path: []string{"js8call", "address"}, label: "JS8Call address", value: optional(s.JS8CallAddress)},
+		{path: []string{"udpbroadcast", "address"}, label: "UDP broadcast address", value: optional(s.UDPBroadcastAddress)},
+		{path: []string{"track", "directory"}, label: "Track directory", value: optional(s.TrackDirectory)},
+	}
+}
+
+// setYAMLValue sets the key at path in the mapping m to v, or removes it if v is nil
+// sections are added as needed and removed when they end up empty, comments and everything else in m are kept.
+func setYAMLValue(m *yaml.Node, path []string, v interface{}) error {
+	// keys and values alternate
+	i := 0
+	for i < len(m.Content) && m.Content[i].Value != path[0] {
+		i += 2
+	}
+	found := i < len(m.Content)
+
+	if len(path) > 1 {
+		section := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		if found && m.Content[i+1].Kind == yaml.MappingNode {
+			section = m.Content[i+1]
+		} else if v == nil {
+			// nothing there to remove
+			return nil
+		}
+
+		err := setYAMLValue(section, path[1:], v)
+		if err != nil {
+			return err
+		}
+
+		if len(section.Content) == 0 {
+			if found {
+				m.Content = append(m.Content[:i], m.Content[i+2:]...)
+			}
+			return nil
+		}
+		if found {
+			m.Content[i+1] = section
+			return nil
+		}
+		m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: path[0]}, section)
+		return nil
+	}
+
+	if v == nil {
+		if found {
+			m.Content = append(m.Content[:i], m.Content[i+2:]...)
+		}
+		return nil
+	}
+
+	value := &yaml.Node{}
+	err := value.Encode(v)
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+
+	if found {
+		// keep the comments on the line
+		prev := m.Content[i+1]
+		value.HeadComment = prev.HeadComment
+		value.LineComment = prev.LineComment
+		value.FootComment = prev.FootComment
+
+		m.Content[i+1] = value
+		return nil
+	}
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: path[0]}, value)
+	return nil
+}
+
+// saveSettings writes s into the configuration file fn, keeping the rest of it along with its comments
+// the file is replaced in one step so gps-qth-qtr never sees half of it.
+func saveSettings(fn string, s settings) error {
+	var doc yaml.Node
+
+	// #nosec G304
+	b, err := ioutil.ReadFile(fn)
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("%+v", err)
+		return err
+	}
+	err = yaml.Unmarshal(b, &doc)
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+
+	// an empty file has no document
+	if doc.Kind == 0 {
+		doc = yaml.Node{
+			Kind:    yaml.DocumentNode,
+			Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}},
+		}
+	}
+	m := doc.Content[0]
+	if m.Kind != yaml.MappingNode {
+		err = fmt.Errorf("%s isn't a mapping of settings", fn)
+		log.Printf("%+v", err)
+		return err
+	}
+
+	for _, sv := range s.values() {
+		err = setYAMLValue(m, sv.path, sv.value)
+		if err != nil {
+			return err
+		}
+	}
+
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	err = enc.Encode(&doc)
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+	err = enc.Close()
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+
+	return writeFileAtomic(fn, out.Bytes())
+}
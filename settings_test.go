@@ -0,0 +1,260 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_settings_validate(t *testing.T) {
+	good := settings{
+		Port:     "COM3",
+		Baud:     9600,
+		PollRate: 900,
+		MaxHDOP:  5,
+	}
+
+	tests := []struct {
+		name    string
+		change  func(s *settings)
+		wantErr bool
+	}{
+		{
+			name:   "Good",
+			change: func(s *settings) {},
+		},
+		{
+			name: "Integrations",
+			change: func(s *settings) {
+				s.APIAddress = "127.0.0.1:8080"
+				s.MQTTBroker = "tcp://broker.example.com:1883"
+				s.WSJTXAddress = "127.0.0.1:2237"
+				s.JS8CallAddress = "localhost:2442"
+				s.UDPBroadcastAddress = "255.255.255.255:2238"
+			},
+		},
+		{
+			name:    "No port",
+			change:  func(s *settings) { s.Port = "" },
+			wantErr: true,
+		},
+		{
+			name:    "Baud",
+			change:  func(s *settings) { s.Baud = 0 },
+			wantErr: true,
+		},
+		{
+			name:    "Poll rate too fast",
+			change:  func(s *settings) { s.PollRate = 1 },
+			wantErr: true,
+		},
+		{
+			name:    "No HDOP",
+			change:  func(s *settings) { s.MaxHDOP = 0 },
+			wantErr: true,
+		},
+		{
+			name:    "MQTT broker without scheme",
+			change:  func(s *settings) { s.MQTTBroker = "broker.example.com" },
+			wantErr: true,
+		},
+		{
+			name:    "WSJT-X without port",
+			change:  func(s *settings) { s.WSJTXAddress = "127.0.0.1" },
+			wantErr: true,
+		},
+		{
+			name:    "API port out of range",
+			change:  func(s *settings) { s.APIAddress = "127.0.0.1:70000" },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+		t.Run(ttt.name, func(t *testing.T) {
+			s := good
+			ttt.change(&s)
+			if err := s.validate(); (err != nil) != ttt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, ttt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_settings_applyTo(t *testing.T) {
+	var cfg configuration
+	cfg.GPSDevice.Port = "COM3"
+	cfg.MQTT.Username = "station"
+	cfg.WSJTX.GridLength = 6
+
+	s := settingsOf(cfg)
+	if s.MaxHDOP != defaultMaxHDOP {
+		t.Errorf("settingsOf() MaxHDOP = %v, want %v", s.MaxHDOP, defaultMaxHDOP)
+	}
+
+	s.Port = "COM4"
+	s.PollRate = 60
+	s.MQTTBroker = "tcp://broker.example.com"
+	got := s.applyTo(cfg)
+	if got.GPSDevice.Port != "COM4" || got.GPSDevice.PollRate != 60 || got.MQTT.Broker != "tcp://broker.example.com" {
+		t.Errorf("applyTo() = %+v", got)
+	}
+	if got.MQTT.Username != "station" || got.WSJTX.GridLength != 6 {
+		t.Errorf("applyTo() lost settings not in the window, %+v", got)
+	}
+}
+
+func Test_saveSettings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gps-qth-qtr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fn := filepath.Join(dir, "gps-qth-qtr.yaml")
+	err = ioutil.WriteFile(fn, []byte(`gpsdevice:
+  port: COM3
+  baud: 4800
+  pollrate: 900
+mqtt:
+  broker: tcp://broker.example.com
+  username: station
+wsjtx:
+  address: 127.0.0.1:2237
+history:
+  size: 50
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		create bool
+		s      settings
+		want   string
+	}{
+		{
+			name: "Existing",
+			s: settings{
+				Port:           "COM4",
+				Baud:           9600,
+				PollRate:       60,
+				MaxHDOP:        2.5,
+				APIAddress:     "127.0.0.1:8080",
+				MQTTBroker:     "",
+				WSJTXAddress:   "",
+				JS8CallAddress: "",
+			},
+			want: `gpsdevice:
+  port: COM4
+  baud: 9600
+  pollrate: 60
+  maxhdop: 2.5
+mqtt:
+  username: station
+history:
+  size: 50
+api:
+  address: 127.0.0.1:8080
+`,
+		},
+		{
+			name:   "New",
+			create: true,
+			s:      settings{Port: "COM3", Baud: 4800, PollRate: 900, MaxHDOP: 5},
+			want: `gpsdevice:
+  port: COM3
+  baud: 4800
+  pollrate: 900
+  maxhdop: 5
+`,
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+		t.Run(ttt.name, func(t *testing.T) {
+			fn := fn
+			if ttt.create {
+				fn = filepath.Join(dir, "new.yaml")
+			}
+
+			err := saveSettings(fn, ttt.s)
+			if err != nil {
+				t.Fatalf("saveSettings() error = %v", err)
+			}
+
+			b, err := ioutil.ReadFile(fn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != ttt.want {
+				t.Errorf("saveSettings() wrote\n%s\nwant\n%s", b, ttt.want)
+			}
+
+			// and it reads back the same
+			cfg, err := readConfig(fn)
+			if err != nil {
+				t.Fatalf("readConfig() error = %v", err)
+			}
+			if got := settingsOf(cfg); got != ttt.s {
+				t.Errorf("readConfig() settings = %+v, want %+v", got, ttt.s)
+			}
+		})
+	}
+}
+
+func Test_saveSettings_comments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gps-qth-qtr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fn := filepath.Join(dir, "gps-qth-qtr.yaml")
+	err = ioutil.WriteFile(fn, []byte(`# gps-qth-qtr in the shack
+
+# the puck on the roof
+gpsdevice:
+  port: COM3 # USB adapter
+  baud: 4800
+  pollrate: 900 # every 15 minutes
+# turned off for now
+wsjtx:
+  address: 127.0.0.1:2237
+hooks:
+  # let the logger know
+  - event: gridchanged
+    command: [notify, "{{.Gridsquare}}"]
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = saveSettings(fn, settings{Port: "COM4", Baud: 4800, PollRate: 60, MaxHDOP: 5})
+	if err != nil {
+		t.Fatalf("saveSettings() error = %v", err)
+	}
+
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# gps-qth-qtr in the shack
+
+# the puck on the roof
+gpsdevice:
+  port: COM4 # USB adapter
+  baud: 4800
+  pollrate: 60 # every 15 minutes
+  maxhdop: 5
+hooks:
+  # let the logger know
+  - event: gridchanged
+    command: [notify, "{{.Gridsquare}}"]
+`
+	if string(b) != want {
+		t.Errorf("saveSettings() wrote\n%s\nwant\n%s", b, want)
+	}
+}
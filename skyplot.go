@@ -0,0 +1,63 @@
+package main
+
+import (
+	"math"
+)
+
+// skySystem is how a satellite system is shown on the sky plot and SNR chart.
+type skySystem struct {
+	Name    string
+	R, G, B byte
+}
+
+var (
+	// satellite systems by talker.
+	skySystems = map[string]skySystem{
+		"GP": {Name: "GPS", R: 0x1f, G: 0x77, B: 0xb4},
+		"GL": {Name: "GLONASS", R: 0xd6, G: 0x27, B: 0x28},
+		"GA": {Name: "Galileo", R: 0x2c, G: 0xa0, B: 0x2c},
+		"GB": {Name: "BeiDou", R: 0xff, G: 0x7f, B: 0x0e},
+		"BD": {Name: "BeiDou", R: 0xff, G: 0x7f, B: 0x0e},
+		"GQ": {Name: "QZSS", R: 0x94, G: 0x67, B: 0xbd},
+	}
+
+	// systems the receiver doesn't say
+	skySystemOther = skySystem{Name: "Other", R: 0x7f, G: 0x7f, B: 0x7f}
+
+	// order of the legend
+	skyLegend = []string{"GP", "GL", "GA", "GB", "GQ"}
+)
+
+// skySystemOf returns how the satellites of talker system are shown.
+func skySystemOf(system string) skySystem {
+	s, ok := skySystems[system]
+	if !ok {
+		return skySystemOther
+	}
+	return s
+}
+
+// skyPoint returns where sat goes on a sky plot of radius r centered on cx, cy
+// north is up, east is right, the horizon is the edge and straight up is the center
+// ok is false if the receiver doesn't know where the satellite is.
+func skyPoint(sat satellite, cx, cy, r int) (x, y int, ok bool) {
+	if sat.Elevation < 0 || sat.Elevation > 90 || sat.Azimuth < 0 {
+		return 0, 0, false
+	}
+
+	d := float64(r) * float64(90-sat.Elevation) / 90
+	a := float64(sat.Azimuth) * math.Pi / 180
+
+	return cx + int(math.Round(d*math.Sin(a))), cy - int(math.Round(d*math.Cos(a))), true
+}
+
+// snrBarHeight returns how tall the bar for snr is in a chart h high, full at dashboardMaxSNR.
+func snrBarHeight(snr, h int) int {
+	if snr <= 0 {
+		return 0
+	}
+	if snr > dashboardMaxSNR {
+		snr = dashboardMaxSNR
+	}
+	return snr * h / dashboardMaxSNR
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+)
+
+func Test_skyPoint(t *testing.T) {
+	tests := []struct {
+		name   string
+		sat    satellite
+		wantX  int
+		wantY  int
+		wantOk bool
+	}{
+		{
+			name:   "Overhead",
+			sat:    satellite{Elevation: 90, Azimuth: 123},
+			wantX:  100,
+			wantY:  100,
+			wantOk: true,
+		},
+		{
+			name:   "North horizon",
+			sat:    satellite{Elevation: 0, Azimuth: 0},
+			wantX:  100,
+			wantY:  10,
+			wantOk: true,
+		},
+		{
+			name:   "East half way",
+			sat:    satellite{Elevation: 45, Azimuth: 90},
+			wantX:  145,
+			wantY:  100,
+			wantOk: true,
+		},
+		{
+			name:   "South west",
+			sat:    satellite{Elevation: 0, Azimuth: 225},
+			wantX:  36,
+			wantY:  164,
+			wantOk: true,
+		},
+		{
+			name:   "Unknown",
+			sat:    satellite{Elevation: -1, Azimuth: -1},
+			wantOk: false,
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+		t.Run(ttt.name, func(t *testing.T) {
+			x, y, ok := skyPoint(ttt.sat, 100, 100, 90)
+			if ok != ttt.wantOk || (ok && (x != ttt.wantX || y != ttt.wantY)) {
+				t.Errorf("skyPoint() = %d, %d, %v, want %d, %d, %v", x, y, ok, ttt.wantX, ttt.wantY, ttt.wantOk)
+			}
+		})
+	}
+}
+
+func Test_snrBarHeight(t *testing.T) {
+	tests := []struct {
+		snr  int
+		want int
+	}{
+		{snr: -1, want: 0},
+		{snr: 0, want: 0},
+		{snr: 25, want: 50},
+		{snr: 50, want: 100},
+		{snr: 60, want: 100},
+	}
+	for _, tt := range tests {
+		if got := snrBarHeight(tt.snr, 100); got != tt.want {
+			t.Errorf("snrBarHeight(%d) = %d, want %d", tt.snr, got, tt.want)
+		}
+	}
+}
+
+func Test_skySystemOf(t *testing.T) {
+	if got := skySystemOf("GL").Name; got != "GLONASS" {
+		t.Errorf("skySystemOf(GL) = %s, want GLONASS", got)
+	}
+	if got := skySystemOf("GI").Name; got != "Other" {
+		t.Errorf("skySystemOf(GI) = %s, want Other", got)
+	}
+}
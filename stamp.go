@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"strings"
+	"time"
+)
+
+// formatADIFLocation returns v as an ADIF Location, like N038 55.200, hemispheres are pos or neg.
+func formatADIFLocation(v float64, pos, neg byte) string {
+	h := pos
+	if v < 0 {
+		h = neg
+	}
+
+	m := int(math.Round(math.Abs(v) * 60000))
+	return fmt.Sprintf("%c%03d %02d.%03d", h, m/60000, m%60000/1000, m%1000)
+}
+
+// parseADIFTime returns the UTC time from QSO_DATE (YYYYMMDD) and TIME_ON (HHMM or HHMMSS).
+func parseADIFTime(date, tm string) (time.Time, error) {
+	layout := "20060102150405"
+	if len(tm) == 4 {
+		layout = "200601021504"
+	}
+
+	t, err := time.Parse(layout, date+tm)
+	if err != nil {
+		log.Printf("%+v", err)
+		return time.Time{}, err
+	}
+	return t, nil
+}
+
+// stampADIF fills in MY_GRIDSQUARE, MY_LAT and MY_LON of the records in af from the point in pts nearest the start of the QSO
+// existing values are only replaced if force is set, QSOs more than maxGap from a point are left alone unless maxGap is 0
+// returns the number of records changed.
+func stampADIF(af *adifFile, pts []gpxPoint, force bool, maxGap time.Duration) (int, error) {
+	var n int
+
+	for i := range af.records {
+		r := &af.records[i]
+
+		date, _ := r.get("QSO_DATE")
+		tm, _ := r.get("TIME_ON")
+		t, err := parseADIFTime(date, tm)
+		if err != nil {
+			call, _ := r.get("CALL")
+			err = fmt.Errorf("QSO %d with %s has no usable QSO_DATE/TIME_ON", i+1, call)
+			log.Printf("%+v", err)
+			return n, err
+		}
+
+		p := nearestGPXPoint(pts, t)
+		gap := p.Time.Sub(t)
+		if gap < 0 {
+			gap = -gap
+		}
+		if maxGap > 0 && gap > maxGap {
+			continue
+		}
+
+		grid, err := latLonToGridsquare(p.Lat, p.Lon)
+		if err != nil {
+			return n, err
+		}
+
+		var changed bool
+		for _, f := range []adifField{
+			{Name: "MY_GRIDSQUARE", Value: grid},
+			{Name: "MY_LAT", Value: formatADIFLocation(p.Lat, 'N', 'S')},
+			{Name: "MY_LON", Value: formatADIFLocation(p.Lon, 'E', 'W')},
+		} {
+			v, ok := r.get(f.Name)
+			if (ok && strings.TrimSpace(v) != "" && !force) || v == f.Value {
+				continue
+			}
+			r.set(f.Name, f.Value)
+			changed = true
+		}
+		if changed {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// runStamp is the stamp command, it writes our location into the QSOs of an ADIF file from a GPX track.
+func runStamp(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("stamp", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	track := fs.String("track", "", "GPX `file` with the recorded track")
+	out := fs.String("out", "", "write the stamped ADIF to `file` instead of standard output")
+	force := fs.Bool("force", false, "replace existing MY_GRIDSQUARE, MY_LAT and MY_LON values")
+	maxGap := fs.Int("maxgap", 3600, "skip QSOs more than this many `seconds` from a track point, 0 for no limit")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: gps-qth-qtr stamp -track track.gpx [-out stamped.adi] [-force] [-maxgap seconds] log.adi\n")
+		fs.PrintDefaults()
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return exitUsage
+	}
+	if *track == "" || fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	pts, err := readGPXFiles([]string{*track})
+	if err != nil {
+		return exitFailed
+	}
+
+	// #nosec G304
+	b, err := ioutil.ReadFile(fs.Arg(0))
+	if err != nil {
+		log.Printf("%+v", err)
+		return exitFailed
+	}
+
+	af, err := parseADIF(b)
+	if err != nil {
+		return exitFailed
+	}
+
+	n, err := stampADIF(af, pts, *force, time.Duration(*maxGap)*time.Second)
+	if err != nil {
+		return exitFailed
+	}
+
+	var buf bytes.Buffer
+	err = af.write(&buf)
+	if err != nil {
+		return exitFailed
+	}
+
+	if *out == "" {
+		_, err = buf.WriteTo(stdout)
+	} else {
+		err = ioutil.WriteFile(*out, buf.Bytes(), 0666)
+	}
+	if err != nil {
+		log.Printf("%+v", err)
+		return exitFailed
+	}
+
+	log.Printf("stamped %d of %d QSOs", n, len(af.records))
+	return exitOK
+}
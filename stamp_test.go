@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_formatADIFLocation(t *testing.T) {
+	tests := []struct {
+		name string
+		v    float64
+		pos  byte
+		neg  byte
+		want string
+	}{
+		{name: "North", v: 38.92, pos: 'N', neg: 'S', want: "N038 55.200"},
+		{name: "West", v: -77.065, pos: 'E', neg: 'W', want: "W077 03.900"},
+		{name: "South rounding", v: -33.8688197, pos: 'N', neg: 'S', want: "S033 52.129"},
+		{name: "East", v: 151.2092955, pos: 'E', neg: 'W', want: "E151 12.558"},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			if got := formatADIFLocation(ttt.v, ttt.pos, ttt.neg); got != ttt.want {
+				t.Errorf("formatADIFLocation() = %v, want %v", got, ttt.want)
+			}
+		})
+	}
+}
+
+func Test_stampADIF(t *testing.T) {
+	base := time.Date(2024, 6, 1, 14, 0, 0, 0, time.UTC)
+	pts := []gpxPoint{
+		{Lat: 38.92, Lon: -77.065, Time: base},
+		{Lat: 39.5, Lon: -76.5, Time: base.Add(time.Hour)},
+	}
+
+	adif := "<CALL:4>N0CA <QSO_DATE:8>20240601 <TIME_ON:4>1005 <EOR>\n" +
+		"<CALL:4>N0CB <QSO_DATE:8>20240601 <TIME_ON:6>145500 <MY_GRIDSQUARE:4>FM19 <EOR>\n" +
+		"<CALL:4>N0CC <QSO_DATE:8>20240601 <TIME_ON:4>1700 <EOR>\n"
+
+	tests := []struct {
+		name   string
+		force  bool
+		maxGap time.Duration
+		want   int
+		grids  []string
+		lats   []string
+	}{
+		{
+			name:  "Keep existing",
+			want:  3,
+			grids: []string{"FM18lw", "FM19", "FM19sm"},
+			lats:  []string{"N038 55.200", "N039 30.000", "N039 30.000"},
+		},
+		{
+			name:  "Force",
+			force: true,
+			want:  3,
+			grids: []string{"FM18lw", "FM19sm", "FM19sm"},
+			lats:  []string{"N038 55.200", "N039 30.000", "N039 30.000"},
+		},
+		{
+			name:   "Max gap",
+			maxGap: time.Hour,
+			want:   1,
+			grids:  []string{"", "FM19", ""},
+			lats:   []string{"", "N039 30.000", ""},
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			af, err := parseADIF([]byte(adif))
+			if err != nil {
+				t.Fatalf("parseADIF() error = %v", err)
+			}
+
+			n, err := stampADIF(af, pts, ttt.force, ttt.maxGap)
+			if err != nil {
+				t.Fatalf("stampADIF() error = %v", err)
+			}
+			if n != ttt.want {
+				t.Errorf("stampADIF() = %v, want %v", n, ttt.want)
+			}
+
+			for i, r := range af.records {
+				grid, _ := r.get("MY_GRIDSQUARE")
+				lat, _ := r.get("MY_LAT")
+				if grid != ttt.grids[i] || lat != ttt.lats[i] {
+					t.Errorf("stampADIF() record %d = %s %s, want %s %s", i, grid, lat, ttt.grids[i], ttt.lats[i])
+				}
+			}
+		})
+	}
+
+	af, err := parseADIF([]byte("<CALL:4>N0CA <EOR>"))
+	if err != nil {
+		t.Fatalf("parseADIF() error = %v", err)
+	}
+	_, err = stampADIF(af, pts, false, 0)
+	if err == nil {
+		t.Errorf("stampADIF() error = nil, want error for missing QSO_DATE")
+	}
+}
+
+func Test_runStamp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "stamp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	track := filepath.Join(dir, "track.gpx")
+	err = ioutil.WriteFile(track, []byte(`<gpx version="1.1"><trk><trkseg>
+<trkpt lat="38.92" lon="-77.065"><time>2024-06-01T14:00:00Z</time></trkpt>
+</trkseg></trk></gpx>`), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adi := filepath.Join(dir, "log.adi")
+	err = ioutil.WriteFile(adi, []byte("<CALL:4>N0CA <QSO_DATE:8>20240601 <TIME_ON:4>1405 <EOR>\n"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if code := runStamp([]string{"-track", track, adi}, &out, ioutil.Discard); code != 0 {
+		t.Fatalf("runStamp() = %v, want 0", code)
+	}
+	if !strings.Contains(out.String(), "<MY_GRIDSQUARE:6>FM18lw <MY_LAT:11>N038 55.200 <MY_LON:11>W077 03.900 <EOR>") {
+		t.Errorf("runStamp() output = %q", out.String())
+	}
+
+	if code := runStamp([]string{adi}, &out, ioutil.Discard); code != 2 {
+		t.Errorf("runStamp() without track = %v, want 2", code)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// gpsState is the last good gps data, kept in the state file so we have a position as soon as we start.
+type gpsState struct {
+	Saved         time.Time `json:"saved"`
+	Time          time.Time `json:"time"`
+	Gridsquare    string    `json:"gridsquare"`
+	Latitude      float64   `json:"latitude"`
+	Longitude     float64   `json:"longitude"`
+	FixQuality    string    `json:"fixQuality"`
+	NumSatellites int       `json:"numSatellites"`
+	HDOP          float64   `json:"hdop"`
+	Altitude      *float64  `json:"altitude,omitempty"`
+}
+
+var (
+	// where the last good gps data is kept, empty to not keep it.
+	statePath string
+)
+
+// saveState writes v to the state file at fn, replacing it in one step so it is never half written.
+func saveState(fn string, v gpsValues, now time.Time) error {
+	st := gpsState{
+		Saved:         now.UTC(),
+		Time:          v.Time,
+		Gridsquare:    v.Gridsquare,
+		Latitude:      v.Latitude,
+		Longitude:     v.Longitude,
+		FixQuality:    v.FixQuality,
+		NumSatellites: v.NumSatellites,
+		HDOP:          v.HDOP,
+	}
+	if !math.IsNaN(v.Altitude) {
+		st.Altitude = &v.Altitude
+	}
+
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+
+	return writeFileAtomic(fn, b)
+}
+
+// writeFileAtomic replaces the file fn with b in one step so it is never half written, keeping its permissions.
+func writeFileAtomic(fn string, b []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(fn), filepath.Base(fn)+".*")
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if fi, err := os.Stat(fn); err == nil {
+		err = f.Chmod(fi.Mode())
+		if err != nil {
+			log.Printf("%+v", err)
+		}
+	}
+
+	_, err = f.Write(b)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+
+	err = os.Rename(f.Name(), fn)
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+	return nil
+}
+
+// loadState returns the gps data saved in the state file at fn, marked as stale.
+func loadState(fn string) (*gpsData, error) {
+	// #nosec G304
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	var st gpsState
+	err = json.Unmarshal(b, &st)
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	if st.Gridsquare == "" || st.Time.IsZero() {
+		err = fmt.Errorf("state file %s has no position", fn)
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	g := newGPSData()
+	g.setTime(st.Time)
+	g.setGridsquare(st.Gridsquare)
+	g.setLatitude(st.Latitude)
+	g.setLongitude(st.Longitude)
+	g.setFixQuality(st.FixQuality)
+	g.setNumSatellites(st.NumSatellites)
+	g.setHDOP(st.HDOP)
+	if st.Altitude != nil {
+		g.setAltitude(*st.Altitude)
+	}
+	g.setStale(true)
+
+	return g, nil
+}
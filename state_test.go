@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_saveState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fn := filepath.Join(dir, "gps-qth-qtr.state")
+	tm := time.Date(2024, 6, 1, 14, 0, 0, 0, time.UTC)
+
+	for _, alt := range []float64{math.NaN(), 85.5} {
+		g := newGPSData()
+		g.setTime(tm)
+		g.setGridsquare("FM18lw")
+		g.setLatitude(38.92)
+		g.setLongitude(-77.065)
+		g.setFixQuality("GPS fix (SPS)")
+		g.setNumSatellites(9)
+		g.setHDOP(0.9)
+		g.setAltitude(alt)
+
+		err = saveState(fn, g.values(), tm.Add(time.Second))
+		if err != nil {
+			t.Fatalf("saveState() error = %v", err)
+		}
+
+		got, err := loadState(fn)
+		if err != nil {
+			t.Fatalf("loadState() error = %v", err)
+		}
+
+		want := g.values()
+		want.Stale = true
+		v := got.values()
+		if v.Time != want.Time || v.Gridsquare != want.Gridsquare || v.Latitude != want.Latitude || v.Longitude != want.Longitude ||
+			v.FixQuality != want.FixQuality || v.NumSatellites != want.NumSatellites || v.HDOP != want.HDOP || !v.Stale ||
+			(v.Altitude != want.Altitude && !(math.IsNaN(v.Altitude) && math.IsNaN(want.Altitude))) {
+			t.Errorf("loadState() = %+v, want %+v", v, want)
+		}
+
+		if !strings.HasPrefix(got.formatStatus(), "Stale") || !strings.HasSuffix(got.formatTime(), "(stale)") {
+			t.Errorf("loadState() shows %s %s, want it marked stale", got.formatStatus(), got.formatTime())
+		}
+	}
+
+	// only the state file is left behind
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fis) != 1 {
+		t.Errorf("saveState() left %d files, want 1", len(fis))
+	}
+}
+
+func Test_loadState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name  string
+		state string
+	}{
+		{name: "Not json", state: "FM18lw"},
+		{name: "No position", state: `{"time":"2024-06-01T14:00:00Z"}`},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			fn := filepath.Join(dir, "gps-qth-qtr.state")
+			err := ioutil.WriteFile(fn, []byte(ttt.state), 0666)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = loadState(fn)
+			if err == nil {
+				t.Errorf("loadState() error = nil, want error")
+			}
+		})
+	}
+
+	_, err = loadState(filepath.Join(dir, "missing.state"))
+	if err == nil {
+		t.Errorf("loadState() error = nil, want error for missing file")
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// statusRow is a line of the status window.
+type statusRow struct {
+	Name  string
+	Value string
+}
+
+// statusRows returns what the status window shows, g along with the poll history and the integrations.
+func statusRows(g *gpsData, now time.Time) []statusRow {
+	rows := []statusRow{
+		{Name: "Message", Value: g.formatStatus()},
+		{Name: "Gridsquare", Value: g.formatGridsquare()},
+		{Name: "Latitude", Value: g.formatLatitude()},
+		{Name: "Longitude", Value: g.formatLongitude()},
+		{Name: "Altitude", Value: g.formatAltitude()},
+		{Name: "Speed/Course", Value: g.formatSpeedCourse()},
+		{Name: "Last Update", Value: g.formatTime()},
+		{Name: "Fix Age", Value: g.formatFixAge(now)},
+		{Name: "Last Attempt", Value: g.formatAttempted()},
+		{Name: "Satellites", Value: g.formatNumSatellites()},
+		{Name: "Fix Quality", Value: g.formatFixQuality()},
+		{Name: "HDOP", Value: g.formatHDOP()},
+		{Name: "PDOP/VDOP", Value: g.formatPDOPVDOP()},
+		{Name: "Time Set", Value: g.formatSynced()},
+		{Name: "Clock Offset", Value: g.formatSyncOffset()},
+		{Name: "Data Source", Value: formatDataSource(g)},
+		{Name: "Last Good Fix", Value: history.formatLastGood()},
+		{Name: "Recent Polls", Value: history.formatRecent()},
+	}
+
+	if ji := currentJS8Call(); ji != nil {
+		rows = append(rows, statusRow{Name: "JS8Call", Value: ji.formatStatus()})
+	}
+
+	return rows
+}
+
+// formatDataSource returns where the values in g came from to show user.
+func formatDataSource(g *gpsData) string {
+	if g.isStale() {
+		return "Last known position from " + statePath
+	}
+	if g.getAttempted() == (time.Time{}) {
+		return ""
+	}
+	cfg := currentConfig()
+	return fmt.Sprintf("%s at %d baud", cfg.GPSDevice.Port, cfg.GPSDevice.Baud)
+}
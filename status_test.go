@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_statusRows(t *testing.T) {
+	now := time.Date(2020, time.Month(1), 18, 2, 3, 42, 0, time.UTC)
+
+	prevConfig := config
+	prevStatePath := statePath
+	defer func() {
+		config = prevConfig
+		statePath = prevStatePath
+	}()
+	config.GPSDevice.Port = "COM3"
+	config.GPSDevice.Baud = 4800
+	statePath = "gps-qth-qtr.state"
+
+	polled := newGPSData()
+	polled.setAttempted(now)
+	polled.setAltitude(250.64)
+	polled.setSpeed(0.149)
+	polled.setCourse(92.25)
+	polled.setPDOP(2.5)
+	polled.setVDOP(2.1)
+	polled.setSynced(now, -1234567*time.Microsecond)
+
+	stale := newGPSData()
+	stale.setStale(true)
+	stale.setSpeed(3)
+	stale.setSynced(now, 0)
+
+	tests := []struct {
+		name string
+		g    *gpsData
+		want map[string]string
+	}{
+		{
+			name: "Not polled",
+			g:    newGPSData(),
+			want: map[string]string{
+				"Altitude":     "",
+				"Speed/Course": "",
+				"PDOP/VDOP":    "",
+				"Time Set":     "",
+				"Clock Offset": "",
+				"Data Source":  "",
+			},
+		},
+		{
+			name: "Polled",
+			g:    polled,
+			want: map[string]string{
+				"Altitude":     "250.6 m",
+				"Speed/Course": "0.1 kn, 92.2°",
+				"PDOP/VDOP":    "2.5 / 2.1",
+				"Time Set":     "18-Jan-2020 02:03:42 UTC",
+				"Clock Offset": "1.235s ahead",
+				"Data Source":  "COM3 at 4800 baud",
+			},
+		},
+		{
+			name: "Stale",
+			g:    stale,
+			want: map[string]string{
+				"Speed/Course": "3.0 kn",
+				"Clock Offset": "0s",
+				"Data Source":  "Last known position from gps-qth-qtr.state",
+			},
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+		t.Run(ttt.name, func(t *testing.T) {
+			got := make(map[string]string)
+			for _, r := range statusRows(ttt.g, now) {
+				got[r.Name] = r.Value
+			}
+
+			for name, want := range ttt.want {
+				if v, ok := got[name]; !ok || v != want {
+					t.Errorf("statusRows() %s = %q, want %q", name, v, want)
+				}
+			}
+		})
+	}
+}
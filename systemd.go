@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sdNotify sends state to systemd when we were started as a notify service, does nothing otherwise.
+func sdNotify(state string) error {
+	name := os.Getenv("NOTIFY_SOCKET")
+	if name == "" {
+		return nil
+	}
+
+	// abstract socket
+	if name[0] == '@' {
+		name = "\x00" + name[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(state))
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+	return nil
+}
+
+// sdWatchdogInterval returns how often to tell systemd we are alive, 0 if it isn't watching us.
+func sdWatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+
+	// the watchdog can be meant for another process
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+
+	// twice as often as required so a late ping doesn't get us killed
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// formatDaemonStatus returns a one line summary of v for the service status.
+func formatDaemonStatus(v gpsValues) string {
+	switch {
+	case v.Status != "" && v.Gridsquare != "":
+		return fmt.Sprintf("%s, last grid %s", v.Status, v.Gridsquare)
+	case v.Status != "":
+		return v.Status
+	case v.Gridsquare == "":
+		return "Waiting for the GPS device"
+	case v.Stale:
+		return fmt.Sprintf("Grid %s (stale), waiting for a fix", v.Gridsquare)
+	}
+
+	s := fmt.Sprintf("Grid %s", v.Gridsquare)
+	if v.FixQuality != "" {
+		s += ", " + v.FixQuality
+	}
+	if v.NumSatellites >= 0 {
+		s += fmt.Sprintf(", %d satellites", v.NumSatellites)
+	}
+	return s
+}
+
+// quoteUnitArg quotes s for a systemd unit file if it needs it.
+func quoteUnitArg(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+// systemdUnit returns a systemd service unit that runs exe with the configuration file configPath
+// as user if it isn't empty.
+func systemdUnit(exe, configPath, user string) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "[Unit]\n")
+	fmt.Fprintf(&b, "Description=gps-qth-qtr GPS time and gridsquare\n")
+	fmt.Fprintf(&b, "After=network-online.target\n")
+	fmt.Fprintf(&b, "Wants=network-online.target\n")
+	fmt.Fprintf(&b, "\n")
+	fmt.Fprintf(&b, "[Service]\n")
+	fmt.Fprintf(&b, "Type=notify\n")
+	fmt.Fprintf(&b, "ExecStart=%s -config %s -log - -state /var/lib/gps-qth-qtr/gps-qth-qtr.state run\n", quoteUnitArg(exe), quoteUnitArg(configPath))
+	fmt.Fprintf(&b, "ExecReload=/bin/kill -HUP $MAINPID\n")
+	fmt.Fprintf(&b, "StateDirectory=gps-qth-qtr\n")
+	fmt.Fprintf(&b, "WatchdogSec=60\n")
+	fmt.Fprintf(&b, "Restart=on-failure\n")
+	if user != "" {
+		fmt.Fprintf(&b, "User=%s\n", user)
+		// serial ports belong to the dialout group
+		fmt.Fprintf(&b, "SupplementaryGroups=dialout\n")
+		// and only root can set the system time without this
+		fmt.Fprintf(&b, "AmbientCapabilities=CAP_SYS_TIME\n")
+	}
+	fmt.Fprintf(&b, "\n")
+	fmt.Fprintf(&b, "[Install]\n")
+	fmt.Fprintf(&b, "WantedBy=multi-user.target\n")
+
+	return b.String()
+}
+
+// runUnit is the unit command, it prints a systemd service unit for running gps-qth-qtr headless.
+func runUnit(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("unit", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	user := fs.String("user", "", "run the service as `user` instead of root")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: gps-qth-qtr [-config file] unit [-user user] > /etc/systemd/system/gps-qth-qtr.service\n\n")
+		fs.PrintDefaults()
+	}
+
+	err := fs.Parse(args)
+	if err != nil || fs.NArg() != 0 {
+		return exitUsage
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		log.Printf("%+v", err)
+		return exitFailed
+	}
+
+	// the service doesn't start where we are
+	configPath, err := filepath.Abs(options.configPath)
+	if err != nil {
+		log.Printf("%+v", err)
+		return exitFailed
+	}
+
+	fmt.Fprint(stdout, systemdUnit(exe, configPath, *user))
+	return exitOK
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// saveEnv returns a func that puts the environment variables keys back the way they are now.
+func saveEnv(keys ...string) func() {
+	type saved struct {
+		value string
+		ok    bool
+	}
+
+	prev := make(map[string]saved, len(keys))
+	for _, k := range keys {
+		v, ok := os.LookupEnv(k)
+		prev[k] = saved{value: v, ok: ok}
+	}
+
+	return func() {
+		for k, s := range prev {
+			if s.ok {
+				os.Setenv(k, s.value)
+			} else {
+				os.Unsetenv(k)
+			}
+		}
+	}
+}
+
+// listenNotify listens for sd_notify messages on a socket in dir and points NOTIFY_SOCKET at it.
+func listenNotify(t *testing.T, dir string) *net.UnixConn {
+	name := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("NOTIFY_SOCKET", name)
+
+	return conn
+}
+
+// readNotify returns the next sd_notify message sent to conn.
+func readNotify(t *testing.T, conn *net.UnixConn) string {
+	b := make([]byte, 4096)
+
+	err := conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := conn.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b[:n])
+}
+
+func Test_sdNotify(t *testing.T) {
+	defer saveEnv("NOTIFY_SOCKET")()
+
+	// not started by systemd
+	os.Unsetenv("NOTIFY_SOCKET")
+	err := sdNotify("READY=1")
+	if err != nil {
+		t.Errorf("sdNotify() without NOTIFY_SOCKET error = %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "systemd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	conn := listenNotify(t, dir)
+	defer conn.Close()
+
+	err = sdNotify("READY=1\nSTATUS=Grid FM18lw")
+	if err != nil {
+		t.Fatalf("sdNotify() error = %v", err)
+	}
+	if got := readNotify(t, conn); got != "READY=1\nSTATUS=Grid FM18lw" {
+		t.Errorf("sdNotify() sent %q", got)
+	}
+
+	os.Setenv("NOTIFY_SOCKET", filepath.Join(dir, "missing"))
+	err = sdNotify("READY=1")
+	if err == nil {
+		t.Errorf("sdNotify() to missing socket error = nil")
+	}
+}
+
+func Test_sdWatchdogInterval(t *testing.T) {
+	defer saveEnv("WATCHDOG_USEC", "WATCHDOG_PID")()
+
+	tests := []struct {
+		name string
+		usec string
+		pid  string
+		want time.Duration
+	}{
+		{name: "Not watched", want: 0},
+		{name: "Watched", usec: "60000000", want: 30 * time.Second},
+		{name: "Our pid", usec: "60000000", pid: strconv.Itoa(os.Getpid()), want: 30 * time.Second},
+		{name: "Other pid", usec: "60000000", pid: "1", want: 0},
+		{name: "Bad", usec: "soon", want: 0},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			os.Setenv("WATCHDOG_USEC", ttt.usec)
+			os.Setenv("WATCHDOG_PID", ttt.pid)
+
+			if got := sdWatchdogInterval(); got != ttt.want {
+				t.Errorf("sdWatchdogInterval() = %v, want %v", got, ttt.want)
+			}
+		})
+	}
+}
+
+func Test_formatDaemonStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		v    gpsValues
+		want string
+	}{
+		{
+			name: "Waiting",
+			v:    gpsValues{NumSatellites: -1},
+			want: "Waiting for the GPS device",
+		},
+		{
+			name: "Fix",
+			v:    gpsValues{Gridsquare: "FM18lw", FixQuality: "GPS fix (SPS)", NumSatellites: 9},
+			want: "Grid FM18lw, GPS fix (SPS), 9 satellites",
+		},
+		{
+			name: "Stale",
+			v:    gpsValues{Gridsquare: "FM18lw", NumSatellites: -1, Stale: true},
+			want: "Grid FM18lw (stale), waiting for a fix",
+		},
+		{
+			name: "Error",
+			v:    gpsValues{Status: "RMC line bad checksum", Gridsquare: "FM18lw"},
+			want: "RMC line bad checksum, last grid FM18lw",
+		},
+		{
+			name: "Error without grid",
+			v:    gpsValues{Status: "RMC line bad checksum"},
+			want: "RMC line bad checksum",
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			if got := formatDaemonStatus(ttt.v); got != ttt.want {
+				t.Errorf("formatDaemonStatus() = %q, want %q", got, ttt.want)
+			}
+		})
+	}
+}
+
+func Test_systemdUnit(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    string
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "Root",
+			want: []string{
+				"Type=notify\n",
+				"ExecStart=/usr/local/bin/gps-qth-qtr -config \"/etc/gps qth qtr.yaml\" -log - -state /var/lib/gps-qth-qtr/gps-qth-qtr.state run\n",
+				"ExecReload=/bin/kill -HUP $MAINPID\n",
+				"WatchdogSec=60\n",
+				"WantedBy=multi-user.target\n",
+			},
+			notWant: []string{"User=", "AmbientCapabilities="},
+		},
+		{
+			name: "User",
+			user: "ham",
+			want: []string{"User=ham\n", "SupplementaryGroups=dialout\n", "AmbientCapabilities=CAP_SYS_TIME\n"},
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			got := systemdUnit("/usr/local/bin/gps-qth-qtr", "/etc/gps qth qtr.yaml", ttt.user)
+			for _, w := range ttt.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("systemdUnit() = %q, want it to contain %q", got, w)
+				}
+			}
+			for _, w := range ttt.notWant {
+				if strings.Contains(got, w) {
+					t.Errorf("systemdUnit() = %q, don't want it to contain %q", got, w)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package main
+
+import (
+	"log"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// makeRaw puts the terminal in so keys are read as they are pressed and not echoed, returns a func to put it back.
+func makeRaw(in, out *os.File) (func(), error) {
+	fd := int(in.Fd())
+
+	prev, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	// same as cfmakeraw, but keep output processing so \n still starts a new line
+	raw := *prev
+	raw.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	raw.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	raw.Cflag &^= unix.CSIZE | unix.PARENB
+	raw.Cflag |= unix.CS8
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+
+	err = unix.IoctlSetTermios(fd, ioctlSetTermios, &raw)
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	return func() {
+		_ = unix.IoctlSetTermios(fd, ioctlSetTermios, prev)
+	}, nil
+}
+
+// terminalSize returns the columns and rows of the terminal f is.
+func terminalSize(f *os.File) (int, int, error) {
+	ws, err := unix.IoctlGetWinsize(int(f.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(ws.Col), int(ws.Row), nil
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// trackConfig is the configuration of the GPX track logger.
+type trackConfig struct {
+	Directory   string
+	Split       string
+	MinDistance float64
+	MinInterval time.Duration
+}
+
+const (
+	// mean radius of the earth in meters
+	earthRadius = 6371000
+)
+
+// distance returns the great circle distance in meters between two points in decimal degrees.
+func distance(lat1, lon1, lat2, lon2 float64) float64 {
+	p1 := lat1 * math.Pi / 180
+	p2 := lat2 * math.Pi / 180
+	dp := (lat2 - lat1) * math.Pi / 180
+	dl := (lon2 - lon1) * math.Pi / 180
+
+	a := math.Sin(dp/2)*math.Sin(dp/2) + math.Cos(p1)*math.Cos(p2)*math.Sin(dl/2)*math.Sin(dl/2)
+	return 2 * earthRadius * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// trackLogger appends fixes to GPX files, the file is complete after every point so nothing is lost if we're killed.
+type trackLogger struct {
+	cfg     trackConfig
+	f       *os.File
+	name    string
+	session time.Time
+	last    gpxPoint
+	quit    chan struct{}
+	done    chan struct{}
+}
+
+// newTrackLogger validates cfg and returns a logger ready to start.
+func newTrackLogger(cfg trackConfig) (*trackLogger, error) {
+	switch cfg.Split {
+	case "":
+		cfg.Split = "day"
+	case "day", "session":
+	default:
+		err := fmt.Errorf("track split must be day or session")
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	if cfg.MinDistance < 0 {
+		cfg.MinDistance = 0
+	}
+	if cfg.MinInterval < 0 {
+		cfg.MinInterval = 0
+	}
+	cfg.MinInterval *= time.Second
+
+	err := os.MkdirAll(cfg.Directory, 0777)
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	return &trackLogger{
+		cfg:  cfg,
+		quit: make(chan struct{}),
+		done: make(chan struct{}),
+	}, nil
+}
+
+// fileName returns the name of the file the point at t goes in.
+func (tl *trackLogger) fileName(t time.Time) string {
+	if tl.cfg.Split == "session" {
+		if tl.session.IsZero() {
+			tl.session = t.UTC()
+		}
+		return filepath.Join(tl.cfg.Directory, "track-"+tl.session.Format("2006-01-02T150405Z")+".gpx")
+	}
+	return filepath.Join(tl.cfg.Directory, "track-"+t.UTC().Format("2006-01-02")+".gpx")
+}
+
+// open makes fn the current file, an existing file we wrote gets a new track segment.
+func (tl *trackLogger) open(fn string) error {
+	tl.close()
+
+	// #nosec G304
+	f, err := os.OpenFile(fn, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		log.Printf("%+v", err)
+		return err
+	}
+
+	var b []byte
+	if fi.Size() == 0 {
+		b = []byte(fmt.Sprintf(gpxHead, filepath.Base(fn)) + gpxTail)
+	} else {
+		// make sure it ends the way we left it before writing over the end
+		end := make([]byte, len(gpxTail))
+		_, err = f.ReadAt(end, fi.Size()-int64(len(end)))
+		if err != nil || string(end) != gpxTail {
+			f.Close()
+			err = fmt.Errorf("%s wasn't written by the track logger", fn)
+			log.Printf("%+v", err)
+			return err
+		}
+
+		_, err = f.Seek(-int64(len(gpxTail)), io.SeekEnd)
+		if err != nil {
+			f.Close()
+			log.Printf("%+v", err)
+			return err
+		}
+		b = []byte("</trkseg>\n<trkseg>\n" + gpxTail)
+	}
+
+	_, err = f.Write(b)
+	if err != nil {
+		f.Close()
+		log.Printf("%+v", err)
+		return err
+	}
+
+	tl.f = f
+	tl.name = fn
+	tl.last = gpxPoint{}
+	return nil
+}
+
+// close closes the current file.
+func (tl *trackLogger) close() {
+	if tl.f == nil {
+		return
+	}
+
+	err := tl.f.Close()
+	if err != nil {
+		log.Printf("%+v", err)
+	}
+	tl.f = nil
+	tl.name = ""
+}
+
+// due returns true if p is far enough in time and distance from the last point written.
+func (tl *trackLogger) due(p gpxPoint) bool {
+	if tl.last.Time.IsZero() {
+		return true
+	}
+	if p.Time.Sub(tl.last.Time) < tl.cfg.MinInterval {
+		return false
+	}
+	return distance(tl.last.Lat, tl.last.Lon, p.Lat, p.Lon) >= tl.cfg.MinDistance
+}
+
+// write adds p to the end of the current file in a single write, the tail is rewritten after it.
+func (tl *trackLogger) write(p gpxPoint) error {
+	b, err := encodeGPXPoint(p)
+	if err != nil {
+		return err
+	}
+
+	_, err = tl.f.Seek(-int64(len(gpxTail)), io.SeekEnd)
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+
+	_, err = tl.f.Write(append(b, gpxTail...))
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+
+	tl.last = p
+	return nil
+}
+
+// update logs v if it is a valid fix and far enough from the last point.
+func (tl *trackLogger) update(v gpsValues) {
+	if v.Status != "" || !v.hasFix() || v.Gridsquare == "" || v.Time.IsZero() {
+		return
+	}
+
+	p := gpxPoint{
+		Lat:  v.Latitude,
+		Lon:  v.Longitude,
+		Time: v.Time,
+	}
+	if !math.IsNaN(v.Altitude) {
+		ele := v.Altitude
+		p.Ele = &ele
+	}
+	if v.NumSatellites > 0 {
+		sat := v.NumSatellites
+		p.Sat = &sat
+	}
+	if v.HDOP > 0 {
+		hdop := v.HDOP
+		p.HDOP = &hdop
+	}
+
+	fn := tl.fileName(p.Time)
+	if fn != tl.name {
+		if tl.open(fn) != nil {
+			return
+		}
+	}
+
+	if !tl.due(p) {
+		return
+	}
+
+	_ = tl.write(p)
+}
+
+// start logs fixes after polls in the background until stop is called.
+func (tl *trackLogger) start() {
+	updates, unsubscribe := subscribe()
+
+	go func() {
+		defer close(tl.done)
+		defer unsubscribe()
+		defer tl.close()
+
+		for {
+			select {
+			case u := <-updates:
+				tl.update(u.values)
+			case <-tl.quit:
+				return
+			}
+		}
+	}()
+}
+
+// stop waits for the background work to finish.
+func (tl *trackLogger) stop() {
+	close(tl.quit)
+	<-tl.done
+}
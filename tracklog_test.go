@@ -0,0 +1,177 @@
+package main
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_distance(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lon1, lat2, lon2 float64
+		want                   float64
+	}{
+		{name: "Same", lat1: 38.92, lon1: -77.065, lat2: 38.92, lon2: -77.065, want: 0},
+		{name: "One degree of latitude", lat1: 0, lon1: 0, lat2: 1, lon2: 0, want: 111195},
+		{name: "DC to London", lat1: 38.9072, lon1: -77.0369, lat2: 51.5074, lon2: -0.1278, want: 5897619},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			if got := distance(ttt.lat1, ttt.lon1, ttt.lat2, ttt.lon2); math.Abs(got-ttt.want) > 1 {
+				t.Errorf("distance() = %v, want %v", got, ttt.want)
+			}
+		})
+	}
+}
+
+// readTrackFile returns the points in the GPX file fn, failing the test if it isn't well-formed.
+func readTrackFile(t *testing.T, fn string) []gpxPoint {
+	t.Helper()
+
+	f, err := os.Open(fn)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer f.Close()
+
+	pts, err := readGPXTrack(f)
+	if err != nil {
+		t.Fatalf("readGPXTrack() error = %v", err)
+	}
+	return pts
+}
+
+func Test_trackLogger_update(t *testing.T) {
+	dir, err := ioutil.TempDir("", "track")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tl, err := newTrackLogger(trackConfig{Directory: dir, MinDistance: 100, MinInterval: 60})
+	if err != nil {
+		t.Fatalf("newTrackLogger() error = %v", err)
+	}
+
+	base := time.Date(2024, 6, 1, 23, 50, 0, 0, time.UTC)
+	v := gpsValues{
+		Time:          base,
+		Gridsquare:    "FM18lw",
+		Latitude:      38.92,
+		Longitude:     -77.065,
+		FixQuality:    "GPS fix",
+		NumSatellites: 9,
+		HDOP:          0.9,
+		Altitude:      math.NaN(),
+	}
+	fn := filepath.Join(dir, "track-2024-06-01.gpx")
+
+	tl.update(v)
+	pts := readTrackFile(t, fn)
+	if len(pts) != 1 || pts[0].Ele != nil || pts[0].Sat == nil || *pts[0].Sat != 9 {
+		t.Fatalf("update() points = %+v", pts)
+	}
+
+	// too soon, then not far enough, then far enough
+	v.Time = base.Add(30 * time.Second)
+	v.Latitude = 39
+	tl.update(v)
+	v.Time = base.Add(2 * time.Minute)
+	v.Latitude = 38.9205
+	tl.update(v)
+	v.Time = base.Add(3 * time.Minute)
+	v.Latitude = 38.93
+	v.Altitude = 85.5
+	tl.update(v)
+
+	pts = readTrackFile(t, fn)
+	if len(pts) != 2 || pts[1].Lat != 38.93 || pts[1].Ele == nil || *pts[1].Ele != 85.5 {
+		t.Fatalf("update() points = %+v", pts)
+	}
+
+	// no fix
+	v.Time = base.Add(5 * time.Minute)
+	v.Latitude = 38.95
+	v.FixQuality = "invalid"
+	tl.update(v)
+	if pts = readTrackFile(t, fn); len(pts) != 2 {
+		t.Errorf("update() without fix logged %d points, want 2", len(pts))
+	}
+
+	// next day is a new file
+	v.Time = base.Add(15 * time.Minute)
+	v.FixQuality = "GPS fix"
+	tl.update(v)
+	if pts = readTrackFile(t, filepath.Join(dir, "track-2024-06-02.gpx")); len(pts) != 1 {
+		t.Errorf("update() next day logged %d points, want 1", len(pts))
+	}
+	tl.close()
+
+	// restarting adds a segment to the existing file
+	tl, err = newTrackLogger(trackConfig{Directory: dir})
+	if err != nil {
+		t.Fatalf("newTrackLogger() error = %v", err)
+	}
+	v.Time = base.Add(5 * time.Minute)
+	tl.update(v)
+	tl.close()
+
+	pts = readTrackFile(t, fn)
+	if len(pts) != 3 {
+		t.Errorf("update() after restart = %d points, want 3", len(pts))
+	}
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(b), "<trkseg>"); n != 2 {
+		t.Errorf("update() after restart = %d segments, want 2", n)
+	}
+}
+
+func Test_trackLogger_session(t *testing.T) {
+	dir, err := ioutil.TempDir("", "track")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tl, err := newTrackLogger(trackConfig{Directory: dir, Split: "session"})
+	if err != nil {
+		t.Fatalf("newTrackLogger() error = %v", err)
+	}
+	defer tl.close()
+
+	base := time.Date(2024, 6, 1, 23, 50, 0, 0, time.UTC)
+	v := gpsValues{Time: base, Gridsquare: "FM18lw", Latitude: 38.92, Longitude: -77.065, FixQuality: "GPS fix", Altitude: math.NaN()}
+	tl.update(v)
+	v.Time = base.Add(time.Hour)
+	tl.update(v)
+
+	if pts := readTrackFile(t, filepath.Join(dir, "track-2024-06-01T235000Z.gpx")); len(pts) != 2 {
+		t.Errorf("update() = %d points, want 2", len(pts))
+	}
+
+	// files we didn't write are left alone
+	fn := filepath.Join(dir, "track-2024-06-02.gpx")
+	err = ioutil.WriteFile(fn, []byte("<gpx></gpx>"), 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tl.open(fn)
+	if err == nil {
+		t.Errorf("open() error = nil, want error for a file we didn't write")
+	}
+
+	_, err = newTrackLogger(trackConfig{Directory: dir, Split: "week"})
+	if err == nil {
+		t.Errorf("newTrackLogger() error = nil, want error for split")
+	}
+}
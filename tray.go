@@ -0,0 +1,544 @@
+// +build !windows
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+// the freedesktop StatusNotifierItem and DBusMenu protocols the desktop's tray speaks.
+const (
+	sniInterface      = "org.kde.StatusNotifierItem"
+	sniPath           = dbus.ObjectPath("/StatusNotifierItem")
+	sniWatcherName    = "org.kde.StatusNotifierWatcher"
+	sniWatcherPath    = dbus.ObjectPath("/StatusNotifierWatcher")
+	menuInterface     = "com.canonical.dbusmenu"
+	menuPath          = dbus.ObjectPath("/MenuBar")
+	notifyName        = "org.freedesktop.Notifications"
+	notifyPath        = dbus.ObjectPath("/org/freedesktop/Notifications")
+	trayIconName      = "mark-location"
+	statusNotifyTitle = "gps-qth-qtr Status"
+)
+
+// sniPixmap is an icon image in a StatusNotifierItem.
+type sniPixmap struct {
+	Width  int32
+	Height int32
+	Data   []byte
+}
+
+// sniToolTip is the tool tip of a StatusNotifierItem.
+type sniToolTip struct {
+	IconName string
+	Pixmaps  []sniPixmap
+	Title    string
+	Text     string
+}
+
+// menuLayout is a DBusMenu item and its children.
+type menuLayout struct {
+	ID         int32
+	Properties map[string]dbus.Variant
+	Children   []dbus.Variant
+}
+
+// menuItemProperties are the properties of a DBusMenu item.
+type menuItemProperties struct {
+	ID         int32
+	Properties map[string]dbus.Variant
+}
+
+// menuEvent is a DBusMenu event.
+type menuEvent struct {
+	ID        int32
+	EventID   string
+	Data      dbus.Variant
+	Timestamp uint32
+}
+
+// trayItem is an entry in the tray menu.
+type trayItem struct {
+	id     int32
+	label  string
+	action func()
+}
+
+// tray is our StatusNotifierItem and its DBusMenu.
+type tray struct {
+	conn  *dbus.Conn
+	name  string
+	props *prop.Properties
+	items []trayItem
+
+	// what the icon shows now
+	state trayState
+
+	// closed when the user picks Exit
+	exit     chan struct{}
+	exitOnce sync.Once
+
+	// notification showing the status, so it gets replaced instead of piling up
+	notifyID uint32
+	notifyMu sync.Mutex
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+// startTray puts us in the desktop's system tray, fails if there is no session bus.
+func startTray() (*tray, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	t, err := newTray(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// newTray exports the tray on conn and registers it with the StatusNotifierWatcher
+// the tray is registered again whenever the watcher restarts.
+func newTray(conn *dbus.Conn) (*tray, error) {
+	t := &tray{
+		conn: conn,
+		name: fmt.Sprintf("org.kde.StatusNotifierItem-%d-1", os.Getpid()),
+		exit: make(chan struct{}),
+		quit: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	t.items = []trayItem{
+		{id: 1, label: "Copy Gridsquare", action: t.copyGridsquare},
+		{id: 2, label: "Status...", action: t.showStatus},
+		{id: 3, label: "Update now", action: t.update},
+		{id: 4, label: "Exit", action: t.requestExit},
+	}
+
+	err := t.export()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = conn.RequestName(t.name, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	// know when the watcher comes and goes
+	err = conn.AddMatchSignal(
+		dbus.WithMatchInterface("org.freedesktop.DBus"),
+		dbus.WithMatchMember("NameOwnerChanged"),
+		dbus.WithMatchArg(0, sniWatcherName),
+	)
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+	signals := make(chan *dbus.Signal, 8)
+	conn.Signal(signals)
+
+	// the desktop might not have a tray yet
+	_ = t.register()
+
+	// keep the icon and tool tip up to date after every poll, and every minute for the time since the time was set
+	updates, unsubscribe := subscribe()
+	go func() {
+		defer close(t.done)
+		defer unsubscribe()
+		defer conn.RemoveSignal(signals)
+
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case s := <-signals:
+				if s.Name == "org.freedesktop.DBus.NameOwnerChanged" && len(s.Body) == 3 && s.Body[2] != "" {
+					_ = t.register()
+				}
+			case u := <-updates:
+				t.setState(u.values)
+			case <-ticker.C:
+				t.setState(gpsdata.values())
+			case <-t.quit:
+				return
+			}
+		}
+	}()
+
+	return t, nil
+}
+
+// export makes the StatusNotifierItem and the DBusMenu available on the bus.
+func (t *tray) export() error {
+	err := t.conn.Export(sniItem{t: t}, sniPath, sniInterface)
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+
+	err = t.conn.Export(dbusMenu{t: t}, menuPath, menuInterface)
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+
+	// the host reads everything else from properties
+	// no IconName so the host shows the pixmap of the state instead of the theme icon
+	v := gpsdata.values()
+	t.state = trayStateOf(v)
+	t.props, err = prop.Export(t.conn, sniPath, prop.Map{
+		sniInterface: {
+			"Category":   {Value: "ApplicationStatus", Emit: prop.EmitFalse},
+			"Id":         {Value: "gps-qth-qtr", Emit: prop.EmitFalse},
+			"Title":      {Value: "gps-qth-qtr", Emit: prop.EmitFalse},
+			"Status":     {Value: "Active", Emit: prop.EmitFalse},
+			"WindowId":   {Value: int32(0), Emit: prop.EmitFalse},
+			"IconName":   {Value: "", Emit: prop.EmitFalse},
+			"IconPixmap": {Value: newSNIPixmaps(t.state), Emit: prop.EmitFalse},
+			"ToolTip":    {Value: newSNIToolTip(v, time.Now()), Emit: prop.EmitFalse},
+			"ItemIsMenu": {Value: false, Emit: prop.EmitFalse},
+			"Menu":       {Value: menuPath, Emit: prop.EmitFalse},
+		},
+	})
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+
+	menuProps, err := prop.Export(t.conn, menuPath, prop.Map{
+		menuInterface: {
+			"Version":       {Value: uint32(3), Emit: prop.EmitFalse},
+			"TextDirection": {Value: "ltr", Emit: prop.EmitFalse},
+			"Status":        {Value: "normal", Emit: prop.EmitFalse},
+			"IconThemePath": {Value: []string{}, Emit: prop.EmitFalse},
+		},
+	})
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+
+	for _, o := range []struct {
+		path  dbus.ObjectPath
+		iface string
+		v     interface{}
+		props *prop.Properties
+	}{
+		{path: sniPath, iface: sniInterface, v: sniItem{}, props: t.props},
+		{path: menuPath, iface: menuInterface, v: dbusMenu{}, props: menuProps},
+	} {
+		n := &introspect.Node{
+			Name: string(o.path),
+			Interfaces: []introspect.Interface{
+				introspect.IntrospectData,
+				prop.IntrospectData,
+				{
+					Name:       o.iface,
+					Methods:    introspect.Methods(o.v),
+					Properties: o.props.Introspection(o.iface),
+				},
+			},
+		}
+		err = t.conn.Export(introspect.NewIntrospectable(n), o.path, "org.freedesktop.DBus.Introspectable")
+		if err != nil {
+			log.Printf("%+v", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// register tells the StatusNotifierWatcher about us so the tray shows our icon.
+func (t *tray) register() error {
+	call := t.conn.Object(sniWatcherName, sniWatcherPath).Call(sniWatcherName+".RegisterStatusNotifierItem", 0, t.name)
+	if call.Err != nil {
+		log.Printf("%+v", call.Err)
+		return call.Err
+	}
+	return nil
+}
+
+// trayPixmapSizes are the sizes of the icon given to the host, it picks the one closest to the size of the tray.
+var trayPixmapSizes = []int{16, 22, 32, 48}
+
+// newSNIPixmaps returns the icon of s as StatusNotifierItem pixmaps
+// they are ARGB32 in network byte order, not premultiplied.
+func newSNIPixmaps(s trayState) []sniPixmap {
+	pixmaps := make([]sniPixmap, 0, len(trayPixmapSizes))
+
+	for _, size := range trayPixmapSizes {
+		img := trayIconImage(s, size)
+
+		data := make([]byte, 0, size*size*4)
+		for i := 0; i < len(img.Pix); i += 4 {
+			r, g, b, a := img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3]
+			if a > 0 && a < 0xff {
+				r = uint8(int(r) * 0xff / int(a))
+				g = uint8(int(g) * 0xff / int(a))
+				b = uint8(int(b) * 0xff / int(a))
+			}
+			data = append(data, a, r, g, b)
+		}
+
+		pixmaps = append(pixmaps, sniPixmap{Width: int32(size), Height: int32(size), Data: data})
+	}
+
+	return pixmaps
+}
+
+// newSNIToolTip returns the tool tip showing v.
+func newSNIToolTip(v gpsValues, now time.Time) sniToolTip {
+	return sniToolTip{
+		IconName: trayIconName,
+		Pixmaps:  []sniPixmap{},
+		Title:    "gps-qth-qtr",
+		Text:     formatTrayToolTipText(v, now),
+	}
+}
+
+// setState shows v in the icon and tool tip, the icon only changes when the state does.
+func (t *tray) setState(v gpsValues) {
+	s := trayStateOf(v)
+	if s != t.state {
+		t.state = s
+		t.props.SetMust(sniInterface, "IconPixmap", newSNIPixmaps(s))
+
+		err := t.conn.Emit(sniPath, sniInterface+".NewIcon")
+		if err != nil {
+			log.Printf("%+v", err)
+		}
+	}
+
+	t.props.SetMust(sniInterface, "ToolTip", newSNIToolTip(v, time.Now()))
+
+	err := t.conn.Emit(sniPath, sniInterface+".NewToolTip")
+	if err != nil {
+		log.Printf("%+v", err)
+	}
+}
+
+// copyGridsquare puts the gridsquare on the clipboard, in the background so D-Bus isn't held up by the clipboard command.
+func (t *tray) copyGridsquare() {
+	go func() {
+		_ = copyToClipboard(gpsdata.formatGridsquare())
+	}()
+}
+
+// formatTrayStatus returns the status data the same way the status window shows it.
+func formatTrayStatus(now time.Time) string {
+	var b bytes.Buffer
+	printStatus(&b, gpsdata, now)
+
+	return b.String()
+}
+
+// showStatus shows the status data in a desktop notification.
+func (t *tray) showStatus() {
+	t.notify(statusNotifyTitle, formatTrayStatus(time.Now()))
+}
+
+// notify shows body in a desktop notification, replacing the one we showed before.
+func (t *tray) notify(title, body string) {
+	t.notifyMu.Lock()
+	defer t.notifyMu.Unlock()
+
+	call := t.conn.Object(notifyName, notifyPath).Call(notifyName+".Notify", 0,
+		"gps-qth-qtr", t.notifyID, trayIconName, title, body,
+		[]string{}, map[string]dbus.Variant{}, int32(-1))
+	if call.Err != nil {
+		log.Printf("%+v", call.Err)
+		return
+	}
+
+	err := call.Store(&t.notifyID)
+	if err != nil {
+		log.Printf("%+v", err)
+	}
+}
+
+// update polls the gps device in the background.
+func (t *tray) update() {
+	go func() {
+		updated := gatherGpsData(true)
+		if !updated {
+			log.Printf("updateAction: failed to update")
+		}
+	}()
+}
+
+// requestExit lets runDaemon know the user wants to exit.
+func (t *tray) requestExit() {
+	t.exitOnce.Do(func() {
+		close(t.exit)
+	})
+}
+
+// stop takes us out of the tray.
+func (t *tray) stop() {
+	close(t.quit)
+	<-t.done
+
+	err := t.conn.Close()
+	if err != nil {
+		log.Printf("%+v", err)
+	}
+}
+
+// item returns the menu item with id.
+func (t *tray) item(id int32) (trayItem, bool) {
+	for _, it := range t.items {
+		if it.id == id {
+			return it, true
+		}
+	}
+	return trayItem{}, false
+}
+
+// itemProperties returns the DBusMenu properties of the menu item with id, 0 is the menu itself.
+func (t *tray) itemProperties(id int32) map[string]dbus.Variant {
+	if id == 0 {
+		return map[string]dbus.Variant{
+			"children-display": dbus.MakeVariant("submenu"),
+		}
+	}
+
+	it, _ := t.item(id)
+	return map[string]dbus.Variant{
+		"type":    dbus.MakeVariant("standard"),
+		"label":   dbus.MakeVariant(it.label),
+		"enabled": dbus.MakeVariant(true),
+		"visible": dbus.MakeVariant(true),
+	}
+}
+
+// sniItem is the org.kde.StatusNotifierItem interface of the tray.
+type sniItem struct {
+	t *tray
+}
+
+// Activate is a click on the icon, it shows the status.
+func (s sniItem) Activate(x, y int32) *dbus.Error {
+	s.t.showStatus()
+	return nil
+}
+
+// SecondaryActivate is a middle click on the icon, it copies the gridsquare.
+func (s sniItem) SecondaryActivate(x, y int32) *dbus.Error {
+	s.t.copyGridsquare()
+	return nil
+}
+
+// ContextMenu isn't needed, the host shows the menu.
+func (s sniItem) ContextMenu(x, y int32) *dbus.Error {
+	return nil
+}
+
+// Scroll does nothing.
+func (s sniItem) Scroll(delta int32, orientation string) *dbus.Error {
+	return nil
+}
+
+// dbusMenu is the com.canonical.dbusmenu interface of the tray.
+type dbusMenu struct {
+	t *tray
+}
+
+// GetLayout returns the menu, it never changes so the revision is always 1.
+func (m dbusMenu) GetLayout(parentID int32, recursionDepth int32, propertyNames []string) (uint32, menuLayout, *dbus.Error) {
+	if _, ok := m.t.item(parentID); parentID != 0 && !ok {
+		return 0, menuLayout{}, dbus.MakeFailedError(fmt.Errorf("no menu item %d", parentID))
+	}
+
+	l := menuLayout{
+		ID:         parentID,
+		Properties: m.t.itemProperties(parentID),
+		Children:   []dbus.Variant{},
+	}
+	if parentID == 0 && recursionDepth != 0 {
+		for _, it := range m.t.items {
+			l.Children = append(l.Children, dbus.MakeVariant(menuLayout{
+				ID:         it.id,
+				Properties: m.t.itemProperties(it.id),
+				Children:   []dbus.Variant{},
+			}))
+		}
+	}
+
+	return 1, l, nil
+}
+
+// GetGroupProperties returns the properties of the menu items with ids, all of them if ids is empty.
+func (m dbusMenu) GetGroupProperties(ids []int32, propertyNames []string) ([]menuItemProperties, *dbus.Error) {
+	if len(ids) == 0 {
+		for _, it := range m.t.items {
+			ids = append(ids, it.id)
+		}
+	}
+
+	ps := []menuItemProperties{}
+	for _, id := range ids {
+		if _, ok := m.t.item(id); ok || id == 0 {
+			ps = append(ps, menuItemProperties{ID: id, Properties: m.t.itemProperties(id)})
+		}
+	}
+	return ps, nil
+}
+
+// GetProperty returns one property of the menu item with id.
+func (m dbusMenu) GetProperty(id int32, name string) (dbus.Variant, *dbus.Error) {
+	v, ok := m.t.itemProperties(id)[name]
+	if !ok {
+		return dbus.Variant{}, dbus.MakeFailedError(fmt.Errorf("menu item %d has no property %s", id, name))
+	}
+	return v, nil
+}
+
+// Event runs the action of the menu item with id when it is clicked.
+func (m dbusMenu) Event(id int32, eventID string, data dbus.Variant, timestamp uint32) *dbus.Error {
+	it, ok := m.t.item(id)
+	if !ok {
+		return dbus.MakeFailedError(fmt.Errorf("no menu item %d", id))
+	}
+
+	if eventID == "clicked" {
+		it.action()
+	}
+	return nil
+}
+
+// EventGroup runs Event for each of events, returns the ids that weren't found.
+func (m dbusMenu) EventGroup(events []menuEvent) ([]int32, *dbus.Error) {
+	notFound := []int32{}
+	for _, e := range events {
+		if err := m.Event(e.ID, e.EventID, e.Data, e.Timestamp); err != nil {
+			notFound = append(notFound, e.ID)
+		}
+	}
+	return notFound, nil
+}
+
+// AboutToShow returns false, the menu doesn't change.
+func (m dbusMenu) AboutToShow(id int32) (bool, *dbus.Error) {
+	return false, nil
+}
+
+// AboutToShowGroup returns no updates needed and no ids not found.
+func (m dbusMenu) AboutToShowGroup(ids []int32) ([]int32, []int32, *dbus.Error) {
+	return []int32{}, []int32{}, nil
+}
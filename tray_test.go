@@ -0,0 +1,359 @@
+// +build !windows
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// startSessionBus runs a private session bus, returns its address and a func to stop it.
+func startSessionBus(t *testing.T) (string, func()) {
+	path, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon isn't installed")
+	}
+
+	cmd := exec.Command(path, "--session", "--nofork", "--print-address")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmd.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		stop()
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(address), stop
+}
+
+// fakeWatcher is a StatusNotifierWatcher that remembers what registered.
+type fakeWatcher struct {
+	registered chan string
+}
+
+func (w fakeWatcher) RegisterStatusNotifierItem(service string) *dbus.Error {
+	w.registered <- service
+	return nil
+}
+
+// fakeNotifications is a notification server that remembers what it was asked to show.
+type fakeNotifications struct {
+	bodies chan string
+}
+
+func (n fakeNotifications) Notify(appName string, replacesID uint32, appIcon, summary, body string, actions []string, hints map[string]dbus.Variant, timeout int32) (uint32, *dbus.Error) {
+	n.bodies <- body
+	return 7, nil
+}
+
+// waitFor returns the next value from c, fails the test if it takes too long.
+func waitFor(t *testing.T, c <-chan string, what string) string {
+	select {
+	case s := <-c:
+		return s
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+	return ""
+}
+
+func Test_tray(t *testing.T) {
+	address, stop := startSessionBus(t)
+	defer stop()
+
+	dir, err := ioutil.TempDir("", "tray")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	prevGPSData := newGPSData()
+	prevGPSData.copy(gpsdata)
+	defer gpsdata.copy(prevGPSData)
+	g := newGPSData()
+	g.setGridsquare("FM18lw")
+	g.setFixQuality("GPS fix (SPS)")
+	g.setNumSatellites(9)
+	gpsdata.copy(g)
+
+	prevClipboard := clipboardCommands
+	defer func() {
+		clipboardCommands = prevClipboard
+	}()
+	clipped := filepath.Join(dir, "clipboard")
+	clipboardCommands = [][]string{{"no-such-clipboard"}, {"sh", "-c", "cat > " + clipped}}
+
+	// the desktop's side of the bus
+	host, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer host.Close()
+
+	watcher := fakeWatcher{registered: make(chan string, 4)}
+	err = host.Export(watcher, sniWatcherPath, sniWatcherName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = host.RequestName(sniWatcherName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notifications := fakeNotifications{bodies: make(chan string, 4)}
+	err = host.Export(notifications, notifyPath, notifyName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = host.RequestName(notifyName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = host.AddMatchSignal(dbus.WithMatchInterface(sniInterface), dbus.WithMatchMember("NewToolTip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signals := make(chan *dbus.Signal, 8)
+	host.Signal(signals)
+
+	// our side
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := newTray(conn)
+	if err != nil {
+		t.Fatalf("newTray() error = %v", err)
+	}
+	defer tr.stop()
+
+	name := waitFor(t, watcher.registered, "registration")
+	if name != tr.name {
+		t.Errorf("registered %q, want %q", name, tr.name)
+	}
+	item := host.Object(name, sniPath)
+	menu := host.Object(name, menuPath)
+
+	t.Run("Properties", func(t *testing.T) {
+		for p, want := range map[string]interface{}{
+			"Id":         "gps-qth-qtr",
+			"Status":     "Active",
+			"IconName":   "",
+			"Menu":       menuPath,
+			"ItemIsMenu": false,
+		} {
+			v, err := item.GetProperty(sniInterface + "." + p)
+			if err != nil {
+				t.Errorf("GetProperty(%s) error = %v", p, err)
+				continue
+			}
+			if v.Value() != want {
+				t.Errorf("GetProperty(%s) = %v, want %v", p, v.Value(), want)
+			}
+		}
+
+		var pixmaps []sniPixmap
+		err := item.Call("org.freedesktop.DBus.Properties.Get", 0, sniInterface, "IconPixmap").Store(&pixmaps)
+		if err != nil || len(pixmaps) != len(trayPixmapSizes) {
+			t.Errorf("GetProperty(IconPixmap) = %d pixmaps, %v, want %d", len(pixmaps), err, len(trayPixmapSizes))
+		}
+
+		v, err := menu.GetProperty(menuInterface + ".Version")
+		if err != nil || v.Value() != uint32(3) {
+			t.Errorf("GetProperty(Version) = %v, %v, want 3", v, err)
+		}
+	})
+
+	t.Run("Introspect", func(t *testing.T) {
+		var xml string
+		err := menu.Call("org.freedesktop.DBus.Introspectable.Introspect", 0).Store(&xml)
+		if err != nil {
+			t.Fatalf("Introspect() error = %v", err)
+		}
+		if !strings.Contains(xml, `<method name="GetLayout">`) {
+			t.Errorf("Introspect() = %s, want GetLayout", xml)
+		}
+	})
+
+	t.Run("Layout", func(t *testing.T) {
+		var revision uint32
+		var layout menuLayout
+		err := menu.Call(menuInterface+".GetLayout", 0, int32(0), int32(-1), []string{}).Store(&revision, &layout)
+		if err != nil {
+			t.Fatalf("GetLayout() error = %v", err)
+		}
+
+		var labels []string
+		for _, c := range layout.Children {
+			var child menuLayout
+			err := dbus.Store(c.Value().([]interface{}), &child.ID, &child.Properties, &child.Children)
+			if err != nil {
+				t.Fatalf("GetLayout() child error = %v", err)
+			}
+			labels = append(labels, child.Properties["label"].Value().(string))
+		}
+		if strings.Join(labels, "|") != "Copy Gridsquare|Status...|Update now|Exit" {
+			t.Errorf("GetLayout() labels = %v", labels)
+		}
+
+		err = menu.Call(menuInterface+".GetLayout", 0, int32(99), int32(-1), []string{}).Store(&revision, &layout)
+		if err == nil {
+			t.Errorf("GetLayout() of a missing item error = nil")
+		}
+	})
+
+	t.Run("Tool tip", func(t *testing.T) {
+		v := g.values()
+		v.Gridsquare = "FN20aa"
+		v.Attempted = time.Now()
+		publish(nil, v)
+
+		select {
+		case <-signals:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for NewToolTip")
+		}
+
+		p, err := item.GetProperty(sniInterface + ".ToolTip")
+		if err != nil {
+			t.Fatalf("GetProperty(ToolTip) error = %v", err)
+		}
+		var tt sniToolTip
+		err = dbus.Store(p.Value().([]interface{}), &tt.IconName, &tt.Pixmaps, &tt.Title, &tt.Text)
+		if err != nil {
+			t.Fatalf("GetProperty(ToolTip) error = %v", err)
+		}
+		if tt.Text != "FN20aa, 3D fix, 9 satellites\nTime not set yet" {
+			t.Errorf("ToolTip text = %q", tt.Text)
+		}
+
+		var pixmaps []sniPixmap
+		err = item.Call("org.freedesktop.DBus.Properties.Get", 0, sniInterface, "IconPixmap").Store(&pixmaps)
+		if err != nil {
+			t.Fatalf("GetProperty(IconPixmap) error = %v", err)
+		}
+		want := newSNIPixmaps(tray3DFix)
+		if len(pixmaps) != len(want) || !bytes.Equal(pixmaps[0].Data, want[0].Data) {
+			t.Errorf("IconPixmap is not the 3D fix icon")
+		}
+	})
+
+	t.Run("Copy Gridsquare", func(t *testing.T) {
+		err := menu.Call(menuInterface+".Event", 0, int32(1), "clicked", dbus.MakeVariant(""), uint32(0)).Err
+		if err != nil {
+			t.Fatalf("Event() error = %v", err)
+		}
+
+		// the copy happens in the background
+		var b []byte
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			b, _ = ioutil.ReadFile(clipped)
+			if string(b) == "FM18lw" {
+				break
+			}
+		}
+		if string(b) != "FM18lw" {
+			t.Errorf("clipboard = %q, want FM18lw", b)
+		}
+	})
+
+	t.Run("Status", func(t *testing.T) {
+		err := menu.Call(menuInterface+".Event", 0, int32(2), "clicked", dbus.MakeVariant(""), uint32(0)).Err
+		if err != nil {
+			t.Fatalf("Event() error = %v", err)
+		}
+
+		body := waitFor(t, notifications.bodies, "notification")
+		if !strings.Contains(body, "Gridsquare:    FM18lw\n") {
+			t.Errorf("notification = %q, want the gridsquare", body)
+		}
+		tr.notifyMu.Lock()
+		id := tr.notifyID
+		tr.notifyMu.Unlock()
+		if id != 7 {
+			t.Errorf("notifyID = %d, want 7", id)
+		}
+	})
+
+	t.Run("Watcher restart", func(t *testing.T) {
+		_, err := host.ReleaseName(sniWatcherName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = host.RequestName(sniWatcherName, dbus.NameFlagDoNotQueue)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if name := waitFor(t, watcher.registered, "registration"); name != tr.name {
+			t.Errorf("registered %q, want %q", name, tr.name)
+		}
+	})
+
+	t.Run("Exit", func(t *testing.T) {
+		var notFound []int32
+		err := menu.Call(menuInterface+".EventGroup", 0, []menuEvent{
+			{ID: 4, EventID: "clicked", Data: dbus.MakeVariant("")},
+			{ID: 42, EventID: "clicked", Data: dbus.MakeVariant("")},
+		}).Store(&notFound)
+		if err != nil {
+			t.Fatalf("EventGroup() error = %v", err)
+		}
+		if len(notFound) != 1 || notFound[0] != 42 {
+			t.Errorf("EventGroup() not found = %v, want [42]", notFound)
+		}
+
+		select {
+		case <-tr.exit:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Exit didn't close exit")
+		}
+	})
+}
+
+func Test_trayStateOf_setSystemTime(t *testing.T) {
+	// the time it already is, so the test can't move the clock when it is allowed to set it
+	g := newGPSData()
+	g.setStatus("")
+	g.setTime(time.Now().UTC())
+	g.setFixQuality("GPS")
+	g.setNumSatellites(8)
+	g.setAttempted(time.Now())
+
+	err := stepClock(g, setSystemTime)
+	switch err {
+	case nil:
+		if got := trayStateOf(g.values()); got != traySynced {
+			t.Errorf("trayStateOf() after setting the time = %v, want %v", got, traySynced)
+		}
+	case errSetTimePermission:
+		if got := trayStateOf(g.values()); got == traySynced {
+			t.Errorf("trayStateOf() without permission = %v, want it not set", got)
+		}
+		if got := formatTrayToolTipText(g.values(), time.Now()); !strings.HasSuffix(got, "\nTime not set yet") {
+			t.Errorf("formatTrayToolTipText() without permission = %q, want the time not set", got)
+		}
+	default:
+		t.Errorf("stepClock() error = %v, want nil or %v", err, errSetTimePermission)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+	"time"
+)
+
+// trayState is what the tray icon shows.
+type trayState int
+
+const (
+	// gps device hasn't answered yet, or stopped answering
+	trayNoData trayState = iota
+
+	// receiver is answering but doesn't have a fix
+	trayNoFix
+
+	// receiver has a 2D fix, no altitude
+	tray2DFix
+
+	// receiver has a 3D fix
+	tray3DFix
+
+	// receiver has a fix and the system time was set from it on the last poll
+	traySynced
+)
+
+// trayStates are all the states, for making the icons.
+var trayStates = []trayState{trayNoData, trayNoFix, tray2DFix, tray3DFix, traySynced}
+
+// String returns the state to show user.
+func (s trayState) String() string {
+	switch s {
+	case trayNoFix:
+		return "No fix"
+	case tray2DFix:
+		return "2D fix"
+	case tray3DFix:
+		return "3D fix"
+	case traySynced:
+		return "Time set"
+	}
+	return "No data"
+}
+
+// trayStateOf returns the state v should show as.
+func trayStateOf(v gpsValues) trayState {
+	switch {
+	case v.Stale || v.Attempted == (time.Time{}):
+		return trayNoData
+	case v.Status == errInvalidState.Error():
+		return trayNoFix
+	case v.Status != "":
+		return trayNoData
+	case !v.hasFix():
+		return trayNoFix
+	case v.Synced != (time.Time{}) && !v.Synced.Before(v.Time):
+		// set after the time of the fix means it was set from it
+		return traySynced
+	case v.FixMode == 2:
+		return tray2DFix
+	case v.FixMode == 3:
+		return tray3DFix
+	case v.NumSatellites >= 4:
+		// receiver doesn't send GSA, 4 satellites are enough for 3D
+		return tray3DFix
+	}
+	return tray2DFix
+}
+
+// formatFixMode returns the kind of fix in v to show user.
+func formatFixMode(v gpsValues) string {
+	s := trayStateOf(v)
+	if s != traySynced {
+		return s.String()
+	}
+
+	v.Synced = time.Time{}
+	return trayStateOf(v).String()
+}
+
+// formatTrayToolTip returns the tool tip of the tray icon, the gridsquare, satellites and time since the system time was set.
+func formatTrayToolTip(v gpsValues, now time.Time) string {
+	return "gps-qth-qtr\n" + formatTrayToolTipText(v, now)
+}
+
+// formatTrayToolTipText returns the tool tip without the title, for trays that show the title on its own.
+func formatTrayToolTipText(v gpsValues, now time.Time) string {
+	var b strings.Builder
+
+	switch {
+	case v.Gridsquare == "":
+		b.WriteString("No gridsquare yet")
+	case v.Stale:
+		b.WriteString(v.Gridsquare + " (last known)")
+	default:
+		b.WriteString(v.Gridsquare)
+	}
+	b.WriteString(", " + formatFixMode(v))
+	if v.NumSatellites > -1 {
+		fmt.Fprintf(&b, ", %d satellites", v.NumSatellites)
+	}
+
+	if v.Synced == (time.Time{}) {
+		b.WriteString("\nTime not set yet")
+	} else {
+		b.WriteString("\nTime set " + now.Sub(v.Synced).Round(time.Second).String() + " ago")
+	}
+
+	return b.String()
+}
+
+// trayIconColors are the colors of the icon of each state.
+var trayIconColors = map[trayState]color.RGBA{
+	trayNoData: {R: 0x80, G: 0x80, B: 0x80, A: 0xff},
+	trayNoFix:  {R: 0xd6, G: 0x27, B: 0x28, A: 0xff},
+	tray2DFix:  {R: 0xff, G: 0xa5, B: 0x00, A: 0xff},
+	tray3DFix:  {R: 0x2c, G: 0xa0, B: 0x2c, A: 0xff},
+	traySynced: {R: 0x2c, G: 0xa0, B: 0x2c, A: 0xff},
+}
+
+// trayIconImage returns the icon for s, size pixels square
+// a ring with no data, a disc colored by fix otherwise, with a white dot in the middle once the time is set.
+func trayIconImage(s trayState, size int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	c := trayIconColors[s]
+
+	r := float64(size)/2 - 0.5
+	inner := 0.0
+	if s == trayNoData {
+		inner = r * 0.55
+	}
+	dot := 0.0
+	if s == traySynced {
+		dot = r * 0.35
+	}
+
+	// 4x4 samples per pixel smooth the edges
+	const samples = 4
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			var in, white int
+			for sy := 0; sy < samples; sy++ {
+				for sx := 0; sx < samples; sx++ {
+					dx := float64(x) + (float64(sx)+0.5)/samples - float64(size)/2
+					dy := float64(y) + (float64(sy)+0.5)/samples - float64(size)/2
+					d := math.Sqrt(dx*dx + dy*dy)
+
+					switch {
+					case d < dot:
+						white++
+					case d <= r && d >= inner:
+						in++
+					}
+				}
+			}
+
+			n := in + white
+			if n == 0 {
+				continue
+			}
+			blend := func(v uint8) uint8 {
+				return uint8((int(v)*in + 0xff*white) / n)
+			}
+			img.SetRGBA(x, y, color.RGBA{
+				R: uint8(int(blend(c.R)) * n / (samples * samples)),
+				G: uint8(int(blend(c.G)) * n / (samples * samples)),
+				B: uint8(int(blend(c.B)) * n / (samples * samples)),
+				A: uint8(0xff * n / (samples * samples)),
+			})
+		}
+	}
+
+	return img
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_trayStateOf(t *testing.T) {
+	fixed := time.Date(2020, 1, 21, 12, 0, 0, 0, time.UTC)
+	fix := gpsValues{
+		Time:          fixed,
+		Gridsquare:    "FN20aa",
+		FixQuality:    "GPS fix (SPS)",
+		NumSatellites: 9,
+		Attempted:     fixed,
+	}
+
+	tests := []struct {
+		name   string
+		change func(v *gpsValues)
+		want   trayState
+	}{
+		{
+			name:   "Never polled",
+			change: func(v *gpsValues) { v.Attempted = time.Time{} },
+			want:   trayNoData,
+		},
+		{
+			name:   "Stale",
+			change: func(v *gpsValues) { v.Stale = true },
+			want:   trayNoData,
+		},
+		{
+			name:   "Device not answering",
+			change: func(v *gpsValues) { v.Status = "open COM3: The system cannot find the file specified." },
+			want:   trayNoData,
+		},
+		{
+			name:   "Invalid state",
+			change: func(v *gpsValues) { v.Status = errInvalidState.Error() },
+			want:   trayNoFix,
+		},
+		{
+			name:   "Invalid fix",
+			change: func(v *gpsValues) { v.FixQuality = "invalid" },
+			want:   trayNoFix,
+		},
+		{
+			name:   "2D from GSA",
+			change: func(v *gpsValues) { v.FixMode = 2 },
+			want:   tray2DFix,
+		},
+		{
+			name:   "3D from GSA",
+			change: func(v *gpsValues) { v.FixMode = 3; v.NumSatellites = 3 },
+			want:   tray3DFix,
+		},
+		{
+			name:   "3D from satellites",
+			change: func(v *gpsValues) {},
+			want:   tray3DFix,
+		},
+		{
+			name:   "2D from satellites",
+			change: func(v *gpsValues) { v.NumSatellites = 3 },
+			want:   tray2DFix,
+		},
+		{
+			name:   "Synced",
+			change: func(v *gpsValues) { v.Synced = fixed.Add(time.Second) },
+			want:   traySynced,
+		},
+		{
+			name:   "Synced before the fix",
+			change: func(v *gpsValues) { v.Synced = fixed.Add(-time.Hour) },
+			want:   tray3DFix,
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+		t.Run(ttt.name, func(t *testing.T) {
+			v := fix
+			ttt.change(&v)
+			if got := trayStateOf(v); got != ttt.want {
+				t.Errorf("trayStateOf() = %v, want %v", got, ttt.want)
+			}
+		})
+	}
+}
+
+func Test_formatTrayToolTip(t *testing.T) {
+	now := time.Date(2020, 1, 21, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		v    gpsValues
+		want string
+	}{
+		{
+			name: "Nothing yet",
+			v:    gpsValues{NumSatellites: -1},
+			want: "gps-qth-qtr\nNo gridsquare yet, No data\nTime not set yet",
+		},
+		{
+			name: "Synced",
+			v: gpsValues{
+				Time:          now.Add(-2 * time.Minute),
+				Gridsquare:    "FN20aa",
+				FixQuality:    "GPS fix (SPS)",
+				FixMode:       3,
+				NumSatellites: 9,
+				Attempted:     now.Add(-2 * time.Minute),
+				Synced:        now.Add(-2 * time.Minute),
+			},
+			want: "gps-qth-qtr\nFN20aa, 3D fix, 9 satellites\nTime set 2m0s ago",
+		},
+		{
+			name: "Last known",
+			v: gpsValues{
+				Gridsquare:    "FN20aa",
+				NumSatellites: -1,
+				Stale:         true,
+			},
+			want: "gps-qth-qtr\nFN20aa (last known), No data\nTime not set yet",
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+		t.Run(ttt.name, func(t *testing.T) {
+			if got := formatTrayToolTip(ttt.v, now); got != ttt.want {
+				t.Errorf("formatTrayToolTip() = %q, want %q", got, ttt.want)
+			}
+		})
+	}
+}
+
+func Test_trayIconImage(t *testing.T) {
+	for _, s := range trayStates {
+		img := trayIconImage(s, 32)
+
+		if a := img.RGBAAt(0, 0).A; a != 0 {
+			t.Errorf("trayIconImage(%v) corner alpha = %d, want 0", s, a)
+		}
+
+		center := img.RGBAAt(16, 16)
+		switch s {
+		case trayNoData:
+			if center.A != 0 {
+				t.Errorf("trayIconImage(%v) center alpha = %d, want 0", s, center.A)
+			}
+		case traySynced:
+			if center.R != 0xff || center.G != 0xff || center.B != 0xff {
+				t.Errorf("trayIconImage(%v) center = %v, want white", s, center)
+			}
+		default:
+			if center != trayIconColors[s] {
+				t.Errorf("trayIconImage(%v) center = %v, want %v", s, center, trayIconColors[s])
+			}
+		}
+	}
+}
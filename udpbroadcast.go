@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"time"
+)
+
+// udpBroadcastConfig is the configuration of the UDP position broadcaster.
+type udpBroadcastConfig struct {
+	Address  string
+	Format   string
+	Interval time.Duration
+}
+
+// udpPosition is the packet broadcast after each successful poll.
+type udpPosition struct {
+	XMLName       xml.Name  `xml:"position" json:"-"`
+	App           string    `xml:"app" json:"app"`
+	Station       string    `xml:"station" json:"station"`
+	Time          time.Time `xml:"time" json:"time"`
+	Gridsquare    string    `xml:"gridsquare" json:"gridsquare"`
+	Latitude      float64   `xml:"latitude" json:"latitude"`
+	Longitude     float64   `xml:"longitude" json:"longitude"`
+	FixQuality    string    `xml:"fixquality" json:"fixQuality"`
+	NumSatellites int       `xml:"satellites" json:"numSatellites"`
+	HDOP          float64   `xml:"hdop" json:"hdop"`
+}
+
+// udpBroadcaster sends our position to other station software on the LAN.
+type udpBroadcaster struct {
+	cfg     udpBroadcastConfig
+	addr    *net.UDPAddr
+	conn    *net.UDPConn
+	station string
+	last    time.Time
+	quit    chan struct{}
+	done    chan struct{}
+}
+
+// newUDPBroadcaster validates cfg and returns a broadcaster ready to start.
+func newUDPBroadcaster(cfg udpBroadcastConfig) (*udpBroadcaster, error) {
+	switch cfg.Format {
+	case "":
+		cfg.Format = "json"
+	case "json", "xml":
+	default:
+		err := fmt.Errorf("UDP broadcast format must be json or xml")
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	if cfg.Interval < 0 {
+		cfg.Interval = 0
+	}
+	cfg.Interval *= time.Second
+
+	addr, err := net.ResolveUDPAddr("udp", cfg.Address)
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	station, err := os.Hostname()
+	if err != nil {
+		log.Printf("%+v", err)
+	}
+
+	return &udpBroadcaster{
+		cfg:     cfg,
+		addr:    addr,
+		conn:    conn,
+		station: station,
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// encode returns the packet for v in the configured format.
+func (ub *udpBroadcaster) encode(v gpsValues) ([]byte, error) {
+	p := udpPosition{
+		App:           "gps-qth-qtr",
+		Station:       ub.station,
+		Time:          v.Time,
+		Gridsquare:    v.Gridsquare,
+		Latitude:      v.Latitude,
+		Longitude:     v.Longitude,
+		FixQuality:    v.FixQuality,
+		NumSatellites: v.NumSatellites,
+		HDOP:          v.HDOP,
+	}
+
+	var b []byte
+	var err error
+	if ub.cfg.Format == "xml" {
+		b, err = xml.Marshal(p)
+		b = append([]byte(xml.Header), b...)
+	} else {
+		b, err = json.Marshal(p)
+	}
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+	return b, nil
+}
+
+// send broadcasts v unless it is from a failed poll or the last packet was sent too recently.
+func (ub *udpBroadcaster) send(v gpsValues, now time.Time) error {
+	if v.Status != "" || now.Sub(ub.last) < ub.cfg.Interval {
+		return nil
+	}
+
+	b, err := ub.encode(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = ub.conn.WriteToUDP(b, ub.addr)
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+	ub.last = now
+
+	return nil
+}
+
+// start broadcasts after every successful poll in the background until stop is called.
+func (ub *udpBroadcaster) start() {
+	updates, unsubscribe := subscribe()
+
+	go func() {
+		defer close(ub.done)
+		defer unsubscribe()
+
+		for {
+			select {
+			case u := <-updates:
+				_ = ub.send(u.values, time.Now())
+			case <-ub.quit:
+				return
+			}
+		}
+	}()
+}
+
+// stop waits for the background work to finish and closes the connection.
+func (ub *udpBroadcaster) stop() {
+	close(ub.quit)
+	<-ub.done
+	ub.conn.Close()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func Test_udpBroadcaster_encode(t *testing.T) {
+	g := newGPSData()
+	g.setTime(time.Date(2020, time.Month(1), 18, 2, 2, 2, 0, time.UTC))
+	g.setGridsquare("FM18lw")
+	g.setLatitude(38.92)
+	g.setLongitude(-77.065)
+	g.setFixQuality("GPS fix (SPS)")
+	g.setNumSatellites(9)
+	g.setHDOP(0.96)
+
+	tests := []struct {
+		name   string
+		format string
+		want   string
+	}{
+		{
+			name:   "JSON",
+			format: "json",
+			want:   `{"app":"gps-qth-qtr","station":"shack","time":"2020-01-18T02:02:02Z","gridsquare":"FM18lw","latitude":38.92,"longitude":-77.065,"fixQuality":"GPS fix (SPS)","numSatellites":9,"hdop":0.96}`,
+		},
+		{
+			name:   "XML",
+			format: "xml",
+			want:   "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<position><app>gps-qth-qtr</app><station>shack</station><time>2020-01-18T02:02:02Z</time><gridsquare>FM18lw</gridsquare><latitude>38.92</latitude><longitude>-77.065</longitude><fixquality>GPS fix (SPS)</fixquality><satellites>9</satellites><hdop>0.96</hdop></position>",
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			ub := &udpBroadcaster{cfg: udpBroadcastConfig{Format: ttt.format}, station: "shack"}
+
+			got, err := ub.encode(g.values())
+			if err != nil {
+				t.Fatalf("encode() error = %v", err)
+			}
+			if string(got) != ttt.want {
+				t.Errorf("encode() = %s, want %s", got, ttt.want)
+			}
+		})
+	}
+}
+
+func Test_udpBroadcaster_send(t *testing.T) {
+	l, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("net.ListenUDP() error = %v", err)
+	}
+	defer l.Close()
+
+	ub, err := newUDPBroadcaster(udpBroadcastConfig{Address: l.LocalAddr().String(), Interval: 60})
+	if err != nil {
+		t.Fatalf("newUDPBroadcaster() error = %v", err)
+	}
+	defer ub.conn.Close()
+
+	v := newGPSData().values()
+	v.Gridsquare = "FM18lw"
+	now := time.Now()
+
+	// failed polls and polls within the interval aren't sent
+	sends := []struct {
+		status string
+		at     time.Time
+		want   bool
+	}{
+		{status: "RMC line bad checksum", at: now, want: false},
+		{status: "", at: now, want: true},
+		{status: "", at: now.Add(30 * time.Second), want: false},
+		{status: "", at: now.Add(61 * time.Second), want: true},
+	}
+	for i, s := range sends {
+		v.Status = s.status
+		err = ub.send(v, s.at)
+		if err != nil {
+			t.Fatalf("send() error = %v", err)
+		}
+
+		err = l.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		if err != nil {
+			t.Fatalf("SetReadDeadline() error = %v", err)
+		}
+		b := make([]byte, 1024)
+		_, _, err = l.ReadFromUDP(b)
+		if (err == nil) != s.want {
+			t.Errorf("send %d sent = %v, want %v", i, err == nil, s.want)
+		}
+	}
+}
+
+func Test_newUDPBroadcaster(t *testing.T) {
+	_, err := newUDPBroadcaster(udpBroadcastConfig{Address: "255.255.255.255:12060", Format: "csv"})
+	if err == nil {
+		t.Errorf("newUDPBroadcaster() error = nil, want error for format")
+	}
+}
@@ -3,7 +3,12 @@
 package main
 
 import (
+	"fmt"
 	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 	"unsafe"
 
@@ -11,12 +16,16 @@ import (
 	declarative "github.com/lxn/walk/declarative"
 	"github.com/lxn/win"
 	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
 )
 
 var (
 	// pointer to SetSystemTime proc
 	procSetSystemTime *windows.Proc
 
+	// pointer to AttachConsole proc
+	procAttachConsole *windows.Proc
+
 	// our icon
 	appIcon *walk.Icon
 
@@ -25,6 +34,12 @@ var (
 
 	// reference to status window
 	statusWindow *walk.MainWindow
+
+	// prevent multiple settings windows
+	nbmRunSettingsWindow = NewNonBlockingMutex()
+
+	// reference to settings window
+	settingsWindow *walk.MainWindow
 )
 
 // setSystemTime calls the windows SetSystemTime API
@@ -59,46 +74,161 @@ func init() {
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	procAttachConsole, err = dll.FindProc("AttachConsole")
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// attachConsole connects stdout & stderr to the console we were started from, gui programs don't get one
+// anything already redirected is left alone.
+func attachConsole() {
+	// ATTACH_PARENT_PROCESS
+	r1, _, _ := procAttachConsole.Call(uintptr(^uint32(0)))
+	if r1 == 0 {
+		// not started from a console
+		return
+	}
+
+	for _, std := range []struct {
+		handle uint32
+		file   **os.File
+	}{
+		{handle: windows.STD_OUTPUT_HANDLE, file: &os.Stdout},
+		{handle: windows.STD_ERROR_HANDLE, file: &os.Stderr},
+	} {
+		h, err := windows.GetStdHandle(std.handle)
+		if err == nil && h != 0 && h != windows.InvalidHandle {
+			continue
+		}
+
+		h, err = windows.CreateFile(windows.StringToUTF16Ptr("CONOUT$"), windows.GENERIC_WRITE, windows.FILE_SHARE_WRITE, nil, windows.OPEN_EXISTING, 0, 0)
+		if err != nil {
+			continue
+		}
+		*std.file = os.NewFile(uintptr(h), "CONOUT$")
+	}
+}
+
+// makeRaw puts the console in so keys are read as they are pressed and not echoed, and so it understands
+// the VT escape sequences written to it, returns a func to put it back.
+func makeRaw(in, out *os.File) (func(), error) {
+	hin := windows.Handle(in.Fd())
+	hout := windows.Handle(out.Fd())
+
+	var inMode, outMode uint32
+	err := windows.GetConsoleMode(hin, &inMode)
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+	err = windows.GetConsoleMode(hout, &outMode)
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	err = windows.SetConsoleMode(hin, inMode&^(windows.ENABLE_ECHO_INPUT|windows.ENABLE_LINE_INPUT|windows.ENABLE_PROCESSED_INPUT)|windows.ENABLE_VIRTUAL_TERMINAL_INPUT)
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+	err = windows.SetConsoleMode(hout, outMode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING)
+	if err != nil {
+		log.Printf("%+v", err)
+		_ = windows.SetConsoleMode(hin, inMode)
+		return nil, err
+	}
+
+	return func() {
+		_ = windows.SetConsoleMode(hin, inMode)
+		_ = windows.SetConsoleMode(hout, outMode)
+	}, nil
+}
+
+// terminalSize returns the columns and rows of the console window f is.
+func terminalSize(f *os.File) (int, int, error) {
+	var info windows.ConsoleScreenBufferInfo
+	err := windows.GetConsoleScreenBufferInfo(windows.Handle(f.Fd()), &info)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(info.Window.Right-info.Window.Left) + 1, int(info.Window.Bottom-info.Window.Top) + 1, nil
 }
 
-// runStatusWindow presents the user with a window containing the GPS data we have collected
+// runStatusWindow presents the user with a window containing the GPS data we have collected, kept up to date while it is open
 func runStatusWindow() error {
 	if nbmRunStatusWindow.Lock() {
 		defer nbmRunStatusWindow.Unlock()
 
+		// name, value and a copy button per row, and the satellites in view
+		var skyPlot, snrChart *walk.CustomWidget
+		rows := statusRows(gpsdata, time.Now())
+		values := make([]*walk.LineEdit, len(rows))
+		children := make([]declarative.Widget, 0, 3*len(rows))
+		for i, r := range rows {
+			value := &values[i]
+			children = append(children,
+				declarative.Label{Text: r.Name + ":"},
+				declarative.LineEdit{AssignTo: value, Text: r.Value, ReadOnly: true},
+				declarative.PushButton{
+					Text:    "Copy",
+					MaxSize: declarative.Size{Width: 50},
+					OnClicked: func() {
+						err := walk.Clipboard().SetText((*value).Text())
+						if err != nil {
+							log.Printf("%+v", err)
+						}
+					},
+				},
+			)
+		}
+
 		mw := declarative.MainWindow{
 			AssignTo: &statusWindow,
 			Name:     "statusmw",
 			Title:    "Status Data",
 			Icon:     appIcon,
-			Size:     declarative.Size{Width: 300, Height: 250},
-			Layout:   declarative.VBox{MarginsZero: true},
+			Size:     declarative.Size{Width: 450, Height: 560},
+			Layout:   declarative.VBox{},
 			Children: []declarative.Widget{
-				declarative.Composite{
-					Layout:        declarative.Grid{Rows: 2},
+				declarative.TabWidget{
 					StretchFactor: 4,
-					Children: []declarative.Widget{
-						declarative.TableView{
-							Name:                "statustv",
-							ColumnsOrderable:    true,
-							AlternatingRowBG:    true,
-							HeaderHidden:        true,
-							LastColumnStretched: true,
-							Columns: []declarative.TableViewColumn{
-								{Name: "Index", Hidden: true},
-								{Name: "Name"},
-								{Name: "Value"},
-							},
-							Model: newStatusTableDataModel(),
+					Pages: []declarative.TabPage{
+						{
+							Title:    "Status",
+							Layout:   declarative.Grid{Columns: 3},
+							Children: children,
 						},
-						declarative.PushButton{
-							Text: "OK",
-							OnClicked: func() {
-								statusWindow.Close()
+						{
+							Title:  "Sky",
+							Layout: declarative.VBox{},
+							Children: []declarative.Widget{
+								declarative.CustomWidget{
+									AssignTo:            &skyPlot,
+									Paint:               paintSkyPlot,
+									PaintMode:           declarative.PaintBuffered,
+									InvalidatesOnResize: true,
+									StretchFactor:       3,
+								},
+								declarative.CustomWidget{
+									AssignTo:            &snrChart,
+									Paint:               paintSNRChart,
+									PaintMode:           declarative.PaintBuffered,
+									InvalidatesOnResize: true,
+									StretchFactor:       2,
+								},
 							},
 						},
 					},
 				},
+				declarative.PushButton{
+					Text: "OK",
+					OnClicked: func() {
+						statusWindow.Close()
+					},
+				},
 			},
 		}
 
@@ -112,6 +242,44 @@ func runStatusWindow() error {
 		hwnd := statusWindow.Handle()
 		win.SetWindowLong(hwnd, win.GWL_STYLE, win.GetWindowLong(hwnd, win.GWL_STYLE) & ^(win.WS_MAXIMIZEBOX|win.WS_MINIMIZEBOX|win.WS_SIZEBOX))
 
+		// refresh after every poll, and every second so the ages move on
+		updates, unsubscribe := subscribe()
+		defer unsubscribe()
+		quit := make(chan struct{})
+		defer close(quit)
+		go func() {
+			ticker := time.NewTicker(time.Second)
+			defer ticker.Stop()
+
+			for {
+				var updated bool
+				select {
+				case _, ok := <-updates:
+					if !ok {
+						return
+					}
+					updated = true
+				case <-ticker.C:
+				case <-quit:
+					return
+				}
+
+				statusWindow.Synchronize(func() {
+					refreshStatusWindow(values)
+
+					// satellites only change with a poll
+					if updated {
+						for _, w := range []*walk.CustomWidget{skyPlot, snrChart} {
+							err := w.Invalidate()
+							if err != nil {
+								log.Printf("%+v", err)
+							}
+						}
+					}
+				})
+			}
+		}()
+
 		// start message loop
 		statusWindow.Run()
 	} else {
@@ -122,81 +290,488 @@ func runStatusWindow() error {
 	return nil
 }
 
-// newStatusTableDataModel returns data model used to populate status tableview
-func newStatusTableDataModel() *statusTableDataModel {
-	m := &statusTableDataModel{items: make([]*statusTableData, 0, 8)}
+// refreshStatusWindow shows the current status data in values, the rows of the status window.
+func refreshStatusWindow(values []*walk.LineEdit) {
+	rows := statusRows(gpsdata, time.Now())
 
-	m.items = append(m.items, &statusTableData{
-		Index: 0,
-		Name:  "Message",
-		Value: gpsdata.formatStatus(),
-	})
+	for i, v := range values {
+		// rows only change with the configuration, the window shows the ones it was opened with
+		if i >= len(rows) || v.Text() == rows[i].Value {
+			continue
+		}
 
-	m.items = append(m.items, &statusTableData{
-		Index: 1,
-		Name:  "Gridsquare",
-		Value: gpsdata.formatGridsquare(),
-	})
+		err := v.SetText(rows[i].Value)
+		if err != nil {
+			log.Printf("%+v", err)
+		}
+	}
+}
 
-	m.items = append(m.items, &statusTableData{
-		Index: 2,
-		Name:  "Latitude",
-		Value: gpsdata.formatLatitude(),
-	})
+// paintBackground clears bounds, buffered custom widgets have to do it themselves.
+func paintBackground(canvas *walk.Canvas, bounds walk.Rectangle) error {
+	brush, err := walk.NewSystemColorBrush(walk.SysColorWindow)
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+	defer brush.Dispose()
 
-	m.items = append(m.items, &statusTableData{
-		Index: 3,
-		Name:  "Longitude",
-		Value: gpsdata.formatLongitude(),
-	})
+	err = canvas.FillRectangle(brush, bounds)
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+	return nil
+}
 
-	m.items = append(m.items, &statusTableData{
-		Index: 4,
-		Name:  "Last Update",
-		Value: gpsdata.formatTime(),
-	})
+// paintSkyPlot draws where the satellites in view are, colored by system and filled if they are used in the fix.
+func paintSkyPlot(canvas *walk.Canvas, updateBounds walk.Rectangle) error {
+	bounds := canvas.Bounds()
 
-	m.items = append(m.items, &statusTableData{
-		Index: 5,
-		Name:  "Satellites",
-		Value: gpsdata.formatNumSatellites(),
-	})
+	font, err := walk.NewFont("Segoe UI", 8, 0)
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+	defer font.Dispose()
 
-	m.items = append(m.items, &statusTableData{
-		Index: 6,
-		Name:  "Fix Quality",
-		Value: gpsdata.formatFixQuality(),
-	})
+	grid, err := walk.NewCosmeticPen(walk.PenSolid, walk.RGB(0xc0, 0xc0, 0xc0))
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+	defer grid.Dispose()
 
-	m.items = append(m.items, &statusTableData{
-		Index: 7,
-		Name:  "HDOP",
-		Value: gpsdata.formatHDOP(),
-	})
+	err = paintBackground(canvas, bounds)
+	if err != nil {
+		return err
+	}
+
+	// horizon, 30 and 60 degrees of elevation, with room for the compass points
+	r := min(bounds.Width, bounds.Height)/2 - 16
+	if r <= 0 {
+		return nil
+	}
+	cx := bounds.X + bounds.Width/2
+	cy := bounds.Y + bounds.Height/2
+	for _, el := range []int{0, 30, 60} {
+		er := r * (90 - el) / 90
+		err = canvas.DrawEllipse(grid, walk.Rectangle{X: cx - er, Y: cy - er, Width: 2 * er, Height: 2 * er})
+		if err != nil {
+			log.Printf("%+v", err)
+			return err
+		}
+	}
+	_ = canvas.DrawLine(grid, walk.Point{X: cx, Y: cy - r}, walk.Point{X: cx, Y: cy + r})
+	_ = canvas.DrawLine(grid, walk.Point{X: cx - r, Y: cy}, walk.Point{X: cx + r, Y: cy})
+
+	center := walk.TextCenter | walk.TextVCenter | walk.TextSingleLine
+	black := walk.RGB(0, 0, 0)
+	for _, p := range []struct {
+		label string
+		x, y  int
+	}{
+		{label: "N", x: cx, y: cy - r - 8},
+		{label: "E", x: cx + r + 8, y: cy},
+		{label: "S", x: cx, y: cy + r + 8},
+		{label: "W", x: cx - r - 8, y: cy},
+	} {
+		_ = canvas.DrawText(p.label, font, black, walk.Rectangle{X: p.x - 8, Y: p.y - 8, Width: 16, Height: 16}, center)
+	}
+
+	// systems in view
+	sats := gpsdata.getSatellites()
+	ly := bounds.Y
+	for _, k := range skyLegend {
+		for _, sat := range sats {
+			if skySystemOf(sat.System).Name != skySystemOf(k).Name {
+				continue
+			}
+
+			ss := skySystemOf(k)
+			_ = canvas.DrawText(ss.Name, font, walk.RGB(ss.R, ss.G, ss.B), walk.Rectangle{X: bounds.X, Y: ly, Width: 80, Height: 16}, walk.TextSingleLine)
+			ly += 16
+			break
+		}
+	}
+
+	for _, sat := range sats {
+		x, y, ok := skyPoint(sat, cx, cy, r)
+		if !ok {
+			continue
+		}
+
+		err = paintSatellite(canvas, sat, walk.Rectangle{X: x - 6, Y: y - 6, Width: 12, Height: 12})
+		if err != nil {
+			return err
+		}
+		_ = canvas.DrawText(fmt.Sprintf("%d", sat.PRN), font, black, walk.Rectangle{X: x + 7, Y: y - 8, Width: 30, Height: 16}, walk.TextSingleLine)
+	}
 
-	return m
+	return nil
 }
 
-// statusTableDataModel is our datamodel type for status data
-type statusTableDataModel struct {
-	walk.SortedReflectTableModelBase
-	items []*statusTableData
+// paintSatellite draws sat as a circle in bounds, filled if it is used in the fix.
+func paintSatellite(canvas *walk.Canvas, sat satellite, bounds walk.Rectangle) error {
+	ss := skySystemOf(sat.System)
+	color := walk.RGB(ss.R, ss.G, ss.B)
+
+	pen, err := walk.NewCosmeticPen(walk.PenSolid, color)
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+	defer pen.Dispose()
+
+	if sat.Used {
+		brush, err := walk.NewSolidColorBrush(color)
+		if err != nil {
+			log.Printf("%+v", err)
+			return err
+		}
+		defer brush.Dispose()
+
+		err = canvas.FillEllipse(brush, bounds)
+		if err != nil {
+			log.Printf("%+v", err)
+			return err
+		}
+	}
+
+	err = canvas.DrawEllipse(pen, bounds)
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+	return nil
 }
 
-// Items is needed by status tableview
-func (m *statusTableDataModel) Items() interface{} {
-	return m.items
+// paintSNRChart draws the signal to noise ratio of the satellites in view as bars, colored by system and filled if
+// they are used in the fix.
+func paintSNRChart(canvas *walk.Canvas, updateBounds walk.Rectangle) error {
+	bounds := canvas.Bounds()
+
+	font, err := walk.NewFont("Segoe UI", 7, 0)
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+	defer font.Dispose()
+
+	err = paintBackground(canvas, bounds)
+	if err != nil {
+		return err
+	}
+
+	black := walk.RGB(0, 0, 0)
+	center := walk.TextCenter | walk.TextVCenter | walk.TextSingleLine
+
+	sats := gpsdata.getSatellites()
+	if len(sats) == 0 {
+		_ = canvas.DrawText("No satellites in view", font, black, bounds, center)
+		return nil
+	}
+
+	// SNR above the bar, PRN below
+	const labelHeight = 14
+	chartHeight := bounds.Height - 2*labelHeight
+	w := bounds.Width / len(sats)
+	if chartHeight <= 0 || w <= 2 {
+		return nil
+	}
+
+	for i, sat := range sats {
+		x := bounds.X + i*w
+		h := snrBarHeight(sat.SNR, chartHeight)
+		top := bounds.Y + labelHeight + chartHeight - h
+
+		if h > 0 {
+			err = paintSNRBar(canvas, sat, walk.Rectangle{X: x + w/6, Y: top, Width: w - w/3, Height: h})
+			if err != nil {
+				return err
+			}
+		}
+
+		snr := "-"
+		if sat.SNR >= 0 {
+			snr = fmt.Sprintf("%d", sat.SNR)
+		}
+		_ = canvas.DrawText(snr, font, black, walk.Rectangle{X: x, Y: top - labelHeight, Width: w, Height: labelHeight}, center)
+		_ = canvas.DrawText(fmt.Sprintf("%d", sat.PRN), font, black, walk.Rectangle{X: x, Y: bounds.Y + labelHeight + chartHeight, Width: w, Height: labelHeight}, center)
+	}
+
+	return nil
 }
 
-// statusTableData is our data type for status data
-type statusTableData struct {
-	Index int
-	Name  string
-	Value string
+// paintSNRBar draws the bar for sat in bounds, filled if it is used in the fix.
+func paintSNRBar(canvas *walk.Canvas, sat satellite, bounds walk.Rectangle) error {
+	ss := skySystemOf(sat.System)
+	color := walk.RGB(ss.R, ss.G, ss.B)
+
+	if sat.Used {
+		brush, err := walk.NewSolidColorBrush(color)
+		if err != nil {
+			log.Printf("%+v", err)
+			return err
+		}
+		defer brush.Dispose()
+
+		err = canvas.FillRectangle(brush, bounds)
+		if err != nil {
+			log.Printf("%+v", err)
+			return err
+		}
+		return nil
+	}
+
+	pen, err := walk.NewCosmeticPen(walk.PenSolid, color)
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+	defer pen.Dispose()
+
+	err = canvas.DrawRectangle(pen, bounds)
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+	return nil
+}
+
+// setNotifyIconState shows the state of v in the icon and tool tip of ni.
+func setNotifyIconState(ni *walk.NotifyIcon, icons map[trayState]*walk.Icon, v gpsValues) error {
+	err := ni.SetIcon(icons[trayStateOf(v)])
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+
+	err = ni.SetToolTip(formatTrayToolTip(v, time.Now()))
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+	return nil
+}
+
+// serialPorts returns the COM ports windows knows about, COM3 before COM10.
+func serialPorts() ([]string, error) {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, `HARDWARE\DEVICEMAP\SERIALCOMM`, registry.QUERY_VALUE)
+	if err == registry.ErrNotExist {
+		// there is no key until there is a port
+		return nil, nil
+	}
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+	defer k.Close()
+
+	names, err := k.ReadValueNames(0)
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	ports := make([]string, 0, len(names))
+	for _, n := range names {
+		p, _, err := k.GetStringValue(n)
+		if err != nil {
+			log.Printf("%+v", err)
+			continue
+		}
+		ports = append(ports, p)
+	}
+
+	sort.Slice(ports, func(i, j int) bool {
+		if len(ports[i]) != len(ports[j]) {
+			return len(ports[i]) < len(ports[j])
+		}
+		return ports[i] < ports[j]
+	})
+	return ports, nil
+}
+
+// portConnected returns true if the COM port name is there.
+func portConnected(name string) bool {
+	ports, err := serialPorts()
+	if err != nil {
+		// can't tell, so don't complain
+		return true
+	}
+
+	name = strings.TrimPrefix(name, `\\.\`)
+	for _, p := range ports {
+		if strings.EqualFold(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// runSettingsWindow shows the settings window, saving and applying the settings when the user clicks OK.
+func runSettingsWindow() error {
+	if nbmRunSettingsWindow.Lock() {
+		defer nbmRunSettingsWindow.Unlock()
+
+		s := settingsOf(currentConfig())
+
+		// the configured port might not be plugged in right now
+		ports, _ := serialPorts()
+		found := s.Port == ""
+		for _, p := range ports {
+			found = found || p == s.Port
+		}
+		if !found {
+			ports = append(ports, s.Port)
+		}
+
+		bauds := make([]string, len(serialBauds))
+		for i, b := range serialBauds {
+			bauds[i] = strconv.Itoa(b)
+		}
+
+		var port, baud *walk.ComboBox
+		var pollRate, maxHDOP *walk.NumberEdit
+		var apiAddress, mqttBroker, wsjtxAddress, js8callAddress, udpBroadcastAddress, trackDirectory *walk.LineEdit
+
+		mw := declarative.MainWindow{
+			AssignTo: &settingsWindow,
+			Name:     "settingsmw",
+			Title:    "Settings",
+			Icon:     appIcon,
+			Size:     declarative.Size{Width: 420, Height: 420},
+			Layout:   declarative.VBox{},
+			Children: []declarative.Widget{
+				declarative.GroupBox{
+					Title:  "GPS Device",
+					Layout: declarative.Grid{Columns: 2},
+					Children: []declarative.Widget{
+						declarative.Label{Text: "Port:"},
+						declarative.ComboBox{AssignTo: &port, Editable: true, Model: ports},
+						declarative.Label{Text: "Baud:"},
+						declarative.ComboBox{AssignTo: &baud, Editable: true, Model: bauds},
+						declarative.Label{Text: "Poll rate (seconds):"},
+						declarative.NumberEdit{AssignTo: &pollRate, MinValue: minPollRate, MaxValue: maxPollRate, Value: float64(s.PollRate)},
+						declarative.Label{Text: "Maximum HDOP:"},
+						declarative.NumberEdit{AssignTo: &maxHDOP, Decimals: 1, MinValue: 0, MaxValue: maxMaxHDOP, Value: s.MaxHDOP},
+					},
+				},
+				declarative.GroupBox{
+					Title:  "Integrations (empty is off)",
+					Layout: declarative.Grid{Columns: 2},
+					Children: []declarative.Widget{
+						declarative.Label{Text: "API address:"},
+						declarative.LineEdit{AssignTo: &apiAddress, Text: s.APIAddress},
+						declarative.Label{Text: "MQTT broker:"},
+						declarative.LineEdit{AssignTo: &mqttBroker, Text: s.MQTTBroker},
+						declarative.Label{Text: "WSJT-X address:"},
+						declarative.LineEdit{AssignTo: &wsjtxAddress, Text: s.WSJTXAddress},
+						declarative.Label{Text: "JS8Call address:"},
+						declarative.LineEdit{AssignTo: &js8callAddress, Text: s.JS8CallAddress},
+						declarative.Label{Text: "UDP broadcast address:"},
+						declarative.LineEdit{AssignTo: &udpBroadcastAddress, Text: s.UDPBroadcastAddress},
+						declarative.Label{Text: "Track directory:"},
+						declarative.LineEdit{AssignTo: &trackDirectory, Text: s.TrackDirectory},
+					},
+				},
+				declarative.Composite{
+					Layout: declarative.HBox{MarginsZero: true},
+					Children: []declarative.Widget{
+						declarative.HSpacer{},
+						declarative.PushButton{
+							Text: "OK",
+							OnClicked: func() {
+								s.Port = port.Text()
+								s.Baud, _ = strconv.Atoi(baud.Text())
+								s.PollRate = int(pollRate.Value())
+								s.MaxHDOP = maxHDOP.Value()
+								s.APIAddress = apiAddress.Text()
+								s.MQTTBroker = mqttBroker.Text()
+								s.WSJTXAddress = wsjtxAddress.Text()
+								s.JS8CallAddress = js8callAddress.Text()
+								s.UDPBroadcastAddress = udpBroadcastAddress.Text()
+								s.TrackDirectory = trackDirectory.Text()
+
+								err := saveSettingsWindow(s)
+								if err != nil {
+									walk.MsgBox(settingsWindow, "Settings", err.Error(), walk.MsgBoxIconError)
+									return
+								}
+								settingsWindow.Close()
+							},
+						},
+						declarative.PushButton{
+							Text: "Cancel",
+							OnClicked: func() {
+								settingsWindow.Close()
+							},
+						},
+					},
+				},
+			},
+		}
+
+		// create window
+		err := mw.Create()
+		if err != nil {
+			return err
+		}
+
+		// editable combo boxes only take their text once they exist
+		for _, t := range []struct {
+			cb   *walk.ComboBox
+			text string
+		}{
+			{cb: port, text: s.Port},
+			{cb: baud, text: strconv.Itoa(s.Baud)},
+		} {
+			err = t.cb.SetText(t.text)
+			if err != nil {
+				log.Printf("%+v", err)
+			}
+		}
+
+		// disable maximize, minimize, and resizing
+		hwnd := settingsWindow.Handle()
+		win.SetWindowLong(hwnd, win.GWL_STYLE, win.GetWindowLong(hwnd, win.GWL_STYLE) & ^(win.WS_MAXIMIZEBOX|win.WS_MINIMIZEBOX|win.WS_SIZEBOX))
+
+		// start message loop
+		settingsWindow.Run()
+	} else {
+		// bring already running settings window to top
+		settingsWindow.Show()
+	}
+
+	return nil
+}
+
+// saveSettingsWindow applies s and saves it to the configuration file
+// s is applied first so settings that can't be started aren't saved.
+func saveSettingsWindow(s settings) error {
+	err := s.validate()
+	if err != nil {
+		return err
+	}
+
+	err = applyConfig(s.applyTo(currentConfig()))
+	if err != nil {
+		return fmt.Errorf("settings not applied: %w", err)
+	}
+
+	err = saveSettings(options.configPath, s)
+	if err != nil {
+		return fmt.Errorf("settings applied but not saved: %w", err)
+	}
+
+	log.Printf("saved settings to %s", options.configPath)
+	return nil
 }
 
 // systemTray create the UI element in the system tray for the user to interact with
-func systemTray() error {
+// problem is shown before the settings window if we couldn't start the way we were configured to.
+func systemTray(problem string) error {
 	var err error
 
 	// load appIcon
@@ -226,18 +801,50 @@ func systemTray() error {
 		}
 	}()
 
-	// set the icon and a tool tip text
-	err = ni.SetIcon(appIcon)
-	if err != nil {
-		log.Printf("%+v", err)
-		return err
+	// an icon for each state of the gps data
+	icons := make(map[trayState]*walk.Icon, len(trayStates))
+	for _, s := range trayStates {
+		icon, err := walk.NewIconFromImage(trayIconImage(s, 32))
+		if err != nil {
+			log.Printf("%+v", err)
+			return err
+		}
+		defer icon.Dispose()
+		icons[s] = icon
 	}
-	err = ni.SetToolTip("gps-qth-qtr")
+
+	// set the icon and a tool tip text
+	err = setNotifyIconState(ni, icons, gpsdata.values())
 	if err != nil {
-		log.Printf("%+v", err)
 		return err
 	}
 
+	// keep them up to date after every poll, and every minute for the time since the time was set
+	updates, unsubscribe := subscribe()
+	defer unsubscribe()
+	quit := make(chan struct{})
+	defer close(quit)
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case _, ok := <-updates:
+				if !ok {
+					return
+				}
+			case <-ticker.C:
+			case <-quit:
+				return
+			}
+
+			mw.Synchronize(func() {
+				_ = setNotifyIconState(ni, icons, gpsdata.values())
+			})
+		}
+	}()
+
 	// gridsquare action in context menu
 	gridsquareAction := walk.NewAction()
 	err = gridsquareAction.SetText("Copy Gridsquare")
@@ -276,6 +883,25 @@ func systemTray() error {
 		return err
 	}
 
+	// settings action in context menu
+	settingsAction := walk.NewAction()
+	err = settingsAction.SetText("Settings...")
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+	settingsAction.Triggered().Attach(func() {
+		err = runSettingsWindow()
+		if err != nil {
+			log.Printf("%+v", err)
+		}
+	})
+	err = ni.ContextMenu().Actions().Add(settingsAction)
+	if err != nil {
+		log.Printf("%+v", err)
+		return err
+	}
+
 	// update now action in context menu
 	updateAction := walk.NewAction()
 	err = updateAction.SetText("Update now")
@@ -284,7 +910,7 @@ func systemTray() error {
 		return err
 	}
 	updateAction.Triggered().Attach(func() {
-		updated := gatherGpsData()
+		updated := gatherGpsData(true)
 		if !updated {
 			log.Printf("updateAction: failed to update")
 		}
@@ -318,6 +944,20 @@ func systemTray() error {
 		return err
 	}
 
+	// nothing to poll until the gps device is set up, or what stopped us starting is fixed
+	if problem != "" || currentConfig().GPSDevice.Port == "" {
+		mw.Synchronize(func() {
+			if problem != "" {
+				walk.MsgBox(nil, "gps-qth-qtr", problem, walk.MsgBoxIconWarning|walk.MsgBoxOK)
+			}
+
+			err := runSettingsWindow()
+			if err != nil {
+				log.Printf("%+v", err)
+			}
+		})
+	}
+
 	// start message loop
 	mw.Run()
 
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// wsjtxConfig is the configuration of the WSJT-X integration.
+type wsjtxConfig struct {
+	Address    string
+	GridLength int
+}
+
+// WSJT-X message types we use.
+const (
+	wsjtxHeartbeat uint32 = 0
+	wsjtxStatus    uint32 = 1
+	wsjtxClose     uint32 = 6
+	wsjtxLocation  uint32 = 17
+)
+
+const (
+	// identifies WSJT-X messages
+	wsjtxMagic uint32 = 0xADBCCBDA
+
+	// Qt 5 QDataStream serialization
+	wsjtxSchema uint32 = 2
+
+	// forget WSJT-X instances that haven't sent anything for this long
+	wsjtxClientTimeout = 2 * time.Minute
+)
+
+// wsjtxWriter encodes values the way QDataStream does.
+type wsjtxWriter struct {
+	bytes.Buffer
+}
+
+// uint32 writes a quint32.
+func (w *wsjtxWriter) uint32(v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	w.Write(b[:])
+}
+
+// utf8 writes a QByteArray of utf-8, empty strings are written as null.
+func (w *wsjtxWriter) utf8(s string) {
+	if s == "" {
+		w.uint32(0xFFFFFFFF)
+		return
+	}
+	w.uint32(uint32(len(s)))
+	w.WriteString(s)
+}
+
+// wsjtxReader decodes values the way QDataStream does, the first error is kept and later reads do nothing.
+type wsjtxReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+// read fills b unless there has already been an error.
+func (r *wsjtxReader) read(b []byte) {
+	if r.err == nil {
+		_, r.err = io.ReadFull(r.r, b)
+	}
+}
+
+// uint32 reads a quint32.
+func (r *wsjtxReader) uint32() uint32 {
+	var b [4]byte
+	r.read(b[:])
+	return binary.BigEndian.Uint32(b[:])
+}
+
+// uint64 reads a quint64.
+func (r *wsjtxReader) uint64() uint64 {
+	var b [8]byte
+	r.read(b[:])
+	return binary.BigEndian.Uint64(b[:])
+}
+
+// bool reads a bool.
+func (r *wsjtxReader) bool() bool {
+	var b [1]byte
+	r.read(b[:])
+	return b[0] != 0
+}
+
+// utf8 reads a QByteArray of utf-8, null is returned as an empty string.
+func (r *wsjtxReader) utf8() string {
+	n := r.uint32()
+	if r.err != nil || n == 0xFFFFFFFF {
+		return ""
+	}
+	if int64(n) > int64(r.r.Len()) {
+		r.err = fmt.Errorf("WSJT-X string longer than message")
+		return ""
+	}
+
+	b := make([]byte, n)
+	r.read(b)
+	return string(b)
+}
+
+// encodeWSJTXLocation returns a Location message for the WSJT-X instance id.
+func encodeWSJTXLocation(id, grid string) []byte {
+	var w wsjtxWriter
+
+	w.uint32(wsjtxMagic)
+	w.uint32(wsjtxSchema)
+	w.uint32(wsjtxLocation)
+	w.utf8(id)
+	w.utf8(grid)
+
+	return w.Bytes()
+}
+
+// wsjtxMessage is the part of a message from WSJT-X that we care about.
+type wsjtxMessage struct {
+	typ  uint32
+	id   string
+	grid string
+}
+
+// decodeWSJTXMessage parses b, the grid is only set for Status messages.
+func decodeWSJTXMessage(b []byte) (wsjtxMessage, error) {
+	r := &wsjtxReader{r: bytes.NewReader(b)}
+
+	if r.uint32() != wsjtxMagic || r.err != nil {
+		err := fmt.Errorf("not a WSJT-X message")
+		log.Printf("%+v", err)
+		return wsjtxMessage{}, err
+	}
+
+	// any schema is fine, the fields we need haven't changed
+	_ = r.uint32()
+
+	m := wsjtxMessage{
+		typ: r.uint32(),
+		id:  r.utf8(),
+	}
+
+	if m.typ == wsjtxStatus {
+		_ = r.uint64() // dial frequency
+		_ = r.utf8()   // mode
+		_ = r.utf8()   // dx call
+		_ = r.utf8()   // report
+		_ = r.utf8()   // tx mode
+		_ = r.bool()   // tx enabled
+		_ = r.bool()   // transmitting
+		_ = r.bool()   // decoding
+		_ = r.uint32() // rx df
+		_ = r.uint32() // tx df
+		_ = r.utf8()   // de call
+		m.grid = r.utf8()
+	}
+
+	if r.err != nil {
+		log.Printf("%+v", r.err)
+		return wsjtxMessage{}, r.err
+	}
+	return m, nil
+}
+
+// wsjtxClient is a WSJT-X instance that has sent us messages.
+type wsjtxClient struct {
+	addr *net.UDPAddr
+	grid string
+	seen time.Time
+}
+
+// wsjtxIntegration tells the WSJT-X instances that talk to us where we are.
+type wsjtxIntegration struct {
+	cfg     wsjtxConfig
+	conn    *net.UDPConn
+	grid    string
+	clients map[string]*wsjtxClient
+	done    sync.WaitGroup
+	quit    chan struct{}
+	mu      sync.Mutex
+}
+
+// newWSJTXIntegration validates cfg and starts listening for WSJT-X on its address.
+func newWSJTXIntegration(cfg wsjtxConfig) (*wsjtxIntegration, error) {
+	switch cfg.GridLength {
+	case 0:
+		cfg.GridLength = 6
+	case 4, 6:
+	default:
+		err := fmt.Errorf("WSJT-X grid length must be 4 or 6")
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	addr, err := net.ResolveUDPAddr("udp", cfg.Address)
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	var conn *net.UDPConn
+	if addr.IP.IsMulticast() {
+		conn, err = net.ListenMulticastUDP("udp", nil, addr)
+	} else {
+		conn, err = net.ListenUDP("udp", addr)
+	}
+	if err != nil {
+		log.Printf("%+v", err)
+		return nil, err
+	}
+
+	return &wsjtxIntegration{
+		cfg:     cfg,
+		conn:    conn,
+		clients: make(map[string]*wsjtxClient),
+		quit:    make(chan struct{}),
+	}, nil
+}
+
+// update sends a Location message to the client with id if it isn't already using our grid.
+func (wi *wsjtxIntegration) update(id string, c *wsjtxClient) {
+	if wi.grid == "" || c.grid == wi.grid {
+		return
+	}
+
+	_, err := wi.conn.WriteToUDP(encodeWSJTXLocation(id, wi.grid), c.addr)
+	if err != nil {
+		log.Printf("%+v", err)
+		return
+	}
+	c.grid = wi.grid
+}
+
+// receive handles a message from a WSJT-X instance at addr.
+func (wi *wsjtxIntegration) receive(b []byte, addr *net.UDPAddr) {
+	m, err := decodeWSJTXMessage(b)
+	if err != nil {
+		return
+	}
+
+	wi.mu.Lock()
+	defer wi.mu.Unlock()
+
+	if m.typ == wsjtxClose {
+		delete(wi.clients, m.id)
+		return
+	}
+
+	c, ok := wi.clients[m.id]
+	if !ok {
+		log.Printf("WSJT-X %s at %s", m.id, addr)
+		c = &wsjtxClient{}
+		wi.clients[m.id] = c
+	}
+	c.addr = addr
+	c.seen = time.Now()
+	if m.typ == wsjtxStatus {
+		c.grid = m.grid
+	}
+
+	if m.typ == wsjtxHeartbeat || m.typ == wsjtxStatus {
+		wi.update(m.id, c)
+	}
+}
+
+// setGrid updates all the clients to grid.
+func (wi *wsjtxIntegration) setGrid(grid string) {
+	wi.mu.Lock()
+	defer wi.mu.Unlock()
+
+	wi.grid = truncateGrid(grid, wi.cfg.GridLength)
+	for id, c := range wi.clients {
+		if time.Since(c.seen) > wsjtxClientTimeout {
+			delete(wi.clients, id)
+			continue
+		}
+		wi.update(id, c)
+	}
+}
+
+// start handles messages from WSJT-X and gridsquare changes in the background until stop is called.
+func (wi *wsjtxIntegration) start() {
+	// don't send the last known grid from before we started
+	if !gpsdata.isStale() {
+		wi.setGrid(gpsdata.getGridsquare())
+	}
+
+	wi.done.Add(2)
+
+	go func() {
+		defer wi.done.Done()
+
+		b := make([]byte, 4096)
+		for {
+			n, addr, err := wi.conn.ReadFromUDP(b)
+			if err != nil {
+				select {
+				case <-wi.quit:
+				default:
+					log.Printf("%+v", err)
+				}
+				return
+			}
+			wi.receive(b[:n], addr)
+		}
+	}()
+
+	updates, unsubscribe := subscribe()
+	go func() {
+		defer wi.done.Done()
+		defer unsubscribe()
+
+		for {
+			select {
+			case u := <-updates:
+				for _, e := range u.events {
+					if e == eventGridChanged {
+						wi.setGrid(u.values.Gridsquare)
+					}
+				}
+			case <-wi.quit:
+				return
+			}
+		}
+	}()
+}
+
+// stop closes the connection and waits for the background work to finish.
+func (wi *wsjtxIntegration) stop() {
+	close(wi.quit)
+	wi.conn.Close()
+	wi.done.Wait()
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_encodeWSJTXLocation(t *testing.T) {
+	got := encodeWSJTXLocation("WSJT-X", "FM18lw")
+	want := []byte{
+		0xAD, 0xBC, 0xCB, 0xDA,
+		0x00, 0x00, 0x00, 0x02,
+		0x00, 0x00, 0x00, 0x11,
+		0x00, 0x00, 0x00, 0x06, 'W', 'S', 'J', 'T', '-', 'X',
+		0x00, 0x00, 0x00, 0x06, 'F', 'M', '1', '8', 'l', 'w',
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("encodeWSJTXLocation() = %x, want %x", got, want)
+	}
+}
+
+// encodeWSJTXTestStatus returns a Status message like WSJT-X sends.
+func encodeWSJTXTestStatus(id, grid string) []byte {
+	var w wsjtxWriter
+
+	w.uint32(wsjtxMagic)
+	w.uint32(3)
+	w.uint32(wsjtxStatus)
+	w.utf8(id)
+	w.Write([]byte{0, 0, 0, 0, 0, 0x6B, 0xF0, 0xD0})
+	w.utf8("FT8")
+	w.utf8("")
+	w.utf8("-10")
+	w.utf8("FT8")
+	w.Write([]byte{0, 0, 1})
+	w.uint32(1500)
+	w.uint32(1200)
+	w.utf8("K1ABC")
+	w.utf8(grid)
+	w.utf8("")
+
+	return w.Bytes()
+}
+
+func Test_decodeWSJTXMessage(t *testing.T) {
+	var hb wsjtxWriter
+	hb.uint32(wsjtxMagic)
+	hb.uint32(2)
+	hb.uint32(wsjtxHeartbeat)
+	hb.utf8("WSJT-X - rig 2")
+	hb.uint32(3)
+	hb.utf8("2.1.2")
+	hb.utf8("")
+
+	type args struct {
+		b []byte
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    wsjtxMessage
+		wantErr bool
+	}{
+		{
+			name:    "Heartbeat",
+			args:    args{b: hb.Bytes()},
+			want:    wsjtxMessage{typ: wsjtxHeartbeat, id: "WSJT-X - rig 2"},
+			wantErr: false,
+		},
+		{
+			name:    "Status",
+			args:    args{b: encodeWSJTXTestStatus("WSJT-X", "FN42")},
+			want:    wsjtxMessage{typ: wsjtxStatus, id: "WSJT-X", grid: "FN42"},
+			wantErr: false,
+		},
+		{
+			name:    "Location",
+			args:    args{b: encodeWSJTXLocation("WSJT-X", "FM18lw")},
+			want:    wsjtxMessage{typ: wsjtxLocation, id: "WSJT-X"},
+			wantErr: false,
+		},
+		{
+			name:    "Truncated status",
+			args:    args{b: encodeWSJTXTestStatus("WSJT-X", "FN42")[:40]},
+			want:    wsjtxMessage{},
+			wantErr: true,
+		},
+		{
+			name:    "Not WSJT-X",
+			args:    args{b: []byte("$GNRMC,203434.00,A")},
+			want:    wsjtxMessage{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		ttt := tt
+
+		t.Run(ttt.name, func(t *testing.T) {
+			got, err := decodeWSJTXMessage(ttt.args.b)
+			if (err != nil) != ttt.wantErr {
+				t.Errorf("decodeWSJTXMessage() error = %v, wantErr %v", err, ttt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, ttt.want) {
+				t.Errorf("decodeWSJTXMessage() = %+v, want %+v", got, ttt.want)
+			}
+		})
+	}
+}
+
+func Test_wsjtxIntegration(t *testing.T) {
+	wi, err := newWSJTXIntegration(wsjtxConfig{Address: "127.0.0.1:0", GridLength: 4})
+	if err != nil {
+		t.Fatalf("newWSJTXIntegration() error = %v", err)
+	}
+	wi.start()
+	defer wi.stop()
+
+	// pretend to be WSJT-X
+	conn, err := net.DialUDP("udp", nil, wi.conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatalf("net.DialUDP() error = %v", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Write(encodeWSJTXTestStatus("WSJT-X", "FN42"))
+	if err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	// wait for status to be processed
+	for {
+		wi.mu.Lock()
+		n := len(wi.clients)
+		wi.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	v := newGPSData().values()
+	v.Gridsquare = "FM18lw"
+	publish([]gpsEvent{eventGridChanged}, v)
+
+	err = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err != nil {
+		t.Fatalf("SetReadDeadline() error = %v", err)
+	}
+	b := make([]byte, 1024)
+	n, err := conn.Read(b)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	want := encodeWSJTXLocation("WSJT-X", "FM18")
+	if !bytes.Equal(b[:n], want) {
+		t.Errorf("Location = %x, want %x", b[:n], want)
+	}
+}